@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/config"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metabase"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/model"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/tracing"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/views"
+)
+
+// app holds the components shared by the commands that work with the full
+// adapter configuration.
+type app struct {
+	cfg      *config.Config
+	logger   *slog.Logger
+	client   *flexibee.Client
+	store    store.Sink
+	registry *registry.Registry
+	location *time.Location
+
+	shutdownTracing func(context.Context) error
+}
+
+// loadConfig loads the adapter configuration from args and the environment
+// with any command-specific flags already defined on fs.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, *slog.Logger, bool) {
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		return nil, nil, false
+	}
+	return cfg, setupLogger(cfg.LogLevel, cfg.LogFormat), true
+}
+
+// newApp connects to the database and builds the evidence registry from
+// cfg. The caller must close the app.
+func newApp(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*app, error) {
+	client := flexibee.NewClient(
+		cfg.FlexibeeURL,
+		cfg.FlexibeeCompany,
+		cfg.FlexibeeUsername,
+		cfg.FlexibeePassword,
+		logger,
+	)
+
+	reg, location, err := buildRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("registered evidence types", "count", reg.Len())
+
+	st, err := openStore(ctx, cfg, reg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.OTLPEndpoint)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return &app{
+		cfg:      cfg,
+		logger:   logger,
+		client:   client,
+		store:    st,
+		registry: reg,
+		location: location,
+
+		shutdownTracing: shutdownTracing,
+	}, nil
+}
+
+// openStore connects to the database configured by cfg. The sink is
+// PostgreSQL, MySQL or SQLite, by URL scheme.
+func openStore(ctx context.Context, cfg *config.Config, reg *registry.Registry, logger *slog.Logger) (store.Sink, error) {
+	unfiltered, err := unfilteredTables(reg, cfg.RowSecurityUnfiltered)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := store.Open(ctx, cfg.DatabaseURL, store.Options{
+		AdminURL:         cfg.DatabaseAdminURL,
+		ReaderRole:       cfg.DatabaseReaderRole,
+		RowSecurity:      rowSecurityRules(cfg.RowSecurity),
+		Company:          cfg.FlexibeeCompany,
+		UnfilteredTables: unfiltered,
+	}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("connect to database: %w", err)
+	}
+	return st, nil
+}
+
+// close flushes pending spans and closes the database.
+func (a *app) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.shutdownTracing(ctx); err != nil {
+		a.logger.Warn("failed to flush traces", "error", err)
+	}
+	a.store.Close()
+}
+
+// buildRegistry registers the default evidences and applies the history,
+// partitioning, index, priority and schedule configuration to them.
+func buildRegistry(cfg *config.Config) (*registry.Registry, *time.Location, error) {
+	reg := registry.NewDefault()
+
+	for _, slug := range cfg.HistoryEvidences {
+		if !reg.Update(slug, func(ev *registry.Evidence) { ev.History = true }) {
+			return nil, nil, fmt.Errorf("unknown evidence %s in history configuration", slug)
+		}
+	}
+
+	for _, slug := range cfg.PartitionEvidences {
+		ev, ok := reg.Get(slug)
+		if !ok || ev.DateColumn == "" {
+			return nil, nil, fmt.Errorf("evidence %s cannot be partitioned (unknown or without a date column)", slug)
+		}
+		reg.Update(slug, func(ev *registry.Evidence) { ev.Partitioned = true })
+	}
+
+	for slug, indexes := range cfg.ExtraIndexes {
+		if !reg.Update(slug, func(ev *registry.Evidence) { ev.Indexes = append(ev.Indexes, indexes...) }) {
+			return nil, nil, fmt.Errorf("unknown evidence %s in extra index configuration", slug)
+		}
+	}
+
+	for slug, priority := range cfg.EvidencePriority {
+		if !reg.Update(slug, func(ev *registry.Evidence) { ev.Priority = priority }) {
+			return nil, nil, fmt.Errorf("unknown evidence %s in priority configuration", slug)
+		}
+	}
+
+	location := time.Local
+	if cfg.SyncTimezone != "" {
+		location, _ = time.LoadLocation(cfg.SyncTimezone) // validated by config
+	}
+	for slug, spec := range cfg.SyncSchedules {
+		if _, err := adaptersync.ParseSchedule(spec, location); err != nil {
+			return nil, nil, fmt.Errorf("sync schedule of %s: %w", slug, err)
+		}
+		if !reg.Update(slug, func(ev *registry.Evidence) { ev.Schedule = spec }) {
+			return nil, nil, fmt.Errorf("unknown evidence %s in sync schedule configuration", slug)
+		}
+	}
+
+	if _, err := reg.Waves(); err != nil {
+		return nil, nil, fmt.Errorf("invalid evidence dependencies: %w", err)
+	}
+	return reg, location, nil
+}
+
+// newCleaner creates the retention cleaner; dryRun only reports what it
+// would remove.
+func (a *app) newCleaner(dryRun bool) *adaptersync.Cleaner {
+	return adaptersync.NewCleaner(a.store, a.registry, adaptersync.CleanupConfig{
+		RetentionDays: a.cfg.RetentionDays,
+		BatchSize:     a.cfg.CleanupBatchSize,
+		DryRun:        dryRun,
+
+		RunRetentionDays: a.cfg.SyncRunsRetentionDays,
+	}, a.logger)
+}
+
+// newEngine creates the sync engine with its reconciler and stages.
+func (a *app) newEngine() *adaptersync.Engine {
+	cfg := a.cfg
+
+	reconciler := adaptersync.NewReconciler(a.client, a.store, a.registry, adaptersync.ReconcileConfig{
+		Months:        cfg.ReconcileMonths,
+		RetentionDays: cfg.RetentionDays,
+	}, a.logger)
+
+	return adaptersync.NewEngine(a.client, a.store, a.registry, a.newCleaner(false), adaptersync.EngineConfig{
+		SyncInterval:    cfg.SyncInterval,
+		CleanupInterval: cfg.CleanupInterval,
+		BatchSize:       cfg.SyncBatchSize,
+		Concurrency:     cfg.SyncConcurrency,
+
+		PartitionMonthsAhead: cfg.PartitionMonthsAhead,
+		IndexRawData:         cfg.IndexRawData,
+		Stages:               a.stages(),
+		Location:             a.location,
+
+		Reconciler:        reconciler,
+		ReconcileInterval: cfg.ReconcileInterval,
+
+		Elector: a.elector(),
+	}, a.logger)
+}
+
+// elector returns the leader elector, or nil without leader election.
+func (a *app) elector() *adaptersync.LeaderElector {
+	if !a.cfg.LeaderElection {
+		return nil
+	}
+	holder := a.cfg.LeaderID
+	if holder == "" {
+		holder, _ = os.Hostname()
+	}
+	if holder == "" {
+		holder = fmt.Sprintf("adapter-%d", os.Getpid())
+	}
+	return adaptersync.NewLeaderElector(a.store, holder, a.cfg.LeaderLease, a.logger)
+}
+
+// exclusive runs fn for a one-off command. With leader election it holds
+// the leader lease while fn runs, so that the command does not sync
+// alongside the leader replica, and fails while another replica holds it.
+func (a *app) exclusive(ctx context.Context, fn func(context.Context) error) error {
+	elector := a.elector()
+	if elector == nil {
+		return fn(ctx)
+	}
+	return elector.RunExclusive(ctx, fn)
+}
+
+// stages returns the optional stages run around every sync pass. They are
+// written for PostgreSQL and are skipped on other sinks.
+func (a *app) stages() []adaptersync.Stage {
+	cfg, logger := a.cfg, a.logger
+
+	var stages []adaptersync.Stage
+	if pg, ok := a.store.(*store.Store); ok {
+		if cfg.CuratedViews {
+			stages = append(stages, views.NewManager(pg.Pool(), views.Default(), views.Config{
+				Materialized: cfg.MaterializeViews,
+				// The base currency views wait until Flexibee tells the
+				// base currency.
+				Deferred: func(ctx context.Context) ([]views.View, error) {
+					base, err := a.client.FetchBaseCurrency(ctx)
+					if err != nil {
+						return nil, fmt.Errorf("read base currency: %w", err)
+					}
+					return views.CurrencyViews(a.registry, base), nil
+				},
+			}, logger))
+		}
+		if cfg.StarSchema {
+			stages = append(stages, model.NewBuilder(pg.Pool(), logger))
+		}
+	} else {
+		if cfg.CuratedViews {
+			logger.Warn("curated views require PostgreSQL, skipping")
+		}
+		if cfg.StarSchema {
+			logger.Warn("star schema requires PostgreSQL, skipping")
+		}
+	}
+	if cfg.MetabaseURL != "" {
+		databaseURL := cfg.MetabaseDatabaseURL
+		if databaseURL == "" {
+			databaseURL = cfg.DatabaseURL
+		}
+		stages = append(stages, metabase.NewIntegrator(
+			metabase.NewClient(cfg.MetabaseURL, cfg.MetabaseAPIKey),
+			metabase.Config{DatabaseName: cfg.MetabaseDatabase, DatabaseURL: databaseURL},
+			logger,
+		))
+	}
+	return stages
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+// runSyncOnce implements "adapter sync-once [flags]": a single sync pass,
+// printing the outcome of each evidence and exiting non-zero when any
+// evidence fails. Meant for cron jobs.
+func runSyncOnce(args []string) int {
+	fs := flag.NewFlagSet("sync-once", flag.ExitOnError)
+	cfg, logger, ok := loadConfig(fs, args)
+	if !ok {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newApp(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize adapter", "error", err)
+		return 1
+	}
+	defer a.close()
+
+	engine := a.newEngine()
+	var result *adaptersync.PassResult
+	err = a.exclusive(ctx, func(ctx context.Context) error {
+		if err := engine.Setup(ctx); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+		var err error
+		if result, err = engine.RunOnce(ctx); result == nil {
+			return err
+		}
+
+		// Indexes are built once the data is in, as in the daemon.
+		if err := engine.EnsureIndexes(ctx); err != nil {
+			logger.Warn("index build stopped", "error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("sync failed", "error", err)
+		return 1
+	}
+	printPassResult(result)
+
+	// A partially successful pass exits with its own code, so that it can
+	// be told apart from a pass in which nothing synced.
+	switch result.Status() {
+	case adaptersync.PassPartial:
+		return 3
+	case adaptersync.PassFailed:
+		return 1
+	}
+	return 0
+}
+
+// printPassResult prints the outcome of every evidence of a sync pass.
+func printPassResult(result *adaptersync.PassResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "EVIDENCE\tOUTCOME\tDURATION\tDETAIL")
+	for _, ev := range result.Evidences {
+		detail := ev.Reason
+		if ev.Err != nil {
+			detail = ev.Err.Error()
+		}
+		if detail == "" {
+			detail = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ev.Evidence, ev.Outcome, ev.Duration.Round(time.Millisecond), detail)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nSync %s: %d ok, %d failed, %d skipped in %s\n", result.Status(),
+		result.Count(adaptersync.OutcomeOK), result.Count(adaptersync.OutcomeFailed), result.Count(adaptersync.OutcomeSkipped),
+		result.FinishedAt.Sub(result.StartedAt).Round(time.Millisecond))
+}
+
+// runResync implements "adapter resync --evidence X [--since DATE] [flags]":
+// it resets the sync state of the evidences and syncs them again, either
+// completely or with the records changed since the date.
+func runResync(args []string) int {
+	fs := flag.NewFlagSet("resync", flag.ExitOnError)
+	evidences := fs.String("evidence", "", "Comma-separated evidences to resync")
+	since := fs.String("since", "", "Resync records changed since this date, YYYY-MM-DD (default all records)")
+	cfg, logger, ok := loadConfig(fs, args)
+	if !ok {
+		return 1
+	}
+
+	slugs := splitSlugs(*evidences)
+	if len(slugs) == 0 {
+		logger.Error("evidence is required (--evidence)")
+		return 2
+	}
+
+	var lastUpdate *time.Time
+	if *since != "" {
+		t, err := time.ParseInLocation(time.DateOnly, *since, time.Local)
+		if err != nil {
+			logger.Error("invalid date (expected YYYY-MM-DD)", "since", *since)
+			return 2
+		}
+		lastUpdate = &t
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newApp(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize adapter", "error", err)
+		return 1
+	}
+	defer a.close()
+
+	for _, slug := range slugs {
+		if _, ok := a.registry.Get(slug); !ok {
+			logger.Error("unknown evidence", "evidence", slug)
+			return 2
+		}
+	}
+
+	engine := a.newEngine()
+	err = a.exclusive(ctx, func(ctx context.Context) error {
+		if err := engine.Setup(ctx); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+		for _, slug := range slugs {
+			if err := engine.ResyncEvidence(ctx, slug, lastUpdate); err != nil {
+				return fmt.Errorf("evidence %s: %w", slug, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("resync failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runStatus implements "adapter status [--json] [flags]". It lists the
+// evidences as configured for the daemon, without contacting Flexibee.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the sync state as JSON")
+	cfg, logger, ok := loadConfig(fs, args)
+	if !ok {
+		return 1
+	}
+
+	reg, _, err := buildRegistry(cfg)
+	if err != nil {
+		logger.Error("invalid configuration", "error", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	st, err := openStore(ctx, cfg, reg, logger)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+	defer st.Close()
+
+	var status adapterStatus
+	lease, err := st.GetLease(ctx, adaptersync.LeaseName)
+	if err != nil {
+		logger.Error("failed to read leader lease", "error", err)
+		return 1
+	}
+	if lease != nil && lease.ExpiresAt.After(time.Now()) {
+		status.Leader = &leaderStatus{Holder: lease.Holder, Since: lease.AcquiredAt, ExpiresAt: lease.ExpiresAt}
+	}
+
+	paused, err := st.PausedEvidences(ctx)
+	if err != nil {
+		logger.Error("failed to read paused evidences", "error", err)
+		return 1
+	}
+	for _, ev := range reg.All() {
+		state, err := st.GetSyncState(ctx, ev.Slug)
+		if err != nil {
+			logger.Error("failed to read sync state", "evidence", ev.Slug, "error", err)
+			return 1
+		}
+		s := newEvidenceStatus(ev.Slug, state)
+		s.Schedule = ev.Schedule
+		if at, ok := paused[ev.Slug]; ok {
+			s.PausedAt = &at
+		}
+		status.Evidences = append(status.Evidences, s)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(status); err != nil {
+			logger.Error("failed to write status", "error", err)
+			return 1
+		}
+		return 0
+	}
+	printStatus(status)
+	return 0
+}
+
+// adapterStatus is what status prints.
+type adapterStatus struct {
+	Leader    *leaderStatus    `json:"leader,omitempty"` // nil without an unexpired lease
+	Evidences []evidenceStatus `json:"evidences"`
+}
+
+// leaderStatus is the replica holding the leader lease.
+type leaderStatus struct {
+	Holder    string    `json:"holder"`
+	Since     time.Time `json:"since"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// evidenceStatus is the sync state of one evidence as printed by status.
+type evidenceStatus struct {
+	Evidence   string     `json:"evidence"`
+	Status     string     `json:"status"`
+	LastSync   *time.Time `json:"last_sync,omitempty"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	RowCount   int64      `json:"row_count"`
+	Error      string     `json:"error,omitempty"`
+	Schedule   string     `json:"schedule,omitempty"`  // own schedule, empty for SYNC_INTERVAL
+	PausedAt   *time.Time `json:"paused_at,omitempty"` // nil unless paused
+}
+
+func newEvidenceStatus(slug string, state *store.SyncState) evidenceStatus {
+	if state == nil {
+		return evidenceStatus{Evidence: slug, Status: "never synced"}
+	}
+	return evidenceStatus{
+		Evidence:   slug,
+		Status:     state.Status,
+		LastSync:   &state.LastSync,
+		LastUpdate: state.LastUpdate,
+		RowCount:   state.RowCount,
+		Error:      state.ErrorMsg,
+	}
+}
+
+func printStatus(status adapterStatus) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	if l := status.Leader; l != nil {
+		fmt.Printf("Leader: %s (since %s, lease expires %s)\n\n", l.Holder, l.Since.Format(time.RFC3339), l.ExpiresAt.Format(time.RFC3339))
+	} else {
+		fmt.Print("Leader: none\n\n")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "EVIDENCE\tSTATUS\tSCHEDULE\tLAST SYNC\tLAST UPDATE\tROWS\tERROR")
+	for _, s := range status.Evidences {
+		errMsg := s.Error
+		if errMsg == "" {
+			errMsg = "-"
+		}
+		state := s.Status
+		if s.PausedAt != nil {
+			state += " (paused)"
+		}
+		schedule := s.Schedule
+		if schedule == "" {
+			schedule = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			s.Evidence, state, schedule, formatTime(s.LastSync), formatTime(s.LastUpdate), s.RowCount, errMsg)
+	}
+	_ = w.Flush()
+}
+
+// runCleanup implements "adapter cleanup [--dry-run] [flags]".
+func runCleanup(args []string) int {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Only report the records that would be removed")
+	cfg, logger, ok := loadConfig(fs, args)
+	if !ok {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newApp(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize adapter", "error", err)
+		return 1
+	}
+	defer a.close()
+
+	if err := a.newCleaner(*dryRun).Run(ctx); err != nil {
+		logger.Error("cleanup failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// runSchema implements "adapter schema diff [flags]". It exits with 1 when
+// any table differs from the Flexibee properties of its evidence.
+func runSchema(args []string) int {
+	if len(args) == 0 || args[0] != "diff" {
+		fmt.Fprint(os.Stderr, "Usage: adapter schema diff [flags]\n")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("schema diff", flag.ExitOnError)
+	cfg, logger, ok := loadConfig(fs, args[1:])
+	if !ok {
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newApp(ctx, cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize adapter", "error", err)
+		return 1
+	}
+	defer a.close()
+
+	pg, ok := a.store.(*store.Store)
+	if !ok {
+		logger.Error("schema diff requires PostgreSQL")
+		return 1
+	}
+
+	var diffs []store.ColumnDiff
+	failed := false
+	for _, ev := range a.registry.All() {
+		props, err := a.client.FetchEvidenceProperties(ctx, ev.Slug)
+		if err != nil {
+			logger.Error("failed to fetch properties", "evidence", ev.Slug, "error", err)
+			failed = true
+			continue
+		}
+		tableDiffs, err := pg.DiffTable(ctx, ev.Table, props)
+		if err != nil {
+			logger.Error("failed to compare table", "evidence", ev.Slug, "error", err)
+			failed = true
+			continue
+		}
+		diffs = append(diffs, tableDiffs...)
+	}
+
+	if len(diffs) == 0 {
+		logger.Info("all tables match the Flexibee properties")
+	} else {
+		printSchemaDiff(diffs)
+	}
+	if failed || len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printSchemaDiff(diffs []store.ColumnDiff) {
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tCOLUMN\tFLEXIBEE\tPOSTGRESQL")
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Table, d.Column, orDash(d.Expected), orDash(d.Actual))
+	}
+	_ = w.Flush()
+}
+
+// splitSlugs splits a comma-separated list of evidence slugs.
+func splitSlugs(s string) []string {
+	var slugs []string
+	for _, slug := range strings.Split(s, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/export"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// runExport implements "adapter export [flags]".
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "export", "Directory to write Parquet files to")
+	source := fs.String("source", "postgres", "Where to read records from: postgres or flexibee")
+	incremental := fs.Bool("incremental", false, "Export only records changed since the previous export; readers keep the newest _exported_at per id")
+	evidences := fs.String("evidences", "", "Comma-separated evidences to export (default all)")
+	batchSize := fs.Int("batch-size", 1000, "Records per page read from the source")
+	databaseURL := fs.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL connection URL (postgres source)")
+	flexibeeURL := fs.String("flexibee-url", os.Getenv("FLEXIBEE_URL"), "Flexibee base URL (flexibee source)")
+	company := fs.String("flexibee-company", os.Getenv("FLEXIBEE_COMPANY"), "Flexibee company code (flexibee source)")
+	username := fs.String("flexibee-username", os.Getenv("FLEXIBEE_USERNAME"), "Flexibee username (flexibee source)")
+	password := fs.String("flexibee-password", os.Getenv("FLEXIBEE_PASSWORD"), "Flexibee password (flexibee source)")
+	_ = fs.Parse(args)
+
+	logger := setupLogger("info", "text")
+
+	if *batchSize <= 0 {
+		logger.Error("batch size must be positive")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	reg := registry.NewDefault()
+	if *evidences != "" {
+		selected := registry.New()
+		for _, slug := range strings.Split(*evidences, ",") {
+			ev, ok := reg.Get(strings.TrimSpace(slug))
+			if !ok {
+				logger.Error("unknown evidence", "evidence", slug)
+				return 2
+			}
+			selected.Register(ev)
+		}
+		reg = selected
+	}
+
+	var src export.Source
+	switch *source {
+	case "postgres":
+		if *databaseURL == "" {
+			logger.Error("database URL is required (DATABASE_URL or --database-url)")
+			return 1
+		}
+		st, err := store.NewStore(ctx, *databaseURL, logger)
+		if err != nil {
+			logger.Error("failed to connect to database", "error", err)
+			return 1
+		}
+		defer st.Close()
+		src = export.NewPostgresSource(st.Pool(), *batchSize)
+	case "flexibee":
+		if *flexibeeURL == "" || *company == "" || *username == "" || *password == "" {
+			logger.Error("Flexibee URL, company, username and password are required for the flexibee source")
+			return 1
+		}
+		client := flexibee.NewClient(*flexibeeURL, *company, *username, *password, logger)
+		src = export.NewFlexibeeSource(client, *batchSize)
+	default:
+		logger.Error("unknown export source (expected postgres or flexibee)", "source", *source)
+		return 2
+	}
+
+	exporter := export.NewExporter(src, reg, export.Config{
+		OutputDir:   *output,
+		Incremental: *incremental,
+	}, logger)
+	if err := exporter.Run(ctx); err != nil {
+		logger.Error("export failed", "error", err)
+		return 1
+	}
+
+	return 0
+}
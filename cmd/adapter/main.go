@@ -2,26 +2,75 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 
 	"github.com/anaryk/metabase-flexibee-adapter/internal/config"
-	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
-	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/tracing"
 )
 
+const usage = `Usage: adapter [command] [flags]
+
+Commands:
+  run          sync continuously (default)
+  sync-once    run a single sync pass and exit
+  resync       reset the sync state of evidences and sync them again
+  status       print the sync state of every evidence
+  cleanup      remove records past the retention period
+  schema diff  compare Flexibee properties with PostgreSQL columns
+  migrate      show, apply or revert database migrations
+  export       export evidences to Parquet files
+
+Run "adapter <command> -h" for the flags of a command.
+`
+
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		slog.Error("failed to load config", "error", err)
-		os.Exit(1)
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "run":
+		os.Exit(runDaemon(args))
+	case "sync-once":
+		os.Exit(runSyncOnce(args))
+	case "resync":
+		os.Exit(runResync(args))
+	case "status":
+		os.Exit(runStatus(args))
+	case "cleanup":
+		os.Exit(runCleanup(args))
+	case "schema":
+		os.Exit(runSchema(args))
+	case "migrate":
+		os.Exit(runMigrate(args))
+	case "export":
+		os.Exit(runExport(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
+}
 
-	logger := setupLogger(cfg.LogLevel, cfg.LogFormat)
+// runDaemon implements "adapter run [flags]", syncing until interrupted.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cfg, logger, ok := loadConfig(fs, args)
+	if !ok {
+		return 1
+	}
 
 	logger.Info("starting metabase-flexibee-adapter",
 		"flexibee_url", cfg.FlexibeeURL,
@@ -34,47 +83,73 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// Initialize Flexibee client
-	client := flexibee.NewClient(
-		cfg.FlexibeeURL,
-		cfg.FlexibeeCompany,
-		cfg.FlexibeeUsername,
-		cfg.FlexibeePassword,
-		logger,
-	)
-
-	// Initialize PostgreSQL store
-	st, err := store.NewStore(ctx, cfg.DatabaseURL, logger)
+	a, err := newApp(ctx, cfg, logger)
 	if err != nil {
-		logger.Error("failed to connect to database", "error", err)
-		os.Exit(1)
+		logger.Error("failed to initialize adapter", "error", err)
+		return 1
+	}
+	defer a.close()
+
+	if err := a.store.RegisterMetrics(); err != nil {
+		logger.Warn("failed to register database metrics", "error", err)
+	}
+
+	engine := a.newEngine()
+
+	// A failing HTTP server stops the adapter rather than leaving it
+	// running unobserved.
+	serverErr := make(chan error, 1)
+	if cfg.HTTPAddr != "" {
+		go func() {
+			err := serveHTTP(ctx, cfg, engine, logger)
+			if err != nil {
+				stop()
+			}
+			serverErr <- err
+		}()
+	} else {
+		serverErr <- nil
 	}
-	defer st.Close()
-
-	// Initialize evidence registry
-	reg := registry.NewDefault()
-	logger.Info("registered evidence types", "count", reg.Len())
-
-	// Initialize cleanup
-	cleaner := adaptersync.NewCleaner(st, reg, adaptersync.CleanupConfig{
-		RetentionDays: cfg.RetentionDays,
-		BatchSize:     cfg.CleanupBatchSize,
-	}, logger)
-
-	// Initialize and start sync engine
-	engine := adaptersync.NewEngine(client, st, reg, cleaner, adaptersync.EngineConfig{
-		SyncInterval:    cfg.SyncInterval,
-		CleanupInterval: cfg.CleanupInterval,
-		BatchSize:       cfg.SyncBatchSize,
-		Concurrency:     cfg.SyncConcurrency,
-	}, logger)
 
+	code := 0
 	if err := engine.Start(ctx); err != nil {
 		logger.Error("engine stopped with error", "error", err)
-		os.Exit(1)
+		code = 1
+	}
+	stop()
+	if err := <-serverErr; err != nil {
+		logger.Error("HTTP server failed", "error", err)
+		code = 1
+	}
+
+	if code == 0 {
+		logger.Info("adapter stopped gracefully")
+	}
+	return code
+}
+
+// rowSecurityRules converts the configured role -> rule mapping into
+// rules, ordered by role.
+func rowSecurityRules(m map[string]config.RowSecurityRule) []store.RowSecurityRule {
+	rules := make([]store.RowSecurityRule, 0, len(m))
+	for _, role := range slices.Sorted(maps.Keys(m)) {
+		rules = append(rules, store.RowSecurityRule{Role: role, Companies: m[role].Companies, CostCenters: m[role].CostCenters})
 	}
+	return rules
+}
 
-	logger.Info("adapter stopped gracefully")
+// unfilteredTables returns the tables of the evidences restricted roles may
+// read in full.
+func unfilteredTables(reg *registry.Registry, slugs []string) ([]string, error) {
+	tables := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		ev, ok := reg.Get(slug)
+		if !ok {
+			return nil, fmt.Errorf("unknown evidence %s in row security configuration", slug)
+		}
+		tables = append(tables, ev.Table)
+	}
+	return tables, nil
 }
 
 func setupLogger(level, format string) *slog.Logger {
@@ -99,5 +174,6 @@ func setupLogger(level, format string) *slog.Logger {
 		handler = slog.NewJSONHandler(os.Stdout, opts)
 	}
 
-	return slog.New(handler)
+	// Records logged within a span carry its trace id.
+	return slog.New(tracing.NewLogHandler(handler))
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// runMigrate implements "adapter migrate [status|up|down] [flags]".
+func runMigrate(args []string) int {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	databaseURL := fs.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL connection URL, mysql:// URL or sqlite:// path")
+	adminURL := fs.String("database-admin-url", os.Getenv("DATABASE_ADMIN_URL"), "PostgreSQL URL of a privileged user to run migrations as")
+	readerRole := fs.String("database-reader-role", os.Getenv("DATABASE_READER_ROLE"), "PostgreSQL role granted read access to all adapter tables")
+	steps := fs.Int("steps", 1, "Number of migrations to revert (down only)")
+	_ = fs.Parse(args)
+
+	logger := setupLogger("info", "text")
+
+	if *databaseURL == "" {
+		logger.Error("database URL is required (DATABASE_URL or --database-url)")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	st, err := store.Open(ctx, *databaseURL, store.Options{
+		AdminURL:   *adminURL,
+		ReaderRole: *readerRole,
+	}, logger)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+	defer st.Close()
+
+	switch action {
+	case "status":
+		statuses, err := st.MigrationStatus(ctx)
+		if err != nil {
+			logger.Error("failed to read migration status", "error", err)
+			return 1
+		}
+		printMigrationStatus(statuses)
+	case "up":
+		if err := st.RunMigrations(ctx); err != nil {
+			logger.Error("migration failed", "error", err)
+			return 1
+		}
+	case "down":
+		if *steps <= 0 {
+			logger.Error("steps must be positive")
+			return 1
+		}
+		if err := st.MigrateDown(ctx, *steps); err != nil {
+			logger.Error("migration failed", "error", err)
+			return 1
+		}
+	default:
+		logger.Error("unknown migrate action (expected status, up or down)", "action", action)
+		return 2
+	}
+
+	return 0
+}
+
+func printMigrationStatus(statuses []store.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, st := range statuses {
+		status, appliedAt := "pending", "-"
+		if st.Applied {
+			status = "applied"
+			appliedAt = st.AppliedAt.Format(time.RFC3339)
+		}
+		if st.Modified {
+			status = "modified"
+		}
+		_, _ = fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", st.Version, st.Name, status, appliedAt)
+	}
+	_ = w.Flush()
+}
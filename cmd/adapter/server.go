@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/admin"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/config"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+// serveHTTP serves the adapter's HTTP endpoints on cfg.HTTPAddr until ctx
+// is done. The admin API is served only with an admin token.
+func serveHTTP(ctx context.Context, cfg *config.Config, engine *adaptersync.Engine, logger *slog.Logger) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, engine.Alive(cfg.LivenessTimeout), logger)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		writeProbe(w, engine.Ready(ctx, cfg.ReadyMaxSyncAge), logger)
+	})
+	if cfg.AdminToken != "" {
+		mux.Handle("/admin/", admin.NewHandler(engine, cfg.AdminToken, logger))
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to shut down HTTP server", "error", err)
+		}
+	}()
+
+	logger.Info("serving HTTP", "addr", cfg.HTTPAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// writeProbe answers a health probe with 200 and "ok", or with 503 and
+// the reason the check failed.
+func writeProbe(w http.ResponseWriter, err error, logger *slog.Logger) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		logger.Warn("health check failed", "error", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprintln(w, err)
+		return
+	}
+	_, _ = fmt.Fprintln(w, "ok")
+}
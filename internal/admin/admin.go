@@ -0,0 +1,228 @@
+// Package admin serves the admin API controlling the sync engine: listing
+// evidences, triggering syncs, resyncs and the cleanup, and pausing
+// evidences.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+// Engine is the part of the sync engine the admin API controls.
+type Engine interface {
+	Evidences(ctx context.Context) ([]adaptersync.EvidenceStatus, error)
+	Running() []adaptersync.Activity
+	TriggerSync(ctx context.Context, slug string) error
+	TriggerResync(ctx context.Context, slug string, since *time.Time) error
+	TriggerSyncAll() error
+	TriggerResyncAll(since *time.Time) error
+	TriggerCleanup() error
+	Pause(ctx context.Context, slug string) error
+	Resume(ctx context.Context, slug string) error
+}
+
+var _ Engine = (*adaptersync.Engine)(nil)
+
+// NewHandler returns the admin API handler, serving paths under /admin/.
+// Requests must carry token as a bearer token.
+func NewHandler(engine Engine, token string, logger *slog.Logger) http.Handler {
+	h := &handler{engine: engine, logger: logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/evidences", h.listEvidences)
+	mux.HandleFunc("GET /admin/running", h.listRunning)
+	mux.HandleFunc("POST /admin/sync", h.syncAll)
+	mux.HandleFunc("POST /admin/resync", h.resyncAll)
+	mux.HandleFunc("POST /admin/cleanup", h.cleanup)
+	mux.HandleFunc("POST /admin/evidences/{slug}/sync", h.sync)
+	mux.HandleFunc("POST /admin/evidences/{slug}/resync", h.resync)
+	mux.HandleFunc("POST /admin/evidences/{slug}/pause", h.pause)
+	mux.HandleFunc("POST /admin/evidences/{slug}/resume", h.resume)
+
+	return authenticate(mux, token)
+}
+
+// authenticate rejects requests without the bearer token.
+func authenticate(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type handler struct {
+	engine Engine
+	logger *slog.Logger
+}
+
+// evidenceResponse is an evidence in GET /admin/evidences.
+type evidenceResponse struct {
+	Evidence   string            `json:"evidence"`
+	Table      string            `json:"table"`
+	Schedule   string            `json:"schedule,omitempty"`
+	Status     string            `json:"status"`
+	LastSync   *time.Time        `json:"last_sync,omitempty"`
+	LastUpdate *time.Time        `json:"last_update,omitempty"`
+	RowCount   int64             `json:"row_count"`
+	Error      string            `json:"error,omitempty"`
+	PausedAt   *time.Time        `json:"paused_at,omitempty"`
+	Running    *activityResponse `json:"running,omitempty"`
+}
+
+// activityResponse is a sync or cleanup being run.
+type activityResponse struct {
+	Name    string    `json:"name"`
+	Trigger string    `json:"trigger"`
+	Since   time.Time `json:"since"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *handler) listEvidences(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.engine.Evidences(r.Context())
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	evidences := make([]evidenceResponse, 0, len(statuses))
+	for _, s := range statuses {
+		ev := evidenceResponse{
+			Evidence: s.Evidence.Slug,
+			Table:    s.Evidence.Table,
+			Schedule: s.Evidence.Schedule,
+			Status:   "never synced",
+			PausedAt: s.PausedAt,
+		}
+		if st := s.State; st != nil {
+			ev.Status, ev.LastSync, ev.LastUpdate, ev.RowCount, ev.Error = st.Status, &st.LastSync, st.LastUpdate, st.RowCount, st.ErrorMsg
+		}
+		if a := s.Running; a != nil {
+			ev.Running = &activityResponse{Name: a.Name, Trigger: a.Trigger, Since: a.Since}
+		}
+		evidences = append(evidences, ev)
+	}
+	writeJSON(w, http.StatusOK, evidences)
+}
+
+func (h *handler) listRunning(w http.ResponseWriter, _ *http.Request) {
+	running := h.engine.Running()
+	activities := make([]activityResponse, 0, len(running))
+	for _, a := range running {
+		activities = append(activities, activityResponse{Name: a.Name, Trigger: a.Trigger, Since: a.Since})
+	}
+	writeJSON(w, http.StatusOK, activities)
+}
+
+func (h *handler) syncAll(w http.ResponseWriter, _ *http.Request) {
+	h.logger.Info("admin: sync of all evidences requested")
+	h.writeStarted(w, h.engine.TriggerSyncAll())
+}
+
+func (h *handler) resyncAll(w http.ResponseWriter, r *http.Request) {
+	since, ok := h.since(w, r)
+	if !ok {
+		return
+	}
+	h.logger.Info("admin: resync of all evidences requested", "since", since)
+	h.writeStarted(w, h.engine.TriggerResyncAll(since))
+}
+
+func (h *handler) cleanup(w http.ResponseWriter, _ *http.Request) {
+	h.logger.Info("admin: cleanup requested")
+	h.writeStarted(w, h.engine.TriggerCleanup())
+}
+
+func (h *handler) sync(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	h.logger.Info("admin: sync requested", "evidence", slug)
+	h.writeStarted(w, h.engine.TriggerSync(r.Context(), slug))
+}
+
+func (h *handler) resync(w http.ResponseWriter, r *http.Request) {
+	since, ok := h.since(w, r)
+	if !ok {
+		return
+	}
+	slug := r.PathValue("slug")
+	h.logger.Info("admin: resync requested", "evidence", slug, "since", since)
+	h.writeStarted(w, h.engine.TriggerResync(r.Context(), slug, since))
+}
+
+func (h *handler) pause(w http.ResponseWriter, r *http.Request) {
+	if err := h.engine.Pause(r.Context(), r.PathValue("slug")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusResponse{Status: "paused"})
+}
+
+func (h *handler) resume(w http.ResponseWriter, r *http.Request) {
+	if err := h.engine.Resume(r.Context(), r.PathValue("slug")); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusResponse{Status: "resumed"})
+}
+
+// since parses the optional since=YYYY-MM-DD query parameter of resyncs.
+func (h *handler) since(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return nil, true
+	}
+	t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid since date (expected YYYY-MM-DD)"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// writeStarted answers a request starting a background run.
+func (h *handler) writeStarted(w http.ResponseWriter, err error) {
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, statusResponse{Status: "started"})
+}
+
+// writeError answers with the status matching err.
+func (h *handler) writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, adaptersync.ErrUnknownEvidence):
+		status = http.StatusNotFound
+	case errors.Is(err, adaptersync.ErrPaused), errors.Is(err, adaptersync.ErrNotRunning), errors.Is(err, adaptersync.ErrBusy):
+		status = http.StatusConflict
+	default:
+		h.logger.Error("admin request failed", "error", err)
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,171 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+// fakeEngine records the requested runs.
+type fakeEngine struct {
+	triggered []string
+	since     *time.Time
+	err       error
+}
+
+func (f *fakeEngine) Evidences(context.Context) ([]adaptersync.EvidenceStatus, error) {
+	pausedAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	return []adaptersync.EvidenceStatus{
+		{
+			Evidence: registry.Evidence{Slug: "banka", Table: "flexibee_banka"},
+			State:    &store.SyncState{Status: "ok", RowCount: 42},
+			Running:  &adaptersync.Activity{Name: "banka", Trigger: adaptersync.TriggerManual},
+		},
+		{Evidence: registry.Evidence{Slug: "adresar", Table: "flexibee_adresar"}, PausedAt: &pausedAt},
+	}, nil
+}
+
+func (f *fakeEngine) Running() []adaptersync.Activity {
+	return []adaptersync.Activity{{Name: "cleanup", Trigger: adaptersync.TriggerScheduled}}
+}
+
+func (f *fakeEngine) TriggerSync(_ context.Context, slug string) error {
+	f.triggered = append(f.triggered, "sync "+slug)
+	return f.err
+}
+
+func (f *fakeEngine) TriggerResync(_ context.Context, slug string, since *time.Time) error {
+	f.triggered, f.since = append(f.triggered, "resync "+slug), since
+	return f.err
+}
+
+func (f *fakeEngine) TriggerSyncAll() error {
+	f.triggered = append(f.triggered, "sync all")
+	return f.err
+}
+
+func (f *fakeEngine) TriggerResyncAll(since *time.Time) error {
+	f.triggered, f.since = append(f.triggered, "resync all"), since
+	return f.err
+}
+
+func (f *fakeEngine) TriggerCleanup() error {
+	f.triggered = append(f.triggered, "cleanup")
+	return f.err
+}
+
+func (f *fakeEngine) Pause(_ context.Context, slug string) error {
+	f.triggered = append(f.triggered, "pause "+slug)
+	return f.err
+}
+
+func (f *fakeEngine) Resume(_ context.Context, slug string) error {
+	f.triggered = append(f.triggered, "resume "+slug)
+	return f.err
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func do(t *testing.T, h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	t.Parallel()
+
+	engine := &fakeEngine{}
+	h := NewHandler(engine, "secret", discardLogger)
+
+	assert.Equal(t, http.StatusUnauthorized, do(t, h, "POST", "/admin/sync", "").Code)
+	rec := do(t, h, "POST", "/admin/sync", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Empty(t, engine.triggered)
+
+	assert.Equal(t, http.StatusAccepted, do(t, h, "POST", "/admin/sync", "secret").Code)
+	assert.Equal(t, []string{"sync all"}, engine.triggered)
+}
+
+func TestHandler_ListsEvidences(t *testing.T) {
+	t.Parallel()
+
+	h := NewHandler(&fakeEngine{}, "secret", discardLogger)
+	rec := do(t, h, "GET", "/admin/evidences", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []evidenceResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 2)
+	assert.Equal(t, "ok", got[0].Status)
+	assert.Equal(t, int64(42), got[0].RowCount)
+	require.NotNil(t, got[0].Running)
+	assert.Equal(t, adaptersync.TriggerManual, got[0].Running.Trigger)
+	assert.Equal(t, "never synced", got[1].Status)
+	assert.NotNil(t, got[1].PausedAt)
+
+	rec = do(t, h, "GET", "/admin/running", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"cleanup"`)
+}
+
+func TestHandler_TriggersRuns(t *testing.T) {
+	t.Parallel()
+
+	engine := &fakeEngine{}
+	h := NewHandler(engine, "secret", discardLogger)
+
+	assert.Equal(t, http.StatusAccepted, do(t, h, "POST", "/admin/evidences/banka/sync", "secret").Code)
+	assert.Equal(t, http.StatusAccepted, do(t, h, "POST", "/admin/evidences/banka/resync?since=2025-01-31", "secret").Code)
+	require.NotNil(t, engine.since)
+	assert.Equal(t, "2025-01-31", engine.since.Format(time.DateOnly))
+	assert.Equal(t, http.StatusAccepted, do(t, h, "POST", "/admin/resync", "secret").Code)
+	assert.Nil(t, engine.since)
+	assert.Equal(t, http.StatusAccepted, do(t, h, "POST", "/admin/cleanup", "secret").Code)
+	assert.Equal(t, http.StatusOK, do(t, h, "POST", "/admin/evidences/banka/pause", "secret").Code)
+	assert.Equal(t, http.StatusOK, do(t, h, "POST", "/admin/evidences/banka/resume", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, do(t, h, "POST", "/admin/resync?since=yesterday", "secret").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(t, h, "GET", "/admin/cleanup", "secret").Code)
+
+	assert.Equal(t, []string{
+		"sync banka", "resync banka", "resync all", "cleanup", "pause banka", "resume banka",
+	}, engine.triggered)
+}
+
+func TestHandler_MapsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{adaptersync.ErrUnknownEvidence, http.StatusNotFound},
+		{adaptersync.ErrPaused, http.StatusConflict},
+		{adaptersync.ErrNotRunning, http.StatusConflict},
+		{adaptersync.ErrBusy, http.StatusConflict},
+		{assert.AnError, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		h := NewHandler(&fakeEngine{err: tt.err}, "secret", discardLogger)
+		rec := do(t, h, "POST", "/admin/evidences/banka/sync", "secret")
+		assert.Equal(t, tt.want, rec.Code, tt.err)
+		assert.Contains(t, rec.Body.String(), tt.err.Error())
+	}
+}
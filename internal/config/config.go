@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,43 +17,137 @@ type Config struct {
 	FlexibeeUsername string
 	FlexibeePassword string
 
-	// PostgreSQL
-	DatabaseURL string
+	// Database
+	DatabaseURL           string
+	DatabaseAdminURL      string                     // migrations and DDL run as this user when set
+	DatabaseReaderRole    string                     // role granted SELECT on everything the adapter creates
+	RowSecurity           map[string]RowSecurityRule // role -> allowed companies and cost centers
+	RowSecurityUnfiltered []string                   // evidences without a cost center readable by restricted roles
 
 	// Sync
 	SyncInterval    time.Duration
 	SyncBatchSize   int
 	SyncConcurrency int
+	SyncSchedules   map[string]string // evidence slug -> interval or cron expression
+	SyncTimezone    string            // time zone of cron schedules ("" = local)
 
 	// Cleanup / Data Retention
-	RetentionDays    int
-	CleanupInterval  time.Duration
-	CleanupBatchSize int
+	RetentionDays         int
+	CleanupInterval       time.Duration
+	CleanupBatchSize      int
+	SyncRunsRetentionDays int
+
+	// History (SCD type 2) tables
+	HistoryEvidences []string
+
+	// Date partitioning
+	PartitionEvidences   []string
+	PartitionMonthsAhead int
+
+	// Indexes
+	IndexRawData bool
+	ExtraIndexes map[string][][]string // evidence slug -> index columns
+
+	// Sync order
+	EvidencePriority map[string]int // evidence slug -> priority
+
+	// Reconciliation
+	ReconcileInterval time.Duration // 0 disables reconciliation
+	ReconcileMonths   int
+
+	// Leader election among replicas
+	LeaderElection bool
+	LeaderLease    time.Duration
+	LeaderID       string // lease holder ("" = host name)
+
+	// Curated views
+	CuratedViews     bool
+	MaterializeViews bool
+
+	// Star schema
+	StarSchema bool
+
+	// Metabase metadata integration (disabled when MetabaseURL is empty)
+	MetabaseURL         string
+	MetabaseAPIKey      string
+	MetabaseDatabase    string // database name in Metabase
+	MetabaseDatabaseURL string // how Metabase reaches the database, defaults to DatabaseURL
+
+	// HTTP server for /metrics, /healthz and /readyz ("" = disabled)
+	HTTPAddr        string
+	LivenessTimeout time.Duration // engine stall after which /healthz fails
+	ReadyMaxSyncAge time.Duration // sync age after which /readyz fails (0 = unchecked)
+	AdminToken      string        // bearer token of the admin API ("" = disabled)
+
+	// Tracing (disabled when OTLPEndpoint is empty)
+	OTLPEndpoint string
 
 	// Logging
 	LogLevel  string
 	LogFormat string
 }
 
-func Load() (*Config, error) {
+// RowSecurityRule lists what a restricted database role may read.
+type RowSecurityRule struct {
+	Companies   []string // Flexibee company codes (nil = any company)
+	CostCenters []string // cost center codes (nil = all rows)
+}
+
+// Load registers the configuration flags on fs, parses args and applies
+// environment variables. Subcommands define their own flags on fs before
+// calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{}
+	var historyEvidences, partitionEvidences, extraIndexes, rowSecurity, rowSecurityUnfiltered, evidencePriority, syncSchedules string
 
 	// Define flags with defaults
-	flag.StringVar(&cfg.FlexibeeURL, "flexibee-url", "", "Flexibee base URL")
-	flag.StringVar(&cfg.FlexibeeCompany, "flexibee-company", "", "Flexibee company code")
-	flag.StringVar(&cfg.FlexibeeUsername, "flexibee-username", "", "Flexibee username")
-	flag.StringVar(&cfg.FlexibeePassword, "flexibee-password", "", "Flexibee password")
-	flag.StringVar(&cfg.DatabaseURL, "database-url", "", "PostgreSQL connection URL")
-	flag.DurationVar(&cfg.SyncInterval, "sync-interval", 5*time.Minute, "Sync interval")
-	flag.IntVar(&cfg.SyncBatchSize, "sync-batch-size", 100, "Records per page when fetching")
-	flag.IntVar(&cfg.SyncConcurrency, "sync-concurrency", 4, "Max concurrent evidence syncs")
-	flag.IntVar(&cfg.RetentionDays, "retention-days", 365, "Data retention in days (0=disabled)")
-	flag.DurationVar(&cfg.CleanupInterval, "cleanup-interval", 24*time.Hour, "Cleanup check interval")
-	flag.IntVar(&cfg.CleanupBatchSize, "cleanup-batch-size", 1000, "Rows to delete per batch")
-	flag.StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
-	flag.StringVar(&cfg.LogFormat, "log-format", "json", "Log format (json, text)")
-
-	flag.Parse()
+	fs.StringVar(&cfg.FlexibeeURL, "flexibee-url", "", "Flexibee base URL")
+	fs.StringVar(&cfg.FlexibeeCompany, "flexibee-company", "", "Flexibee company code")
+	fs.StringVar(&cfg.FlexibeeUsername, "flexibee-username", "", "Flexibee username")
+	fs.StringVar(&cfg.FlexibeePassword, "flexibee-password", "", "Flexibee password")
+	fs.StringVar(&cfg.DatabaseURL, "database-url", "", "PostgreSQL connection URL, mysql:// URL or sqlite:// path")
+	fs.StringVar(&cfg.DatabaseAdminURL, "database-admin-url", "", "PostgreSQL URL of a privileged user for migrations and DDL")
+	fs.StringVar(&cfg.DatabaseReaderRole, "database-reader-role", "", "PostgreSQL role granted read access to all adapter tables")
+	fs.StringVar(&rowSecurity, "row-security", "", "Row-level security rules as role[@COMPANY1+COMPANY2]:COSTCENTER1+COSTCENTER2 or role[@COMPANY]:*, comma-separated")
+	fs.StringVar(&rowSecurityUnfiltered, "row-security-unfiltered", "", "Comma-separated evidences without a cost center that restricted roles may read in full")
+	fs.DurationVar(&cfg.SyncInterval, "sync-interval", 5*time.Minute, "Sync interval")
+	fs.IntVar(&cfg.SyncBatchSize, "sync-batch-size", 100, "Records per page when fetching")
+	fs.IntVar(&cfg.SyncConcurrency, "sync-concurrency", 4, "Max concurrent evidence syncs")
+	fs.StringVar(&syncSchedules, "sync-schedules", "", "Per-evidence sync schedules as evidence:interval or evidence:cron, semicolon-separated")
+	fs.StringVar(&cfg.SyncTimezone, "sync-timezone", "", "Time zone of cron schedules (default local time)")
+	fs.IntVar(&cfg.RetentionDays, "retention-days", 365, "Data retention in days (0=disabled)")
+	fs.DurationVar(&cfg.CleanupInterval, "cleanup-interval", 24*time.Hour, "Cleanup check interval")
+	fs.IntVar(&cfg.CleanupBatchSize, "cleanup-batch-size", 1000, "Rows to delete per batch")
+	fs.IntVar(&cfg.SyncRunsRetentionDays, "sync-runs-retention-days", 90, "Days to keep the sync run history (0=forever)")
+	fs.StringVar(&historyEvidences, "history-evidences", "", "Comma-separated evidence slugs to keep change history for")
+	fs.StringVar(&partitionEvidences, "partition-evidences", "", "Comma-separated evidence slugs to partition by month")
+	fs.IntVar(&cfg.PartitionMonthsAhead, "partition-months-ahead", 3, "Monthly partitions to create ahead of time")
+	fs.BoolVar(&cfg.IndexRawData, "index-raw-data", false, "Create GIN indexes on raw_data")
+	fs.StringVar(&extraIndexes, "extra-indexes", "", "Extra indexes as evidence:col1+col2, comma-separated")
+	fs.StringVar(&evidencePriority, "evidence-priority", "", "Sync priorities as evidence:N, comma-separated (higher syncs first)")
+	fs.DurationVar(&cfg.ReconcileInterval, "reconcile-interval", 24*time.Hour, "Interval between reconciliations against Flexibee (0 to disable)")
+	fs.IntVar(&cfg.ReconcileMonths, "reconcile-months", 12, "Monthly periods reconciled per evidence")
+	fs.BoolVar(&cfg.LeaderElection, "leader-election", false, "Sync only while holding the leader lease, for running several replicas")
+	fs.DurationVar(&cfg.LeaderLease, "leader-lease", 30*time.Second, "Duration of the leader lease; the leader renews it every third of it")
+	fs.StringVar(&cfg.LeaderID, "leader-id", "", "Name of this replica in the leader lease (default host name)")
+	fs.BoolVar(&cfg.CuratedViews, "curated-views", true, "Maintain curated Metabase-friendly views")
+	fs.BoolVar(&cfg.MaterializeViews, "materialize-views", false, "Build curated views as materialized views refreshed after every sync")
+	fs.BoolVar(&cfg.StarSchema, "star-schema", false, "Maintain dimension and fact tables after every sync")
+	fs.StringVar(&cfg.MetabaseURL, "metabase-url", "", "Metabase URL for automatic metadata setup")
+	fs.StringVar(&cfg.MetabaseAPIKey, "metabase-api-key", "", "Metabase API key")
+	fs.StringVar(&cfg.MetabaseDatabase, "metabase-database", "Flexibee", "Database name in Metabase")
+	fs.StringVar(&cfg.MetabaseDatabaseURL, "metabase-database-url", "", "Database URL as reachable from Metabase (default DATABASE_URL)")
+	fs.StringVar(&cfg.HTTPAddr, "http-addr", ":8080", "Listen address of the HTTP server serving /metrics, /healthz and /readyz (empty to disable)")
+	fs.DurationVar(&cfg.LivenessTimeout, "liveness-timeout", 15*time.Minute, "Time without engine progress after which /healthz fails")
+	fs.DurationVar(&cfg.ReadyMaxSyncAge, "ready-max-sync-age", time.Hour, "Age of the last successful sync of any evidence after which /readyz fails (0 to disable)")
+	fs.StringVar(&cfg.AdminToken, "admin-token", "", "Bearer token enabling the admin API under /admin/")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector URL spans are exported to, e.g. http://otel-collector:4318")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	fs.StringVar(&cfg.LogFormat, "log-format", "json", "Log format (json, text)")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
 	// Env vars override flags only when the flag was not explicitly set
 	applyEnv(&cfg.FlexibeeURL, "FLEXIBEE_URL")
@@ -60,15 +155,66 @@ func Load() (*Config, error) {
 	applyEnv(&cfg.FlexibeeUsername, "FLEXIBEE_USERNAME")
 	applyEnv(&cfg.FlexibeePassword, "FLEXIBEE_PASSWORD")
 	applyEnv(&cfg.DatabaseURL, "DATABASE_URL")
+	applyEnv(&cfg.DatabaseAdminURL, "DATABASE_ADMIN_URL")
+	applyEnv(&cfg.DatabaseReaderRole, "DATABASE_READER_ROLE")
+	applyEnv(&rowSecurity, "ROW_SECURITY")
+	applyEnv(&rowSecurityUnfiltered, "ROW_SECURITY_UNFILTERED")
 	applyEnvDuration(&cfg.SyncInterval, "SYNC_INTERVAL")
 	applyEnvInt(&cfg.SyncBatchSize, "SYNC_BATCH_SIZE")
 	applyEnvInt(&cfg.SyncConcurrency, "SYNC_CONCURRENCY")
+	applyEnv(&syncSchedules, "SYNC_SCHEDULES")
+	applyEnv(&cfg.SyncTimezone, "SYNC_TIMEZONE")
 	applyEnvInt(&cfg.RetentionDays, "RETENTION_DAYS")
 	applyEnvDuration(&cfg.CleanupInterval, "CLEANUP_INTERVAL")
 	applyEnvInt(&cfg.CleanupBatchSize, "CLEANUP_BATCH_SIZE")
+	applyEnvInt(&cfg.SyncRunsRetentionDays, "SYNC_RUNS_RETENTION_DAYS")
+	applyEnv(&historyEvidences, "HISTORY_EVIDENCES")
+	applyEnv(&partitionEvidences, "PARTITION_EVIDENCES")
+	applyEnvInt(&cfg.PartitionMonthsAhead, "PARTITION_MONTHS_AHEAD")
+	applyEnvBool(&cfg.IndexRawData, "INDEX_RAW_DATA")
+	applyEnv(&extraIndexes, "EXTRA_INDEXES")
+	applyEnv(&evidencePriority, "EVIDENCE_PRIORITY")
+	applyEnvDuration(&cfg.ReconcileInterval, "RECONCILE_INTERVAL")
+	applyEnvInt(&cfg.ReconcileMonths, "RECONCILE_MONTHS")
+	applyEnvBool(&cfg.LeaderElection, "LEADER_ELECTION")
+	applyEnvDuration(&cfg.LeaderLease, "LEADER_LEASE")
+	applyEnv(&cfg.LeaderID, "LEADER_ID")
+	applyEnvBool(&cfg.CuratedViews, "CURATED_VIEWS")
+	applyEnvBool(&cfg.MaterializeViews, "MATERIALIZE_VIEWS")
+	applyEnvBool(&cfg.StarSchema, "STAR_SCHEMA")
+	applyEnv(&cfg.MetabaseURL, "METABASE_URL")
+	applyEnv(&cfg.MetabaseAPIKey, "METABASE_API_KEY")
+	applyEnv(&cfg.MetabaseDatabase, "METABASE_DATABASE")
+	applyEnv(&cfg.MetabaseDatabaseURL, "METABASE_DATABASE_URL")
+	// HTTP_ADDR may be set to an empty value to disable the server.
+	if v, ok := os.LookupEnv("HTTP_ADDR"); ok {
+		cfg.HTTPAddr = v
+	}
+	applyEnvDuration(&cfg.LivenessTimeout, "LIVENESS_TIMEOUT")
+	applyEnvDuration(&cfg.ReadyMaxSyncAge, "READY_MAX_SYNC_AGE")
+	applyEnv(&cfg.AdminToken, "ADMIN_TOKEN")
+	applyEnv(&cfg.OTLPEndpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
 	applyEnv(&cfg.LogLevel, "LOG_LEVEL")
 	applyEnv(&cfg.LogFormat, "LOG_FORMAT")
 
+	cfg.HistoryEvidences = splitList(historyEvidences)
+	cfg.PartitionEvidences = splitList(partitionEvidences)
+	cfg.RowSecurityUnfiltered = splitList(rowSecurityUnfiltered)
+
+	var err error
+	if cfg.ExtraIndexes, err = parseExtraIndexes(extraIndexes); err != nil {
+		return nil, err
+	}
+	if cfg.RowSecurity, err = parseRowSecurity(rowSecurity); err != nil {
+		return nil, err
+	}
+	if cfg.EvidencePriority, err = parseEvidencePriority(evidencePriority); err != nil {
+		return nil, err
+	}
+	if cfg.SyncSchedules, err = parseSyncSchedules(syncSchedules); err != nil {
+		return nil, err
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -103,9 +249,17 @@ func (c *Config) Validate() error {
 	if c.SyncConcurrency <= 0 {
 		errs = append(errs, fmt.Errorf("sync concurrency must be positive"))
 	}
+	if c.SyncTimezone != "" {
+		if _, err := time.LoadLocation(c.SyncTimezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sync timezone: %w", err))
+		}
+	}
 	if c.RetentionDays < 0 {
 		errs = append(errs, fmt.Errorf("retention days must be non-negative"))
 	}
+	if c.SyncRunsRetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("sync runs retention days must be non-negative"))
+	}
 	if c.CleanupInterval <= 0 {
 		errs = append(errs, fmt.Errorf("cleanup interval must be positive"))
 	}
@@ -113,6 +267,33 @@ func (c *Config) Validate() error {
 		errs = append(errs, fmt.Errorf("cleanup batch size must be positive"))
 	}
 
+	if c.PartitionMonthsAhead < 0 {
+		errs = append(errs, fmt.Errorf("partition months ahead must be non-negative"))
+	}
+
+	if c.ReconcileInterval < 0 {
+		errs = append(errs, fmt.Errorf("reconcile interval must be non-negative"))
+	}
+	if c.ReconcileMonths <= 0 {
+		errs = append(errs, fmt.Errorf("reconcile months must be positive"))
+	}
+	if c.LeaderElection && c.LeaderLease <= 0 {
+		errs = append(errs, fmt.Errorf("leader lease must be positive"))
+	}
+	if c.HTTPAddr != "" && c.LivenessTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("liveness timeout must be positive"))
+	}
+	if c.AdminToken != "" && c.HTTPAddr == "" {
+		errs = append(errs, fmt.Errorf("admin API requires the HTTP server (HTTP_ADDR or --http-addr)"))
+	}
+	if c.ReadyMaxSyncAge < 0 {
+		errs = append(errs, fmt.Errorf("ready max sync age must be non-negative"))
+	}
+
+	if c.MetabaseURL != "" && c.MetabaseAPIKey == "" {
+		errs = append(errs, fmt.Errorf("metabase API key is required with a Metabase URL (METABASE_API_KEY or --metabase-api-key)"))
+	}
+
 	switch c.LogLevel {
 	case "debug", "info", "warn", "error":
 	default:
@@ -149,3 +330,132 @@ func applyEnvInt(dst *int, key string) {
 		}
 	}
 }
+
+func applyEnvBool(dst *bool, key string) {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			*dst = b
+		}
+	}
+}
+
+// parseExtraIndexes parses index declarations such as
+// "faktura-vydana:kod,banka:varSym+datVyst" into index columns per evidence.
+func parseExtraIndexes(v string) (map[string][][]string, error) {
+	result := make(map[string][][]string)
+	for _, item := range splitList(v) {
+		slug, cols, ok := strings.Cut(item, ":")
+		slug = strings.TrimSpace(slug)
+		if !ok || slug == "" {
+			return nil, fmt.Errorf("invalid extra index %q (expected evidence:col1+col2)", item)
+		}
+
+		var columns []string
+		for _, col := range strings.Split(cols, "+") {
+			if col = strings.TrimSpace(col); col != "" {
+				columns = append(columns, col)
+			}
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("invalid extra index %q (no columns)", item)
+		}
+		result[slug] = append(result[slug], columns)
+	}
+	return result, nil
+}
+
+// parseRowSecurity parses row-level security rules such as
+// "manager_praha@firma:PRAHA+BRNO,auditor:*" into a rule per role. The
+// optional "@" part lists the companies the rule applies to. A "*" rule
+// allows all rows and has no cost centers.
+func parseRowSecurity(v string) (map[string]RowSecurityRule, error) {
+	result := make(map[string]RowSecurityRule)
+	for _, item := range splitList(v) {
+		target, codes, ok := strings.Cut(item, ":")
+		role, companies, scoped := strings.Cut(target, "@")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("invalid row security rule %q (expected role[@COMPANY]:CODE1+CODE2 or role[@COMPANY]:*)", item)
+		}
+		if _, dup := result[role]; dup {
+			return nil, fmt.Errorf("duplicate row security rule for role %q", role)
+		}
+
+		var rule RowSecurityRule
+		if scoped {
+			if rule.Companies = splitCodes(companies); len(rule.Companies) == 0 {
+				return nil, fmt.Errorf("invalid row security rule %q (no companies)", item)
+			}
+		}
+		if strings.TrimSpace(codes) != "*" {
+			if rule.CostCenters = splitCodes(codes); len(rule.CostCenters) == 0 {
+				return nil, fmt.Errorf("invalid row security rule %q (no cost centers)", item)
+			}
+		}
+		result[role] = rule
+	}
+	return result, nil
+}
+
+// splitCodes splits a "+"-separated list of codes, dropping empty items.
+func splitCodes(v string) []string {
+	var codes []string
+	for _, code := range strings.Split(v, "+") {
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// parseEvidencePriority parses sync priorities such as
+// "faktura-vydana:10,banka:-5" into a priority per evidence.
+func parseEvidencePriority(v string) (map[string]int, error) {
+	result := make(map[string]int)
+	for _, item := range splitList(v) {
+		slug, priority, ok := strings.Cut(item, ":")
+		slug = strings.TrimSpace(slug)
+		if !ok || slug == "" {
+			return nil, fmt.Errorf("invalid evidence priority %q (expected evidence:N)", item)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(priority))
+		if err != nil {
+			return nil, fmt.Errorf("invalid evidence priority %q: %w", item, err)
+		}
+		result[slug] = n
+	}
+	return result, nil
+}
+
+// parseSyncSchedules parses per-evidence schedules such as
+// "prodejka:* 8-20 * * 1-6;faktura-vydana:5m". Entries are separated by
+// semicolons since cron expressions contain commas.
+func parseSyncSchedules(v string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, item := range strings.Split(v, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		slug, spec, ok := strings.Cut(item, ":")
+		slug, spec = strings.TrimSpace(slug), strings.TrimSpace(spec)
+		if !ok || slug == "" || spec == "" {
+			return nil, fmt.Errorf("invalid sync schedule %q (expected evidence:interval or evidence:cron)", item)
+		}
+		if _, dup := result[slug]; dup {
+			return nil, fmt.Errorf("duplicate sync schedule for evidence %q", slug)
+		}
+		result[slug] = spec
+	}
+	return result, nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
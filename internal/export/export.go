@@ -0,0 +1,237 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+// stateFile records the watermark of every exported evidence in the
+// output directory, for incremental exports.
+const stateFile = "_export_state.json"
+
+// Config controls an export run.
+type Config struct {
+	// OutputDir receives one directory per evidence.
+	OutputDir string
+	// Incremental writes only records changed since the previous export
+	// as new part files next to the existing ones, so a record may occur
+	// in several files; the row with the newest _exported_at is current.
+	// Otherwise every evidence directory is replaced by a full snapshot.
+	Incremental bool
+}
+
+// Exporter writes evidences to Parquet files, one directory per evidence,
+// split into Hive-style month=YYYY-MM directories by the document date.
+type Exporter struct {
+	source   Source
+	registry *registry.Registry
+	config   Config
+	logger   *slog.Logger
+}
+
+// NewExporter creates a new Exporter.
+func NewExporter(src Source, reg *registry.Registry, cfg Config, logger *slog.Logger) *Exporter {
+	return &Exporter{
+		source:   src,
+		registry: reg,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// state is the content of the state file.
+type state struct {
+	Evidences map[string]time.Time `json:"evidences"`
+}
+
+// Run exports every registered evidence. An evidence that fails is logged
+// and skipped; Run then returns an error naming all failed evidences.
+func (e *Exporter) Run(ctx context.Context) error {
+	if err := os.MkdirAll(e.config.OutputDir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	st, err := e.loadState()
+	if err != nil {
+		return err
+	}
+
+	started := time.Now().UTC()
+	runID := started.Format("20060102T150405Z")
+	var failed []string
+
+	for _, ev := range e.registry.All() {
+		var since *time.Time
+		if t, ok := st.Evidences[ev.Slug]; ok && e.config.Incremental {
+			since = &t
+		}
+
+		count, watermark, err := e.exportEvidence(ctx, ev, since, started, runID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e.logger.Error("export failed", "evidence", ev.Slug, "error", err)
+			failed = append(failed, ev.Slug)
+			continue
+		}
+
+		st.Evidences[ev.Slug] = watermark
+		if err := e.saveState(st); err != nil {
+			return err
+		}
+		e.logger.Info("exported evidence", "evidence", ev.Slug, "records", count, "incremental", since != nil)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("export failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// partFile is an open Parquet file of one partition.
+type partFile struct {
+	file   *os.File
+	writer *parquet.Writer
+}
+
+func (e *Exporter) exportEvidence(ctx context.Context, ev registry.Evidence, since *time.Time, started time.Time, runID string) (int, time.Time, error) {
+	props, err := e.source.Properties(ctx, ev)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("fetch properties: %w", err)
+	}
+	builder := newRowBuilder(ev.Slug, columnsFor(props), started)
+
+	finalDir := filepath.Join(e.config.OutputDir, ev.Slug)
+	dir := finalDir
+	if since == nil {
+		// Full snapshots are written aside and swapped in when complete.
+		dir = filepath.Join(e.config.OutputDir, "."+ev.Slug+".tmp")
+		if err := os.RemoveAll(dir); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+
+	files := make(map[string]*partFile)
+	closeAll := func() error {
+		var errs []error
+		for _, pf := range files {
+			errs = append(errs, pf.writer.Close(), pf.file.Close())
+		}
+		clear(files)
+		return errors.Join(errs...)
+	}
+
+	count := 0
+	watermark, err := e.source.Records(ctx, ev, since, func(records []map[string]any) error {
+		for _, record := range records {
+			row, err := builder.build(record)
+			if err != nil {
+				e.logger.Warn("skipping record", "evidence", ev.Slug, "error", err)
+				continue
+			}
+
+			part := partitionDir(record, ev.DateColumn)
+			pf, ok := files[part]
+			if !ok {
+				pf, err = createPartFile(filepath.Join(dir, part, "part-"+runID+".parquet"), builder.schema)
+				if err != nil {
+					return err
+				}
+				files[part] = pf
+			}
+
+			if _, err := pf.writer.WriteRows([]parquet.Row{row}); err != nil {
+				return fmt.Errorf("write %s: %w", pf.file.Name(), err)
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		_ = closeAll()
+		if since == nil {
+			_ = os.RemoveAll(dir)
+		}
+		return 0, time.Time{}, err
+	}
+	if err := closeAll(); err != nil {
+		return 0, time.Time{}, fmt.Errorf("close parquet files: %w", err)
+	}
+
+	if since == nil {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, time.Time{}, err
+		}
+		if err := os.RemoveAll(finalDir); err != nil {
+			return 0, time.Time{}, err
+		}
+		if err := os.Rename(dir, finalDir); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+
+	return count, watermark, nil
+}
+
+func createPartFile(path string, schema *parquet.Schema) (*partFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &partFile{
+		file:   f,
+		writer: parquet.NewWriter(f, schema, parquet.Compression(&parquet.Snappy)),
+	}, nil
+}
+
+func (e *Exporter) loadState() (*state, error) {
+	st := &state{Evidences: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(filepath.Join(e.config.OutputDir, stateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read export state: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("read export state: %w", err)
+	}
+	if st.Evidences == nil {
+		st.Evidences = make(map[string]time.Time)
+	}
+	return st, nil
+}
+
+func (e *Exporter) saveState(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(e.config.OutputDir, stateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write export state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write export state: %w", err)
+	}
+	return nil
+}
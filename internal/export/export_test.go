@@ -0,0 +1,158 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeSource serves fixed records and remembers the since values it got.
+type fakeSource struct {
+	props     []flexibee.Property
+	records   map[string][]map[string]any
+	watermark time.Time
+	since     []*time.Time
+	fail      map[string]bool
+}
+
+func (f *fakeSource) Properties(_ context.Context, ev registry.Evidence) ([]flexibee.Property, error) {
+	if f.fail[ev.Slug] {
+		return nil, errors.New("unavailable")
+	}
+	return f.props, nil
+}
+
+func (f *fakeSource) Records(_ context.Context, ev registry.Evidence, since *time.Time, fn func([]map[string]any) error) (time.Time, error) {
+	f.since = append(f.since, since)
+	if err := fn(f.records[ev.Slug]); err != nil {
+		return time.Time{}, err
+	}
+	return f.watermark, nil
+}
+
+func readParquet(t *testing.T, path string) []parquet.Row {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	r := parquet.NewReader(f)
+	rows := make([]parquet.Row, r.NumRows())
+	n, err := r.ReadRows(rows)
+	if !errors.Is(err, io.EOF) {
+		require.NoError(t, err)
+	}
+	return rows[:n]
+}
+
+func TestExporter_Run(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", PrimaryKey: "id", DateColumn: "datVyst"})
+	reg.Register(registry.Evidence{Slug: "adresar", Table: "flexibee_adresar", PrimaryKey: "id"})
+
+	src := &fakeSource{
+		props: []flexibee.Property{
+			{Name: "kod", Type: "string"},
+			{Name: "datVyst", Type: "date"},
+			{Name: "sumCelkem", Type: "numeric"},
+		},
+		records: map[string][]map[string]any{
+			"faktura-vydana": {
+				{"id": "1", "kod": "FV-1", "datVyst": "2024-01-15+01:00", "sumCelkem": "100.5"},
+				{"id": "2", "kod": "FV-2", "datVyst": "2024-02-01+01:00", "sumCelkem": "20"},
+				{"id": "3", "kod": "FV-3", "datVyst": "2024-02-03+01:00"},
+			},
+			"adresar": {
+				{"id": "10", "kod": "ACME"},
+			},
+		},
+		watermark: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	out := t.TempDir()
+	exp := NewExporter(src, reg, Config{OutputDir: out}, discardLogger)
+	require.NoError(t, exp.Run(context.Background()))
+
+	jan, err := filepath.Glob(filepath.Join(out, "faktura-vydana", "month=2024-01", "part-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, jan, 1)
+	assert.Len(t, readParquet(t, jan[0]), 1)
+
+	feb, err := filepath.Glob(filepath.Join(out, "faktura-vydana", "month=2024-02", "part-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, feb, 1)
+	assert.Len(t, readParquet(t, feb[0]), 2)
+
+	unpartitioned, err := filepath.Glob(filepath.Join(out, "adresar", "part-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, unpartitioned, 1)
+
+	// An incremental run continues from the saved watermark.
+	src.records = map[string][]map[string]any{
+		"faktura-vydana": {{"id": "2", "kod": "FV-2b", "datVyst": "2024-02-01+01:00"}},
+	}
+	exp = NewExporter(src, reg, Config{OutputDir: out, Incremental: true}, discardLogger)
+	require.NoError(t, exp.Run(context.Background()))
+
+	require.Len(t, src.since, 4)
+	assert.Nil(t, src.since[0])
+	require.NotNil(t, src.since[2])
+	assert.True(t, src.watermark.Equal(*src.since[2]))
+}
+
+func TestExporter_Run_FullReplacesSnapshot(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "adresar", Table: "flexibee_adresar", PrimaryKey: "id"})
+	src := &fakeSource{records: map[string][]map[string]any{"adresar": {{"id": "1"}}}}
+
+	out := t.TempDir()
+	stale := filepath.Join(out, "adresar", "part-stale.parquet")
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0o755))
+	require.NoError(t, os.WriteFile(stale, []byte("old"), 0o644))
+
+	require.NoError(t, NewExporter(src, reg, Config{OutputDir: out}, discardLogger).Run(context.Background()))
+
+	_, err := os.Stat(stale)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(out, ".adresar.tmp"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestExporter_Run_ContinuesAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "banka", Table: "flexibee_banka", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "adresar", Table: "flexibee_adresar", PrimaryKey: "id"})
+	src := &fakeSource{
+		records: map[string][]map[string]any{"adresar": {{"id": "1"}}},
+		fail:    map[string]bool{"banka": true},
+	}
+
+	out := t.TempDir()
+	err := NewExporter(src, reg, Config{OutputDir: out}, discardLogger).Run(context.Background())
+	assert.ErrorContains(t, err, "banka")
+
+	files, err := filepath.Glob(filepath.Join(out, "adresar", "part-*.parquet"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,248 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+// rawDataColumn holds the full record as JSON, like raw_data in the database.
+const rawDataColumn = "raw_data"
+
+// exportedAtColumn holds the start of the export run that wrote a row.
+// Incremental exports write a record again whenever it changes, so readers
+// keep the row with the newest value per id.
+const exportedAtColumn = "_exported_at"
+
+// column is one Parquet column derived from a Flexibee property.
+type column struct {
+	Name string
+	Type string // Flexibee property type
+}
+
+// columnsFor returns the exported columns for an evidence: id, every
+// property, raw_data and _exported_at.
+func columnsFor(properties []flexibee.Property) []column {
+	cols := []column{{Name: "id", Type: "integer"}}
+	seen := map[string]bool{"id": true, rawDataColumn: true, exportedAtColumn: true}
+	for _, prop := range properties {
+		if seen[prop.Name] {
+			continue
+		}
+		seen[prop.Name] = true
+		cols = append(cols, column{Name: prop.Name, Type: prop.Type})
+	}
+	return append(cols, column{Name: rawDataColumn, Type: "string"}, column{Name: exportedAtColumn, Type: "datetime"})
+}
+
+// parquetNode maps a Flexibee property type to a Parquet column type.
+func parquetNode(flexType string) parquet.Node {
+	switch flexType {
+	case "integer":
+		return parquet.Int(64)
+	case "numeric":
+		return parquet.Leaf(parquet.DoubleType)
+	case "date":
+		return parquet.Date()
+	case "datetime":
+		return parquet.Timestamp(parquet.Millisecond)
+	case "logic":
+		return parquet.Leaf(parquet.BooleanType)
+	default:
+		return parquet.String()
+	}
+}
+
+// buildSchema returns the Parquet schema for cols. id and _exported_at are
+// required, every other column is optional.
+func buildSchema(name string, cols []column) *parquet.Schema {
+	group := make(parquet.Group, len(cols))
+	for _, col := range cols {
+		node := parquetNode(col.Type)
+		if col.Name != "id" && col.Name != exportedAtColumn {
+			node = parquet.Optional(node)
+		}
+		group[col.Name] = node
+	}
+	return parquet.NewSchema(name, group)
+}
+
+// rowBuilder converts records to Parquet rows in the column order of a schema.
+type rowBuilder struct {
+	schema     *parquet.Schema
+	columns    []column  // in schema column order
+	exportedAt time.Time // written to _exported_at
+}
+
+func newRowBuilder(name string, cols []column, exportedAt time.Time) *rowBuilder {
+	schema := buildSchema(name, cols)
+	byName := make(map[string]column, len(cols))
+	for _, col := range cols {
+		byName[col.Name] = col
+	}
+
+	ordered := make([]column, 0, len(cols))
+	for _, path := range schema.Columns() {
+		ordered = append(ordered, byName[path[0]])
+	}
+	return &rowBuilder{schema: schema, columns: ordered, exportedAt: exportedAt}
+}
+
+// build converts a record into a row. The record must have an id; other
+// values that don't match their column type are written as null.
+func (b *rowBuilder) build(record map[string]any) (parquet.Row, error) {
+	row := make(parquet.Row, 0, len(b.columns))
+	for i, col := range b.columns {
+		var v parquet.Value
+		var ok bool
+		switch col.Name {
+		case rawDataColumn:
+			raw, err := json.Marshal(record)
+			if err != nil {
+				return nil, fmt.Errorf("marshal record: %w", err)
+			}
+			v, ok = parquet.ByteArrayValue(raw), true
+		case exportedAtColumn:
+			// Required like id and the same for every row of a run.
+			row = append(row, parquet.Int64Value(b.exportedAt.UnixMilli()).Level(0, 0, i))
+			continue
+		default:
+			var err error
+			if v, ok, err = convertValue(record[col.Name], col.Type); err != nil {
+				ok = false
+			}
+		}
+
+		switch {
+		case col.Name == "id":
+			if !ok {
+				return nil, fmt.Errorf("record has no id")
+			}
+			row = append(row, v.Level(0, 0, i))
+		case ok:
+			row = append(row, v.Level(0, 1, i))
+		default:
+			row = append(row, parquet.NullValue().Level(0, 0, i))
+		}
+	}
+	return row, nil
+}
+
+// convertValue converts a decoded JSON value into a Parquet value of the
+// given Flexibee type. ok is false for null or empty values.
+func convertValue(v any, flexType string) (parquet.Value, bool, error) {
+	if v == nil || v == "" {
+		return parquet.Value{}, false, nil
+	}
+
+	switch flexType {
+	case "integer":
+		n, err := toFloat(v)
+		if err != nil {
+			return parquet.Value{}, false, err
+		}
+		return parquet.Int64Value(int64(n)), true, nil
+	case "numeric":
+		n, err := toFloat(v)
+		if err != nil {
+			return parquet.Value{}, false, err
+		}
+		return parquet.DoubleValue(n), true, nil
+	case "date":
+		t, err := toTime(v)
+		if err != nil {
+			return parquet.Value{}, false, err
+		}
+		days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		return parquet.Int32Value(int32(days)), true, nil
+	case "datetime":
+		t, err := toTime(v)
+		if err != nil {
+			return parquet.Value{}, false, err
+		}
+		return parquet.Int64Value(t.UnixMilli()), true, nil
+	case "logic":
+		switch b := v.(type) {
+		case bool:
+			return parquet.BooleanValue(b), true, nil
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return parquet.Value{}, false, fmt.Errorf("invalid boolean %q", b)
+			}
+			return parquet.BooleanValue(parsed), true, nil
+		}
+		return parquet.Value{}, false, fmt.Errorf("invalid boolean %v", v)
+	default:
+		if s, ok := v.(string); ok {
+			return parquet.ByteArrayValue([]byte(s)), true, nil
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return parquet.Value{}, false, err
+		}
+		return parquet.ByteArrayValue(raw), true, nil
+	}
+}
+
+func toFloat(v any) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	}
+	return 0, fmt.Errorf("invalid number %v", v)
+}
+
+// timeLayouts are the date and time formats found in Flexibee JSON output
+// and in values read back from the database.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02Z07:00",
+	time.DateOnly,
+}
+
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q", t)
+	}
+	return time.Time{}, fmt.Errorf("invalid date %v", v)
+}
+
+// partitionDir returns the Hive-style partition directory of a record,
+// month=YYYY-MM by its date column, or "" for evidences without one.
+func partitionDir(record map[string]any, dateColumn string) string {
+	if dateColumn == "" {
+		return ""
+	}
+	if s, ok := record[dateColumn].(string); ok && len(s) >= 7 {
+		if _, err := time.Parse("2006-01", s[:7]); err == nil {
+			return "month=" + s[:7]
+		}
+	}
+	if t, ok := record[dateColumn].(time.Time); ok {
+		return "month=" + t.Format("2006-01")
+	}
+	return "month=__HIVE_DEFAULT_PARTITION__"
+}
@@ -0,0 +1,144 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+func TestColumnsFor(t *testing.T) {
+	t.Parallel()
+
+	cols := columnsFor([]flexibee.Property{
+		{Name: "id", Type: "integer"},
+		{Name: "kod", Type: "string"},
+		{Name: "kod", Type: "string"},
+	})
+
+	assert.Equal(t, []column{
+		{Name: "id", Type: "integer"},
+		{Name: "kod", Type: "string"},
+		{Name: rawDataColumn, Type: "string"},
+		{Name: exportedAtColumn, Type: "datetime"},
+	}, cols)
+}
+
+func TestBuildSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := buildSchema("faktura", []column{
+		{Name: "id", Type: "integer"},
+		{Name: "datVyst", Type: "date"},
+		{Name: "lastUpdate", Type: "datetime"},
+	})
+
+	id, ok := schema.Lookup("id")
+	require.True(t, ok)
+	assert.True(t, id.Node.Required())
+
+	date, ok := schema.Lookup("datVyst")
+	require.True(t, ok)
+	assert.True(t, date.Node.Optional())
+	assert.IsType(t, &format.DateType{}, date.Node.Type().LogicalType().Value)
+
+	ts, ok := schema.Lookup("lastUpdate")
+	require.True(t, ok)
+	assert.IsType(t, &format.TimestampType{}, ts.Node.Type().LogicalType().Value)
+}
+
+func TestConvertValue(t *testing.T) {
+	t.Parallel()
+
+	v, ok, err := convertValue("42", "integer")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), v.Int64())
+
+	v, ok, err = convertValue("100.25", "numeric")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, 100.25, v.Double(), 0.0001)
+
+	v, ok, err = convertValue("1970-01-03+01:00", "date")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(2), v.Int32())
+
+	v, ok, err = convertValue("2024-01-01T10:00:00.000+01:00", "datetime")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC).UnixMilli(), v.Int64())
+
+	v, ok, err = convertValue("true", "logic")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, v.Boolean())
+
+	v, ok, err = convertValue(map[string]any{"a": "b"}, "string")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `{"a":"b"}`, string(v.ByteArray()))
+
+	_, ok, err = convertValue(nil, "integer")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = convertValue("", "date")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = convertValue("abc", "numeric")
+	assert.Error(t, err)
+}
+
+func TestRowBuilder_Build(t *testing.T) {
+	t.Parallel()
+
+	exportedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := newRowBuilder("t", columnsFor([]flexibee.Property{
+		{Name: "kod", Type: "string"},
+		{Name: "sumCelkem", Type: "numeric"},
+	}), exportedAt)
+
+	row, err := b.build(map[string]any{"id": "1", "kod": "A", "sumCelkem": "not a number"})
+	require.NoError(t, err)
+	require.Len(t, row, 5)
+	for i, v := range row {
+		assert.Equal(t, i, v.Column())
+		switch b.columns[i].Name {
+		case "sumCelkem":
+			assert.True(t, v.IsNull(), "invalid values are written as null")
+		case exportedAtColumn:
+			assert.Equal(t, exportedAt.UnixMilli(), v.Int64())
+		}
+	}
+
+	_, err = b.build(map[string]any{"kod": "A"})
+	assert.ErrorContains(t, err, "no id")
+}
+
+func TestPartitionDir(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "month=2024-03", partitionDir(map[string]any{"datVyst": "2024-03-15+01:00"}, "datVyst"))
+	assert.Equal(t, "month=2024-04", partitionDir(map[string]any{"datVyst": time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}, "datVyst"))
+	assert.Equal(t, "month=__HIVE_DEFAULT_PARTITION__", partitionDir(map[string]any{}, "datVyst"))
+	assert.Equal(t, "month=__HIVE_DEFAULT_PARTITION__", partitionDir(map[string]any{"datVyst": "garbage"}, "datVyst"))
+	assert.Equal(t, "", partitionDir(map[string]any{"datVyst": "2024-03-15"}, ""))
+}
+
+func TestPgTypeToFlexibee(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "integer", pgTypeToFlexibee("bigint"))
+	assert.Equal(t, "numeric", pgTypeToFlexibee("numeric"))
+	assert.Equal(t, "date", pgTypeToFlexibee("date"))
+	assert.Equal(t, "datetime", pgTypeToFlexibee("timestamp with time zone"))
+	assert.Equal(t, "logic", pgTypeToFlexibee("boolean"))
+	assert.Equal(t, "string", pgTypeToFlexibee("text"))
+}
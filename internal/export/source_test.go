@@ -0,0 +1,49 @@
+package export
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func TestPostgresSource_Records_Overlap(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	_, err = pool.Exec(ctx, `CREATE TABLE test_export_overlap (id BIGINT PRIMARY KEY, raw_data JSONB, synced_at TIMESTAMPTZ NOT NULL)`)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = pool.Exec(context.Background(), "DROP TABLE test_export_overlap") })
+
+	// Row 1 committed late, with a synced_at before the watermark of a
+	// previous export that already saw row 2.
+	watermark := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err = pool.Exec(ctx, `INSERT INTO test_export_overlap VALUES (1, '{"id": 1}', $1), (2, '{"id": 2}', $2), (3, '{"id": 3}', $3)`,
+		watermark.Add(-time.Minute), watermark, watermark.Add(-time.Hour))
+	require.NoError(t, err)
+
+	src := NewPostgresSource(pool, 100)
+	var ids []any
+	got, err := src.Records(ctx, registry.Evidence{Table: "test_export_overlap"}, &watermark, func(records []map[string]any) error {
+		for _, r := range records {
+			ids = append(ids, r["id"])
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{float64(1), float64(2)}, ids)
+	assert.True(t, got.Equal(watermark))
+}
@@ -9,8 +9,17 @@ import (
 	"net/url"
 	"strconv"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/tracing"
 )
 
+var tracer = otel.Tracer("github.com/anaryk/metabase-flexibee-adapter/internal/flexibee")
+
 const (
 	maxRetries     = 3
 	initialBackoff = 500 * time.Millisecond
@@ -41,18 +50,29 @@ func NewClient(baseURL, company, username, password string, logger *slog.Logger)
 }
 
 // FetchEvidence retrieves records from a single evidence endpoint.
-func (c *Client) FetchEvidence(ctx context.Context, evidence string, opts FetchOptions) (*Response, error) {
+func (c *Client) FetchEvidence(ctx context.Context, evidence string, opts FetchOptions) (_ *Response, err error) {
+	ctx, span := tracer.Start(ctx, "flexibee.fetch_page", trace.WithAttributes(
+		attribute.String("flexibee.evidence", evidence),
+		attribute.Int("flexibee.start", opts.Start),
+		attribute.Int("flexibee.limit", opts.Limit),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	u := c.buildURL(evidence, opts)
 
-	body, err := c.doRequest(ctx, u)
+	body, err := c.doRequest(ctx, evidence, u)
 	if err != nil {
 		return nil, fmt.Errorf("fetch evidence %s: %w", evidence, err)
 	}
 
+	_, parseSpan := tracer.Start(ctx, "flexibee.parse", trace.WithAttributes(attribute.Int("flexibee.bytes", len(body))))
 	resp, err := parseResponse(body, evidence)
+	tracing.End(parseSpan, err)
 	if err != nil {
 		return nil, fmt.Errorf("parse evidence %s: %w", evidence, err)
 	}
+	resp.Size = len(body)
+	span.SetAttributes(attribute.Int("flexibee.records", len(resp.Winstrom.Records)))
 
 	return resp, nil
 }
@@ -61,7 +81,7 @@ func (c *Client) FetchEvidence(ctx context.Context, evidence string, opts FetchO
 func (c *Client) FetchEvidenceProperties(ctx context.Context, evidence string) ([]Property, error) {
 	u := fmt.Sprintf("%s/c/%s/%s/properties.json", c.baseURL, c.company, evidence)
 
-	body, err := c.doRequest(ctx, u)
+	body, err := c.doRequest(ctx, evidence, u)
 	if err != nil {
 		return nil, fmt.Errorf("fetch properties for %s: %w", evidence, err)
 	}
@@ -90,6 +110,9 @@ func (c *Client) buildURL(evidence string, opts FetchOptions) string {
 	if opts.Filter != "" {
 		params.Set("filter", opts.Filter)
 	}
+	if opts.Order != "" {
+		params.Set("order", opts.Order)
+	}
 	if opts.AddRowCount {
 		params.Set("add-row-count", "true")
 	}
@@ -100,11 +123,20 @@ func (c *Client) buildURL(evidence string, opts FetchOptions) string {
 	return u
 }
 
-func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
+// doRequest GETs url, retrying on network and server errors. Every attempt
+// is measured under the evidence it is for and recorded as an event of the
+// request's span.
+func (c *Client) doRequest(ctx context.Context, evidence, url string) (_ []byte, err error) {
+	ctx, span := tracer.Start(ctx, "flexibee.request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("flexibee.evidence", evidence)))
+	defer func() { tracing.End(span, err) }()
+
 	var lastErr error
 
 	for attempt := range maxRetries {
+		span.SetAttributes(attribute.Int("flexibee.attempts", attempt+1))
 		if attempt > 0 {
+			metrics.FlexibeeRetries.WithLabelValues(evidence).Inc()
 			backoff := initialBackoff * time.Duration(1<<(attempt-1))
 			select {
 			case <-ctx.Done():
@@ -121,23 +153,33 @@ func (c *Client) doRequest(ctx context.Context, url string) ([]byte, error) {
 		req.SetBasicAuth(c.username, c.password)
 		req.Header.Set("Accept", "application/json")
 
+		start := time.Now()
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
+			metrics.FlexibeeRequestDuration.WithLabelValues(evidence, "error").Observe(time.Since(start).Seconds())
+			span.AddEvent("attempt failed", trace.WithAttributes(
+				attribute.Int("flexibee.attempt", attempt+1), attribute.String("error", err.Error())))
 			lastErr = fmt.Errorf("http request (attempt %d): %w", attempt+1, err)
-			c.logger.Warn("request failed, retrying", "attempt", attempt+1, "error", err)
+			c.logger.WarnContext(ctx, "request failed, retrying", "attempt", attempt+1, "error", err)
 			continue
 		}
 
 		body, err := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
+		metrics.FlexibeeRequestDuration.WithLabelValues(evidence, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 		if err != nil {
+			span.AddEvent("attempt failed", trace.WithAttributes(
+				attribute.Int("flexibee.attempt", attempt+1), attribute.String("error", err.Error())))
 			lastErr = fmt.Errorf("read body (attempt %d): %w", attempt+1, err)
 			continue
 		}
 
 		if resp.StatusCode >= 500 {
+			span.AddEvent("attempt failed", trace.WithAttributes(
+				attribute.Int("flexibee.attempt", attempt+1), attribute.Int("http.response.status_code", resp.StatusCode)))
 			lastErr = fmt.Errorf("server error %d (attempt %d)", resp.StatusCode, attempt+1)
-			c.logger.Warn("server error, retrying", "status", resp.StatusCode, "attempt", attempt+1)
+			c.logger.WarnContext(ctx, "server error, retrying", "status", resp.StatusCode, "attempt", attempt+1)
 			continue
 		}
 
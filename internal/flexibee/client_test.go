@@ -8,6 +8,8 @@ import (
 	"sync/atomic"
 	"testing"
 
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,6 +45,7 @@ func TestFetchEvidence_QueryParams(t *testing.T) {
 		assert.Equal(t, "50", q.Get("limit"))
 		assert.Equal(t, "10", q.Get("start"))
 		assert.Equal(t, "full", q.Get("detail"))
+		assert.Equal(t, "id", q.Get("order"))
 		assert.Equal(t, "true", q.Get("add-row-count"))
 
 		w.Header().Set("Content-Type", "application/json")
@@ -55,6 +58,7 @@ func TestFetchEvidence_QueryParams(t *testing.T) {
 		Limit:       50,
 		Start:       10,
 		Detail:      "full",
+		Order:       "id",
 		AddRowCount: true,
 	})
 	require.NoError(t, err)
@@ -89,7 +93,10 @@ func TestFetchEvidence_ParsesRecords(t *testing.T) {
 }
 
 func TestFetchEvidence_RetriesOnServerError(t *testing.T) {
-	t.Parallel()
+	// Not parallel: other tests retry prodejka too, and parallel tests only
+	// start once the sequential ones have finished.
+	retries := metrics.FlexibeeRetries.WithLabelValues("prodejka")
+	before := testutil.ToFloat64(retries)
 
 	var attempts atomic.Int32
 
@@ -109,6 +116,7 @@ func TestFetchEvidence_RetriesOnServerError(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, resp.Winstrom.Records, 1)
 	assert.Equal(t, int32(3), attempts.Load())
+	assert.Equal(t, 2.0, testutil.ToFloat64(retries)-before)
 }
 
 func TestFetchEvidence_FailsOnClientError(t *testing.T) {
@@ -149,7 +157,8 @@ func TestFetchEvidenceProperties(t *testing.T) {
 		"properties": {
 			"property": [
 				{"propertyName": "id", "type": "integer", "maxLength": 0, "mandatory": true, "isReadOnly": true},
-				{"propertyName": "kod", "type": "string", "maxLength": 20, "mandatory": true, "isReadOnly": false}
+				{"propertyName": "kod", "name": "Zkratka", "type": "string", "maxLength": 20, "mandatory": true, "isReadOnly": false},
+				{"propertyName": "firma", "name": "Firma", "type": "relation", "fkEvidencePath": "adresar"}
 			]
 		}
 	}`
@@ -164,9 +173,11 @@ func TestFetchEvidenceProperties(t *testing.T) {
 	c := NewClient(srv.URL, "demo", "user", "pass", discardLogger)
 	props, err := c.FetchEvidenceProperties(context.Background(), "prodejka")
 	require.NoError(t, err)
-	assert.Len(t, props, 2)
+	assert.Len(t, props, 3)
 	assert.Equal(t, "id", props[0].Name)
 	assert.Equal(t, "integer", props[0].Type)
 	assert.Equal(t, "kod", props[1].Name)
+	assert.Equal(t, "Zkratka", props[1].Title)
 	assert.Equal(t, FlexibeeInt(20), props[1].MaxLength)
+	assert.Equal(t, "adresar", props[2].FKEvidence)
 }
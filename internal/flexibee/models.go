@@ -9,6 +9,7 @@ import (
 // Response is the top-level Flexibee API response wrapper.
 type Response struct {
 	Winstrom ResponseEnvelope `json:"winstrom"`
+	Size     int              `json:"-"` // length of the response body in bytes
 }
 
 // ResponseEnvelope contains metadata and records from the API.
@@ -59,11 +60,13 @@ func (i *FlexibeeInt) UnmarshalJSON(data []byte) error {
 
 // Property describes a single field in a Flexibee evidence type.
 type Property struct {
-	Name      string       `json:"propertyName"`
-	Type      string       `json:"type"`
-	MaxLength FlexibeeInt  `json:"maxLength"`
-	Mandatory FlexibeeBool `json:"mandatory"`
-	ReadOnly  FlexibeeBool `json:"isReadOnly"`
+	Name       string       `json:"propertyName"`
+	Title      string       `json:"name"` // human-readable label, e.g. "Zkratka"
+	Type       string       `json:"type"`
+	MaxLength  FlexibeeInt  `json:"maxLength"`
+	Mandatory  FlexibeeBool `json:"mandatory"`
+	ReadOnly   FlexibeeBool `json:"isReadOnly"`
+	FKEvidence string       `json:"fkEvidencePath"` // target evidence of a relation
 }
 
 // EvidenceInfo describes an available evidence type.
@@ -78,6 +81,7 @@ type FetchOptions struct {
 	Start       int
 	Detail      string // "full", "summary", "id", "custom:..."
 	Filter      string // Flexibee filter expression
+	Order       string // Sort property, e.g. "id" or "id@D" for descending
 	AddRowCount bool
 }
 
@@ -13,6 +13,7 @@ type PageIterator struct {
 	done     bool
 	total    *int
 	fetched  int
+	bytes    int64
 }
 
 // IterateEvidence returns a PageIterator for paginated fetching.
@@ -41,6 +42,7 @@ func (it *PageIterator) Next(ctx context.Context) ([]map[string]any, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fetch page at offset %d: %w", it.opts.Start, err)
 	}
+	it.bytes += int64(resp.Size)
 
 	if it.total == nil && resp.Winstrom.RowCount != nil {
 		it.total = resp.Winstrom.RowCount
@@ -64,3 +66,8 @@ func (it *PageIterator) Next(ctx context.Context) ([]map[string]any, error) {
 
 	return records, nil
 }
+
+// Bytes returns the total size of the pages fetched so far.
+func (it *PageIterator) Bytes() int64 {
+	return it.bytes
+}
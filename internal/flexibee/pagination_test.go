@@ -140,6 +140,7 @@ func TestPageIterator_SinglePage(t *testing.T) {
 	page, err := it.Next(ctx)
 	require.NoError(t, err)
 	assert.Len(t, page, 2)
+	assert.Equal(t, int64(len(`{"winstrom":{"@version":"1.0","@rowCount":2,"test":[{"id":1},{"id":2}]}}`)), it.Bytes())
 
 	page, err = it.Next(ctx)
 	require.NoError(t, err)
@@ -0,0 +1,111 @@
+package flexibee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// CountRecords returns the number of records of an evidence matching
+// filter (all records when filter is empty), using add-row-count.
+func (c *Client) CountRecords(ctx context.Context, evidence, filter string) (int, error) {
+	resp, err := c.FetchEvidence(ctx, evidence, FetchOptions{
+		Limit:       1,
+		Detail:      "id",
+		Filter:      filter,
+		AddRowCount: true,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if resp.Winstrom.RowCount == nil {
+		return 0, fmt.Errorf("count %s: response has no @rowCount", evidence)
+	}
+	return *resp.Winstrom.RowCount, nil
+}
+
+// FetchSums returns the totals of the numeric properties of an evidence
+// over the records matching filter, from Flexibee's $sum endpoint.
+func (c *Client) FetchSums(ctx context.Context, evidence, filter string) (map[string]float64, error) {
+	u := fmt.Sprintf("%s/c/%s/%s/$sum.json", c.baseURL, c.company, evidence)
+	if filter != "" {
+		u += "?" + url.Values{"filter": {filter}}.Encode()
+	}
+
+	body, err := c.doRequest(ctx, evidence, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch sums for %s: %w", evidence, err)
+	}
+
+	sums, err := parseSums(body, evidence)
+	if err != nil {
+		return nil, fmt.Errorf("parse sums for %s: %w", evidence, err)
+	}
+	return sums, nil
+}
+
+// parseSums parses a $sum response. Totals are found under "sum" (or
+// "$sum") either directly in the winstrom envelope or under the evidence
+// key; each total is a number, a numeric string or an object with a value.
+func parseSums(data []byte, evidence string) (map[string]float64, error) {
+	var raw struct {
+		Winstrom map[string]json.RawMessage `json:"winstrom"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+
+	container := raw.Winstrom
+	if v, ok := raw.Winstrom[evidence]; ok {
+		var nested map[string]json.RawMessage
+		if err := json.Unmarshal(v, &nested); err == nil {
+			container = nested
+		}
+	}
+
+	var totalsRaw json.RawMessage
+	for _, key := range []string{"sum", "$sum"} {
+		if v, ok := container[key]; ok {
+			totalsRaw = v
+			break
+		}
+	}
+	if totalsRaw == nil {
+		return nil, fmt.Errorf("response has no sums")
+	}
+
+	var totals map[string]json.RawMessage
+	if err := json.Unmarshal(totalsRaw, &totals); err != nil {
+		return nil, fmt.Errorf("unmarshal sums: %w", err)
+	}
+
+	sums := make(map[string]float64, len(totals))
+	for name, v := range totals {
+		var obj struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(v, &obj); err == nil && obj.Value != nil {
+			v = obj.Value
+		}
+		if n, ok := parseSumValue(v); ok {
+			sums[name] = n
+		}
+	}
+	return sums, nil
+}
+
+// parseSumValue parses a total given as a JSON number or numeric string.
+func parseSumValue(v json.RawMessage) (float64, bool) {
+	var n float64
+	if err := json.Unmarshal(v, &n); err == nil {
+		return n, true
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
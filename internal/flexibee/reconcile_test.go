@@ -0,0 +1,64 @@
+package flexibee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountRecords(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "/c/demo/faktura-vydana.json", r.URL.Path)
+		assert.Equal(t, "true", q.Get("add-row-count"))
+		assert.Equal(t, "datVyst >= '2024-01-01'", q.Get("filter"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"42","faktura-vydana":[{"id":"1"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	n, err := c.CountRecords(context.Background(), "faktura-vydana", "datVyst >= '2024-01-01'")
+	require.NoError(t, err)
+	assert.Equal(t, 42, n)
+}
+
+func TestFetchSums(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/c/demo/faktura-vydana/$sum.json", r.URL.Path)
+		assert.Equal(t, "datVyst < '2024-02-01'", r.URL.Query().Get("filter"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","sum":{
+			"sumCelkem":{"@name":"sumCelkem","value":"1210.50"},
+			"sumZklZakl":{"value":1000.5},
+			"sumDphZakl":"210"
+		}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	sums, err := c.FetchSums(context.Background(), "faktura-vydana", "datVyst < '2024-02-01'")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"sumCelkem": 1210.5, "sumZklZakl": 1000.5, "sumDphZakl": 210}, sums)
+}
+
+func TestParseSums_UnderEvidenceKey(t *testing.T) {
+	t.Parallel()
+
+	sums, err := parseSums([]byte(`{"winstrom":{"banka":{"$sum":{"sumCelkem":{"value":"5"}}}}}`), "banka")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"sumCelkem": 5}, sums)
+
+	_, err = parseSums([]byte(`{"winstrom":{"banka":[]}}`), "banka")
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package flexibee
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FetchBaseCurrency returns the code of the company's base (local) currency,
+// e.g. "CZK", from its settings. Settings are kept per accounting period;
+// the most recent period wins.
+func (c *Client) FetchBaseCurrency(ctx context.Context) (string, error) {
+	resp, err := c.FetchEvidence(ctx, "nastaveni", FetchOptions{Detail: "custom:mena,platiOdData"})
+	if err != nil {
+		return "", err
+	}
+
+	var currency string
+	var from time.Time
+	for _, rec := range resp.Winstrom.Records {
+		mena, _ := rec["mena"].(string)
+		validFrom := parseDate(rec["platiOdData"])
+		if mena == "" || (currency != "" && validFrom.Before(from)) {
+			continue
+		}
+		currency, from = mena, validFrom
+	}
+	if currency == "" {
+		return "", fmt.Errorf("settings have no base currency")
+	}
+	return strings.TrimPrefix(currency, "code:"), nil
+}
+
+// parseDate parses a Flexibee date such as "2024-01-01+01:00", ignoring
+// its zone offset. Values that are not dates give the zero time.
+func parseDate(v any) time.Time {
+	s, _ := v.(string)
+	if len(s) < len(time.DateOnly) {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.DateOnly, s[:len(time.DateOnly)])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,47 @@
+package flexibee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBaseCurrency(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/c/demo/nastaveni.json", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","nastaveni":[
+			{"id":"1","mena":"code:EUR","platiOdData":"2023-01-01+01:00"},
+			{"id":"2","mena":"code:CZK","platiOdData":"2024-01-01+01:00"},
+			{"id":"3","platiOdData":"2025-01-01+01:00"},
+			{"id":"4","mena":"code:USD","platiOdData":"2023-06-01"}
+		]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	currency, err := c.FetchBaseCurrency(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "CZK", currency)
+}
+
+func TestFetchBaseCurrency_Missing(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","nastaveni":[]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	_, err := c.FetchBaseCurrency(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package metabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client communicates with the Metabase REST API using an API key.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Metabase API client.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Database is a database connection registered in Metabase.
+type Database struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Engine string `json:"engine"`
+}
+
+// NewDatabase is the payload for registering a database.
+type NewDatabase struct {
+	Name    string         `json:"name"`
+	Engine  string         `json:"engine"`
+	Details map[string]any `json:"details"`
+}
+
+// Metadata is the schema of a database as known to Metabase.
+type Metadata struct {
+	Tables []Table `json:"tables"`
+}
+
+// Table is a table in Metabase's metadata.
+type Table struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Schema string  `json:"schema"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a column in Metabase's metadata.
+type Field struct {
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	SemanticType   string `json:"semantic_type"`
+	DisplayName    string `json:"display_name"`
+	VisibilityType string `json:"visibility_type"`
+}
+
+// FieldUpdate is the payload for updating a field's metadata. Empty
+// values are left unchanged.
+type FieldUpdate struct {
+	SemanticType   string `json:"semantic_type,omitempty"`
+	DisplayName    string `json:"display_name,omitempty"`
+	VisibilityType string `json:"visibility_type,omitempty"`
+}
+
+// Databases lists the registered databases.
+func (c *Client) Databases(ctx context.Context) ([]Database, error) {
+	var raw json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/api/database", nil, &raw); err != nil {
+		return nil, fmt.Errorf("list databases: %w", err)
+	}
+
+	// Newer Metabase versions wrap the list in {"data": [...]}.
+	var wrapped struct {
+		Data []Database `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &wrapped); err == nil {
+		return wrapped.Data, nil
+	}
+	var dbs []Database
+	if err := json.Unmarshal(raw, &dbs); err != nil {
+		return nil, fmt.Errorf("list databases: %w", err)
+	}
+	return dbs, nil
+}
+
+// CreateDatabase registers a database. Metabase syncs its schema right
+// after it is created.
+func (c *Client) CreateDatabase(ctx context.Context, db NewDatabase) (*Database, error) {
+	var created Database
+	if err := c.do(ctx, http.MethodPost, "/api/database", db, &created); err != nil {
+		return nil, fmt.Errorf("create database %s: %w", db.Name, err)
+	}
+	return &created, nil
+}
+
+// SyncSchema starts a schema sync of a database, picking up new tables
+// and columns. The sync runs asynchronously in Metabase.
+func (c *Client) SyncSchema(ctx context.Context, dbID int) error {
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/database/%d/sync_schema", dbID), nil, nil); err != nil {
+		return fmt.Errorf("sync schema of database %d: %w", dbID, err)
+	}
+	return nil
+}
+
+// RescanValues starts a rescan of the field values Metabase caches for
+// filters on category columns.
+func (c *Client) RescanValues(ctx context.Context, dbID int) error {
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/database/%d/rescan_values", dbID), nil, nil); err != nil {
+		return fmt.Errorf("rescan values of database %d: %w", dbID, err)
+	}
+	return nil
+}
+
+// Metadata returns the tables and fields of a database, including hidden
+// ones.
+func (c *Client) Metadata(ctx context.Context, dbID int) (*Metadata, error) {
+	var meta Metadata
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/database/%d/metadata?include_hidden=true", dbID), nil, &meta); err != nil {
+		return nil, fmt.Errorf("fetch metadata of database %d: %w", dbID, err)
+	}
+	return &meta, nil
+}
+
+// UpdateField updates the metadata of a field.
+func (c *Client) UpdateField(ctx context.Context, fieldID int, update FieldUpdate) error {
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/field/%d", fieldID), update, nil); err != nil {
+		return fmt.Errorf("update field %d: %w", fieldID, err)
+	}
+	return nil
+}
+
+// do sends a JSON request and decodes the JSON response into out, if set.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(data))
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
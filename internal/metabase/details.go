@@ -0,0 +1,61 @@
+package metabase
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// databaseFor describes the database at databaseURL (in the adapter's
+// DATABASE_URL format) as a Metabase database connection.
+func databaseFor(name, databaseURL string) (NewDatabase, error) {
+	if path, ok := strings.CutPrefix(databaseURL, "sqlite://"); ok {
+		if path == "" {
+			return NewDatabase{}, errors.New("sqlite database path is empty")
+		}
+		return NewDatabase{Name: name, Engine: "sqlite", Details: map[string]any{"db": path}}, nil
+	}
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return NewDatabase{}, fmt.Errorf("parse database URL: %w", err)
+	}
+
+	var engine string
+	port := 5432
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		engine = "postgres"
+	case "mysql", "mariadb":
+		engine, port = "mysql", 3306
+	default:
+		return NewDatabase{}, fmt.Errorf("unsupported database URL scheme %q", u.Scheme)
+	}
+
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return NewDatabase{}, fmt.Errorf("invalid port %q", p)
+		}
+	}
+
+	details := map[string]any{
+		"host":   u.Hostname(),
+		"port":   port,
+		"dbname": strings.TrimPrefix(u.Path, "/"),
+	}
+	if u.User != nil {
+		details["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			details["password"] = password
+		}
+	}
+	if engine == "postgres" {
+		mode := u.Query().Get("sslmode")
+		details["ssl"] = mode != "" && mode != "disable"
+	}
+
+	return NewDatabase{Name: name, Engine: engine, Details: details}, nil
+}
@@ -0,0 +1,95 @@
+package metabase
+
+import (
+	"strings"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+// Metabase semantic and visibility types set by the adapter.
+const (
+	semanticPK                = "type/PK"
+	semanticFK                = "type/FK"
+	semanticCurrency          = "type/Currency"
+	semanticCategory          = "type/Category"
+	semanticCreationDate      = "type/CreationDate"
+	semanticCreationTimestamp = "type/CreationTimestamp"
+	semanticUpdatedTimestamp  = "type/UpdatedTimestamp"
+
+	visibilityDetailsOnly = "details-only"
+	visibilitySensitive   = "sensitive"
+)
+
+// currencyPrefixes mark numeric Flexibee properties holding money amounts,
+// e.g. sumCelkem, cenaMj, castkaMen, zbyvaUhradit.
+var currencyPrefixes = []string{"sum", "cena", "castka", "zbyva"}
+
+// fieldSettings returns the desired Metabase metadata of every column of an
+// evidence table, keyed by column name. Technical columns are hidden; the
+// visibility of other columns is left to Metabase admins.
+func fieldSettings(ev registry.Evidence, props []flexibee.Property) map[string]FieldUpdate {
+	settings := map[string]FieldUpdate{
+		"id":        {SemanticType: semanticPK, DisplayName: "ID"},
+		"raw_data":  {VisibilityType: visibilitySensitive},
+		"synced_at": {DisplayName: "Synced At", VisibilityType: visibilityDetailsOnly},
+	}
+
+	for _, prop := range props {
+		if _, ok := settings[prop.Name]; ok {
+			continue
+		}
+		settings[prop.Name] = FieldUpdate{
+			SemanticType: semanticType(ev, prop),
+			DisplayName:  prop.Title,
+		}
+	}
+	return settings
+}
+
+// semanticType derives a column's semantic type from its Flexibee property.
+// It returns "" to leave Metabase's own inference in place.
+func semanticType(ev registry.Evidence, prop flexibee.Property) string {
+	switch {
+	case prop.Name == ev.DateColumn && prop.Type == "date":
+		return semanticCreationDate
+	case prop.Name == ev.DateColumn && prop.Type == "datetime":
+		return semanticCreationTimestamp
+	case prop.Name == "lastUpdate":
+		return semanticUpdatedTimestamp
+	case prop.Type == "relation" && prop.FKEvidence != "":
+		return semanticFK
+	case prop.Type == "numeric" && hasCurrencyPrefix(prop.Name):
+		return semanticCurrency
+	case prop.Type == "select", prop.Type == "string" && len(prop.Name) > 1 && strings.HasSuffix(prop.Name, "K"):
+		// Enumerations: Flexibee suffixes their code properties with K,
+		// e.g. stavUhrK.
+		return semanticCategory
+	}
+	return ""
+}
+
+func hasCurrencyPrefix(name string) bool {
+	for _, p := range currencyPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsUpdate returns the part of want that differs from the field's
+// current metadata, and whether anything differs.
+func needsUpdate(f Field, want FieldUpdate) (FieldUpdate, bool) {
+	var diff FieldUpdate
+	if want.SemanticType != "" && want.SemanticType != f.SemanticType {
+		diff.SemanticType = want.SemanticType
+	}
+	if want.DisplayName != "" && want.DisplayName != f.DisplayName {
+		diff.DisplayName = want.DisplayName
+	}
+	if want.VisibilityType != "" && want.VisibilityType != f.VisibilityType {
+		diff.VisibilityType = want.VisibilityType
+	}
+	return diff, diff != FieldUpdate{}
+}
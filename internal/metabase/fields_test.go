@@ -0,0 +1,86 @@
+package metabase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func TestFieldSettings(t *testing.T) {
+	t.Parallel()
+
+	ev := registry.Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", DateColumn: "datVyst"}
+	settings := fieldSettings(ev, []flexibee.Property{
+		{Name: "id", Type: "integer", Title: "Identifikátor"},
+		{Name: "kod", Type: "string", Title: "Zkratka"},
+		{Name: "datVyst", Type: "date", Title: "Vystaveno"},
+		{Name: "lastUpdate", Type: "datetime"},
+		{Name: "firma", Type: "relation", FKEvidence: "adresar"},
+		{Name: "sumCelkem", Type: "numeric"},
+		{Name: "kurz", Type: "numeric"},
+		{Name: "stavUhrK", Type: "string"},
+		{Name: "typDokl", Type: "select"},
+	})
+
+	assert.Equal(t, FieldUpdate{SemanticType: semanticPK, DisplayName: "ID"}, settings["id"])
+	assert.Equal(t, FieldUpdate{VisibilityType: visibilitySensitive}, settings["raw_data"])
+	assert.Equal(t, visibilityDetailsOnly, settings["synced_at"].VisibilityType)
+	assert.Equal(t, FieldUpdate{DisplayName: "Zkratka"}, settings["kod"])
+	assert.Equal(t, FieldUpdate{SemanticType: semanticCreationDate, DisplayName: "Vystaveno"}, settings["datVyst"])
+	assert.Equal(t, semanticUpdatedTimestamp, settings["lastUpdate"].SemanticType)
+	assert.Equal(t, semanticFK, settings["firma"].SemanticType)
+	assert.Equal(t, semanticCurrency, settings["sumCelkem"].SemanticType)
+	assert.Empty(t, settings["kurz"].SemanticType)
+	assert.Equal(t, semanticCategory, settings["stavUhrK"].SemanticType)
+	assert.Equal(t, semanticCategory, settings["typDokl"].SemanticType)
+}
+
+func TestSemanticType_CreationTimestamp(t *testing.T) {
+	t.Parallel()
+
+	ev := registry.Evidence{DateColumn: "datCas"}
+	assert.Equal(t, semanticCreationTimestamp, semanticType(ev, flexibee.Property{Name: "datCas", Type: "datetime"}))
+	assert.Empty(t, semanticType(ev, flexibee.Property{Name: "firma", Type: "relation"}), "relations without a target stay as they are")
+}
+
+func TestNeedsUpdate(t *testing.T) {
+	t.Parallel()
+
+	f := Field{ID: 1, Name: "kod", DisplayName: "Kod", VisibilityType: "normal"}
+
+	_, ok := needsUpdate(f, FieldUpdate{DisplayName: "Kod"})
+	assert.False(t, ok)
+
+	diff, ok := needsUpdate(f, FieldUpdate{SemanticType: semanticCategory, DisplayName: "Kod"})
+	assert.True(t, ok)
+	assert.Equal(t, FieldUpdate{SemanticType: semanticCategory}, diff)
+}
+
+func TestDatabaseFor(t *testing.T) {
+	t.Parallel()
+
+	db, err := databaseFor("Flexibee", "postgres://user:secret@db:5433/flexibee?sslmode=require")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", db.Engine)
+	assert.Equal(t, map[string]any{
+		"host": "db", "port": 5433, "dbname": "flexibee",
+		"user": "user", "password": "secret", "ssl": true,
+	}, db.Details)
+
+	db, err = databaseFor("Flexibee", "mysql://user@db/flexibee")
+	require.NoError(t, err)
+	assert.Equal(t, "mysql", db.Engine)
+	assert.Equal(t, 3306, db.Details["port"])
+	assert.NotContains(t, db.Details, "password")
+
+	db, err = databaseFor("Flexibee", "sqlite:///data/flexibee.db")
+	require.NoError(t, err)
+	assert.Equal(t, NewDatabase{Name: "Flexibee", Engine: "sqlite", Details: map[string]any{"db": "/data/flexibee.db"}}, db)
+
+	_, err = databaseFor("Flexibee", "redis://localhost")
+	assert.Error(t, err)
+}
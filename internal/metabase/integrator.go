@@ -0,0 +1,190 @@
+package metabase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+// Config controls the Metabase integration.
+type Config struct {
+	// DatabaseName is the name of the synced database in Metabase.
+	DatabaseName string
+	// DatabaseURL is the synced database's URL as reachable from Metabase,
+	// used to register the database when it is missing.
+	DatabaseURL string
+}
+
+// Integrator keeps Metabase's metadata of the synced tables in line with
+// Flexibee: it registers the database, triggers schema syncs when columns
+// are added and sets semantic types and display names. It implements the
+// engine's Stage and SchemaObserver interfaces.
+type Integrator struct {
+	client *Client
+	config Config
+	logger *slog.Logger
+
+	tables []adaptersync.TableSchema
+	dbID   int
+	// pending is set while Metabase has not yet picked up every column,
+	// rescan while field values should be rescanned after the next sync.
+	pending bool
+	rescan  bool
+}
+
+// NewIntegrator creates a new Integrator.
+func NewIntegrator(client *Client, cfg Config, logger *slog.Logger) *Integrator {
+	return &Integrator{
+		client: client,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Name implements sync.Stage.
+func (i *Integrator) Name() string {
+	return "metabase"
+}
+
+// ObserveSchema implements sync.SchemaObserver.
+func (i *Integrator) ObserveSchema(tables []adaptersync.TableSchema) {
+	i.tables = tables
+}
+
+// Prepare registers the database if missing and applies field metadata to
+// the columns Metabase knows. If columns are missing, a schema sync is
+// started and the remaining metadata is applied after later sync passes.
+func (i *Integrator) Prepare(ctx context.Context) error {
+	created, err := i.ensureDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	missing, err := i.applyFieldSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	if missing > 0 && !created {
+		// Metabase syncs newly created databases by itself.
+		if err := i.client.SyncSchema(ctx, i.dbID); err != nil {
+			return err
+		}
+		i.logger.Info("started Metabase schema sync", "missing_fields", missing)
+	}
+	i.pending = missing > 0
+	i.rescan = missing > 0
+	return nil
+}
+
+// Run finishes what Prepare could not: it retries a failed Prepare, applies
+// metadata to columns Metabase picked up since and rescans field values
+// once all columns are known.
+func (i *Integrator) Run(ctx context.Context) error {
+	if i.dbID == 0 {
+		return i.Prepare(ctx)
+	}
+
+	if i.pending {
+		missing, err := i.applyFieldSettings(ctx)
+		if err != nil {
+			return err
+		}
+		if missing > 0 {
+			i.logger.Debug("waiting for Metabase schema sync", "missing_fields", missing)
+			return nil
+		}
+		i.pending = false
+	}
+
+	if i.rescan {
+		if err := i.client.RescanValues(ctx, i.dbID); err != nil {
+			return err
+		}
+		i.rescan = false
+		i.logger.Info("started Metabase field values rescan")
+	}
+	return nil
+}
+
+// ensureDatabase looks up the database by name and registers it if it does
+// not exist. It reports whether the database was created.
+func (i *Integrator) ensureDatabase(ctx context.Context) (bool, error) {
+	dbs, err := i.client.Databases(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, db := range dbs {
+		if db.Name == i.config.DatabaseName {
+			i.dbID = db.ID
+			return false, nil
+		}
+	}
+
+	payload, err := databaseFor(i.config.DatabaseName, i.config.DatabaseURL)
+	if err != nil {
+		return false, err
+	}
+	db, err := i.client.CreateDatabase(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+	i.dbID = db.ID
+	i.logger.Info("registered database in Metabase", "name", db.Name, "id", db.ID, "engine", payload.Engine)
+	return true, nil
+}
+
+// applyFieldSettings updates every known field whose metadata differs from
+// the desired settings. It returns the number of columns Metabase does not
+// know yet.
+func (i *Integrator) applyFieldSettings(ctx context.Context) (int, error) {
+	meta, err := i.client.Metadata(ctx, i.dbID)
+	if err != nil {
+		return 0, err
+	}
+
+	tables := make(map[string]Table, len(meta.Tables))
+	for _, t := range meta.Tables {
+		tables[t.Name] = t
+	}
+
+	var errs []error
+	missing, updated := 0, 0
+	for _, ts := range i.tables {
+		settings := fieldSettings(ts.Evidence, ts.Properties)
+
+		table, ok := tables[ts.Evidence.Table]
+		if !ok {
+			missing += len(settings)
+			continue
+		}
+		fields := make(map[string]Field, len(table.Fields))
+		for _, f := range table.Fields {
+			fields[f.Name] = f
+		}
+
+		for column, want := range settings {
+			f, ok := fields[column]
+			if !ok {
+				missing++
+				continue
+			}
+			diff, ok := needsUpdate(f, want)
+			if !ok {
+				continue
+			}
+			if err := i.client.UpdateField(ctx, f.ID, diff); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			updated++
+		}
+	}
+
+	if updated > 0 {
+		i.logger.Info("updated Metabase field metadata", "fields", updated)
+	}
+	return missing, errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package metabase
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	adaptersync "github.com/anaryk/metabase-flexibee-adapter/internal/sync"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeMetabase is a minimal Metabase API serving one database.
+type fakeMetabase struct {
+	mu       gosync.Mutex
+	dbs      []Database
+	created  []NewDatabase
+	meta     Metadata
+	updates  map[int]FieldUpdate
+	syncs    int
+	rescans  int
+	apiKeyOK bool
+}
+
+func (f *fakeMetabase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.apiKeyOK = r.Header.Get("x-api-key") == "secret"
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/database":
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": f.dbs})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/database":
+		var db NewDatabase
+		_ = json.NewDecoder(r.Body).Decode(&db)
+		f.created = append(f.created, db)
+		f.dbs = append(f.dbs, Database{ID: 7, Name: db.Name, Engine: db.Engine})
+		_ = json.NewEncoder(w).Encode(f.dbs[len(f.dbs)-1])
+	case r.Method == http.MethodGet && r.URL.Path == "/api/database/7/metadata":
+		_ = json.NewEncoder(w).Encode(f.meta)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/database/7/sync_schema":
+		f.syncs++
+	case r.Method == http.MethodPost && r.URL.Path == "/api/database/7/rescan_values":
+		f.rescans++
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/api/field/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/field/"))
+		var u FieldUpdate
+		_ = json.NewDecoder(r.Body).Decode(&u)
+		f.updates[id] = u
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestIntegrator(t *testing.T, fake *fakeMetabase) *Integrator {
+	t.Helper()
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	in := NewIntegrator(NewClient(srv.URL+"/", "secret"), Config{
+		DatabaseName: "Flexibee",
+		DatabaseURL:  "postgres://user:pass@db:5432/flexibee",
+	}, discardLogger)
+	in.ObserveSchema([]adaptersync.TableSchema{{
+		Evidence: registry.Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", DateColumn: "datVyst"},
+		Properties: []flexibee.Property{
+			{Name: "kod", Type: "string", Title: "Zkratka"},
+			{Name: "sumCelkem", Type: "numeric", Title: "Celkem"},
+		},
+	}})
+	return in
+}
+
+func TestIntegrator_RegistersDatabaseAndWaitsForSync(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeMetabase{updates: make(map[int]FieldUpdate)}
+	in := newTestIntegrator(t, fake)
+
+	require.NoError(t, in.Prepare(context.Background()))
+	require.Len(t, fake.created, 1)
+	assert.Equal(t, "postgres", fake.created[0].Engine)
+	assert.True(t, fake.apiKeyOK)
+	assert.Equal(t, 0, fake.syncs, "new databases are synced by Metabase itself")
+
+	// Metabase has not synced the table yet: nothing to apply or rescan.
+	require.NoError(t, in.Run(context.Background()))
+	assert.Empty(t, fake.updates)
+	assert.Equal(t, 0, fake.rescans)
+
+	fake.mu.Lock()
+	fake.meta = Metadata{Tables: []Table{{ID: 1, Name: "flexibee_faktura_vydana", Fields: []Field{
+		{ID: 10, Name: "id", SemanticType: semanticPK, DisplayName: "ID"},
+		{ID: 11, Name: "kod", DisplayName: "Kod"},
+		{ID: 12, Name: "sumCelkem", DisplayName: "Sum Celkem"},
+		{ID: 13, Name: "raw_data", DisplayName: "Raw Data", VisibilityType: "normal"},
+		{ID: 14, Name: "synced_at", DisplayName: "Synced At", VisibilityType: visibilityDetailsOnly},
+	}}}}
+	fake.mu.Unlock()
+
+	require.NoError(t, in.Run(context.Background()))
+	assert.Equal(t, map[int]FieldUpdate{
+		11: {DisplayName: "Zkratka"},
+		12: {SemanticType: semanticCurrency, DisplayName: "Celkem"},
+		13: {VisibilityType: visibilitySensitive},
+	}, fake.updates)
+	assert.Equal(t, 1, fake.rescans)
+
+	// Everything is in place: later passes do nothing.
+	require.NoError(t, in.Run(context.Background()))
+	assert.Equal(t, 1, fake.rescans)
+}
+
+func TestIntegrator_SyncsSchemaOfExistingDatabase(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeMetabase{
+		dbs:     []Database{{ID: 3, Name: "Sample"}, {ID: 7, Name: "Flexibee", Engine: "postgres"}},
+		updates: make(map[int]FieldUpdate),
+		meta: Metadata{Tables: []Table{{ID: 1, Name: "flexibee_faktura_vydana", Fields: []Field{
+			{ID: 10, Name: "id"},
+			{ID: 11, Name: "kod"},
+		}}}},
+	}
+	in := newTestIntegrator(t, fake)
+
+	require.NoError(t, in.Prepare(context.Background()))
+	assert.Empty(t, fake.created)
+	assert.Equal(t, 1, fake.syncs)
+	assert.Equal(t, map[int]FieldUpdate{
+		10: {SemanticType: semanticPK, DisplayName: "ID"},
+		11: {DisplayName: "Zkratka"},
+	}, fake.updates)
+}
+
+func TestClient_Databases_PlainList(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":1,"name":"Flexibee","engine":"postgres"}]`))
+	}))
+	t.Cleanup(srv.Close)
+
+	dbs, err := NewClient(srv.URL, "key").Databases(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Database{{ID: 1, Name: "Flexibee", Engine: "postgres"}}, dbs)
+}
+
+func TestClient_ErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	err := NewClient(srv.URL, "bad").SyncSchema(context.Background(), 1)
+	assert.ErrorContains(t, err, "401")
+}
@@ -0,0 +1,133 @@
+// Package metrics defines the Prometheus metrics of the adapter.
+//
+// The metrics are package-level collectors updated by the instrumented
+// packages and registered on Registry, which Handler serves.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes the names of all adapter metrics.
+const Namespace = "flexibee_adapter"
+
+// Registry holds the adapter metrics and the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+// Flexibee API
+var (
+	FlexibeeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "flexibee",
+		Name:      "request_duration_seconds",
+		Help:      `Duration of Flexibee API requests by evidence and HTTP status ("error" when no response arrived).`,
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"evidence", "status"})
+
+	FlexibeeRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "flexibee",
+		Name:      "retries_total",
+		Help:      "Flexibee API requests retried after a failure, by evidence.",
+	}, []string{"evidence"})
+)
+
+// Sync
+var (
+	RecordsFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "sync",
+		Name:      "records_fetched_total",
+		Help:      "Records fetched from Flexibee, by evidence.",
+	}, []string{"evidence"})
+
+	RecordsUpserted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "sync",
+		Name:      "records_upserted_total",
+		Help:      "Records written to the database, by evidence.",
+	}, []string{"evidence"})
+
+	RecordsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "sync",
+		Name:      "records_failed_total",
+		Help:      "Records fetched but not written to the database, by evidence.",
+	}, []string{"evidence"})
+
+	SyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "sync",
+		Name:      "duration_seconds",
+		Help:      `Duration of evidence syncs by evidence and status ("ok" or "error").`,
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 14), // 0.5s to about 2h
+	}, []string{"evidence", "status"})
+
+	LastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "sync",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful sync, by evidence.",
+	}, []string{"evidence"})
+)
+
+// Cleanup and reconciliation
+var (
+	CleanupDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "cleanup",
+		Name:      "deleted_records_total",
+		Help:      "Records removed by the retention cleanup, by evidence.",
+	}, []string{"evidence"})
+
+	ReconciliationDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "reconciliation",
+		Name:      "drift_periods",
+		Help:      "Periods whose counts or totals differ from Flexibee in the latest reconciliation, by evidence.",
+	}, []string{"evidence"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		FlexibeeRequestDuration,
+		FlexibeeRetries,
+		RecordsFetched,
+		RecordsUpserted,
+		RecordsFailed,
+		SyncDuration,
+		LastSuccessfulSync,
+		CleanupDeleted,
+		ReconciliationDrift,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB exposes the connection pool statistics of a database/sql
+// database as go_sql_* metrics labelled with name.
+func RegisterDB(db *sql.DB, name string) error {
+	return Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Register registers an additional collector unless an equal collector
+// already is.
+func Register(c prometheus.Collector) error {
+	err := Registry.Register(c)
+	if are := (prometheus.AlreadyRegisteredError{}); errors.As(err, &are) {
+		return nil
+	}
+	return err
+}
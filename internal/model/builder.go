@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// watermarkName is the model_state row tracking the star schema build.
+const watermarkName = "star_schema"
+
+// watermarkOverlap re-processes source rows synced shortly before the last
+// build, so rows committed late by a concurrent sync are not missed.
+// Reprocessing is harmless since every step is an upsert.
+const watermarkOverlap = 15 * time.Minute
+
+// Builder maintains a star schema (dim_* and fact_* tables) derived from
+// the synced tables. It implements the engine's Stage interface: tables
+// are created in Prepare and incrementally updated in Run.
+type Builder struct {
+	pool   *pgxpool.Pool
+	steps  []step
+	logger *slog.Logger
+}
+
+// NewBuilder creates a new star schema Builder.
+func NewBuilder(pool *pgxpool.Pool, logger *slog.Logger) *Builder {
+	return &Builder{
+		pool:   pool,
+		steps:  steps(),
+		logger: logger,
+	}
+}
+
+// Name implements sync.Stage.
+func (b *Builder) Name() string {
+	return "star_schema"
+}
+
+// Prepare creates the dimension and fact tables if they don't exist.
+func (b *Builder) Prepare(ctx context.Context) error {
+	for _, stmt := range schemaSQL {
+		if _, err := b.pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("create star schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run updates dimensions and facts from source rows synced since the last
+// run, in a single transaction. Steps whose source tables don't exist are
+// skipped.
+func (b *Builder) Run(ctx context.Context) error {
+	start := time.Now()
+
+	err := pgx.BeginFunc(ctx, b.pool, func(tx pgx.Tx) error {
+		var now time.Time
+		if err := tx.QueryRow(ctx, "SELECT NOW()").Scan(&now); err != nil {
+			return fmt.Errorf("read database time: %w", err)
+		}
+
+		since := time.Time{}
+		var watermark time.Time
+		err := tx.QueryRow(ctx, "SELECT watermark FROM model_state WHERE name = $1", watermarkName).Scan(&watermark)
+		switch {
+		case err == nil:
+			since = watermark.Add(-watermarkOverlap)
+		case !errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("read watermark: %w", err)
+		}
+
+		for _, st := range b.steps {
+			missing, err := missingTables(ctx, tx, st.Tables)
+			if err != nil {
+				return err
+			}
+			if len(missing) > 0 {
+				b.logger.Debug("skipping model step, tables missing", "step", st.Name, "tables", missing)
+				continue
+			}
+
+			var args []any
+			if st.Incremental {
+				args = append(args, since)
+			}
+			tag, err := tx.Exec(ctx, st.SQL, args...)
+			if err != nil {
+				return fmt.Errorf("model step %s: %w", st.Name, err)
+			}
+			b.logger.Debug("model step complete", "step", st.Name, "rows", tag.RowsAffected())
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO model_state (name, watermark) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET watermark = $2
+		`, watermarkName, now)
+		if err != nil {
+			return fmt.Errorf("save watermark: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b.logger.Info("star schema updated", "duration", time.Since(start))
+	return nil
+}
+
+func missingTables(ctx context.Context, tx pgx.Tx, tables []string) ([]string, error) {
+	var missing []string
+	for _, t := range tables {
+		var exists bool
+		if err := tx.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", t).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("check table %s: %w", t, err)
+		}
+		if !exists {
+			missing = append(missing, t)
+		}
+	}
+	return missing, nil
+}
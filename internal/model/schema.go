@@ -0,0 +1,121 @@
+package model
+
+// schemaSQL creates the dimension and fact tables of the star schema.
+// Every table has a BIGSERIAL surrogate key and a unique flexibee_id
+// natural key. Facts keep the codes of the dimension members they point to
+// so that keys of late-arriving dimension rows can be filled in later.
+var schemaSQL = []string{
+	`CREATE TABLE IF NOT EXISTS model_state (
+		name      TEXT PRIMARY KEY,
+		watermark TIMESTAMPTZ NOT NULL
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS dim_customer (
+		customer_key BIGSERIAL PRIMARY KEY,
+		flexibee_id  BIGINT NOT NULL UNIQUE,
+		code         TEXT,
+		name         TEXT,
+		ico          TEXT,
+		dic          TEXT,
+		city         TEXT,
+		country      TEXT,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS dim_customer_code_idx ON dim_customer (code)`,
+
+	`CREATE TABLE IF NOT EXISTS dim_product (
+		product_key   BIGSERIAL PRIMARY KEY,
+		flexibee_id   BIGINT NOT NULL UNIQUE,
+		code          TEXT,
+		name          TEXT,
+		product_group TEXT,
+		unit          TEXT,
+		stock_type    TEXT,
+		updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS dim_product_code_idx ON dim_product (code)`,
+
+	`CREATE TABLE IF NOT EXISTS dim_cost_center (
+		cost_center_key BIGSERIAL PRIMARY KEY,
+		flexibee_id     BIGINT NOT NULL UNIQUE,
+		code            TEXT,
+		name            TEXT,
+		updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS dim_cost_center_code_idx ON dim_cost_center (code)`,
+
+	`CREATE TABLE IF NOT EXISTS dim_project (
+		project_key BIGSERIAL PRIMARY KEY,
+		flexibee_id BIGINT NOT NULL UNIQUE,
+		code        TEXT,
+		name        TEXT,
+		updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS dim_project_code_idx ON dim_project (code)`,
+
+	`CREATE TABLE IF NOT EXISTS dim_date (
+		date_key     INTEGER PRIMARY KEY,
+		date         DATE NOT NULL UNIQUE,
+		year         INTEGER NOT NULL,
+		quarter      INTEGER NOT NULL,
+		month        INTEGER NOT NULL,
+		day          INTEGER NOT NULL,
+		iso_week     INTEGER NOT NULL,
+		day_of_week  INTEGER NOT NULL,
+		is_weekend   BOOLEAN NOT NULL
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS fact_sales_lines (
+		sales_line_key   BIGSERIAL PRIMARY KEY,
+		flexibee_id      BIGINT NOT NULL UNIQUE,
+		invoice_id       BIGINT,
+		invoice_number   TEXT,
+		date_key         INTEGER,
+		customer_key     BIGINT,
+		product_key      BIGINT,
+		cost_center_key  BIGINT,
+		project_key      BIGINT,
+		customer_code    TEXT,
+		product_code     TEXT,
+		cost_center_code TEXT,
+		project_code     TEXT,
+		currency         TEXT,
+		quantity         NUMERIC,
+		unit_price       NUMERIC,
+		amount_net       NUMERIC,
+		amount_total     NUMERIC,
+		updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS fact_sales_lines_date_key_idx ON fact_sales_lines (date_key)`,
+	// Rows still waiting for a late dimension member (see lateKeyFix).
+	`CREATE INDEX IF NOT EXISTS fact_sales_lines_customer_missing_idx ON fact_sales_lines (customer_code) WHERE customer_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_sales_lines_product_missing_idx ON fact_sales_lines (product_code) WHERE product_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_sales_lines_cost_center_missing_idx ON fact_sales_lines (cost_center_code) WHERE cost_center_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_sales_lines_project_missing_idx ON fact_sales_lines (project_code) WHERE project_key IS NULL`,
+
+	`CREATE TABLE IF NOT EXISTS fact_stock_movements (
+		stock_movement_key BIGSERIAL PRIMARY KEY,
+		flexibee_id        BIGINT NOT NULL UNIQUE,
+		document_id        BIGINT,
+		document_number    TEXT,
+		date_key           INTEGER,
+		product_key        BIGINT,
+		customer_key       BIGINT,
+		cost_center_key    BIGINT,
+		project_key        BIGINT,
+		product_code       TEXT,
+		customer_code      TEXT,
+		cost_center_code   TEXT,
+		project_code       TEXT,
+		warehouse_code     TEXT,
+		direction          TEXT,
+		quantity           NUMERIC,
+		amount_total       NUMERIC,
+		updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`,
+	`CREATE INDEX IF NOT EXISTS fact_stock_movements_date_key_idx ON fact_stock_movements (date_key)`,
+	`CREATE INDEX IF NOT EXISTS fact_stock_movements_product_missing_idx ON fact_stock_movements (product_code) WHERE product_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_stock_movements_customer_missing_idx ON fact_stock_movements (customer_code) WHERE customer_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_stock_movements_cost_center_missing_idx ON fact_stock_movements (cost_center_code) WHERE cost_center_key IS NULL`,
+	`CREATE INDEX IF NOT EXISTS fact_stock_movements_project_missing_idx ON fact_stock_movements (project_code) WHERE project_key IS NULL`,
+}
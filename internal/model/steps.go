@@ -0,0 +1,232 @@
+package model
+
+import "fmt"
+
+// step is one statement of the modelling stage.
+type step struct {
+	Name   string
+	Tables []string // source tables that must exist for the step to run
+	SQL    string
+	// Incremental steps take the synced_at timestamp after which source
+	// rows are (re)processed as $1.
+	Incremental bool
+}
+
+// lookupKey returns a lateral join resolving the surrogate key of dim for
+// the member with the given code expression.
+func lookupKey(dim, key, alias, codeExpr string) string {
+	return fmt.Sprintf(
+		"LEFT JOIN LATERAL (SELECT %[2]s FROM %[1]s WHERE code = %[4]s ORDER BY %[2]s DESC LIMIT 1) %[3]s ON TRUE",
+		dim, key, alias, codeExpr,
+	)
+}
+
+// changedLines returns a changed CTE with the ids of document lines synced
+// since $1 or belonging to a header synced since $1. The two cases are
+// separate selects so that each is driven by its table's synced_at index;
+// lines are found from their header through the index on the relation
+// column ref, which holds the header code with or without a "code:" prefix.
+func changedLines(lines, headers, ref string) string {
+	return fmt.Sprintf(`
+				WITH changed AS (
+					SELECT p.id FROM %[1]s p WHERE p.synced_at > $1
+					UNION
+					SELECT p.id FROM %[2]s h
+					JOIN %[1]s p ON p.%[3]s IN (h."kod", 'code:' || h."kod")
+					WHERE h.synced_at > $1
+				)`,
+		lines, headers, ref,
+	)
+}
+
+// lateKeyFix fills in the surrogate keys of fact rows whose dimension
+// member had not been synced yet when the fact row was built. Only members
+// updated since $1 can resolve a missing key (fact rows get the keys of
+// existing members when they are built), and the fact rows still missing
+// the key are found through a partial index (see schemaSQL).
+func lateKeyFix(fact, dim, key, codeColumn string) step {
+	return step{
+		Name:        fmt.Sprintf("%s.%s", fact, key),
+		Incremental: true,
+		SQL: fmt.Sprintf(
+			"UPDATE %[1]s f SET %[3]s = d.%[3]s FROM %[2]s d WHERE d.updated_at > $1 AND f.%[3]s IS NULL AND f.%[4]s = d.code",
+			fact, dim, key, codeColumn,
+		),
+	}
+}
+
+// steps returns the modelling statements in execution order: dimensions
+// first, then facts, then late-arriving key fixes and the date dimension.
+func steps() []step {
+	return []step{
+		{
+			Name:        "dim_customer",
+			Incremental: true,
+			Tables:      []string{"flexibee_adresar"},
+			SQL: `
+				INSERT INTO dim_customer (flexibee_id, code, name, ico, dic, city, country, updated_at)
+				SELECT a.id, a."kod", a."nazev", a."ic", a."dic", a."mesto", flexibee_relation_code(a."stat"), NOW()
+				FROM flexibee_adresar a
+				WHERE a.synced_at > $1
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					code = EXCLUDED.code, name = EXCLUDED.name, ico = EXCLUDED.ico, dic = EXCLUDED.dic,
+					city = EXCLUDED.city, country = EXCLUDED.country, updated_at = NOW()`,
+		},
+		{
+			Name:        "dim_product",
+			Incremental: true,
+			Tables:      []string{"flexibee_cenik"},
+			SQL: `
+				INSERT INTO dim_product (flexibee_id, code, name, product_group, unit, stock_type, updated_at)
+				SELECT c.id, c."kod", c."nazev", flexibee_relation_code(c."skupZboz"),
+					flexibee_relation_code(c."mj1"), c."typZasobyK", NOW()
+				FROM flexibee_cenik c
+				WHERE c.synced_at > $1
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					code = EXCLUDED.code, name = EXCLUDED.name, product_group = EXCLUDED.product_group,
+					unit = EXCLUDED.unit, stock_type = EXCLUDED.stock_type, updated_at = NOW()`,
+		},
+		{
+			Name:        "dim_cost_center",
+			Incremental: true,
+			Tables:      []string{"flexibee_stredisko"},
+			SQL: `
+				INSERT INTO dim_cost_center (flexibee_id, code, name, updated_at)
+				SELECT s.id, s."kod", s."nazev", NOW()
+				FROM flexibee_stredisko s
+				WHERE s.synced_at > $1
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					code = EXCLUDED.code, name = EXCLUDED.name, updated_at = NOW()`,
+		},
+		{
+			Name:        "dim_project",
+			Incremental: true,
+			Tables:      []string{"flexibee_zakazka"},
+			SQL: `
+				INSERT INTO dim_project (flexibee_id, code, name, updated_at)
+				SELECT z.id, z."kod", z."nazev", NOW()
+				FROM flexibee_zakazka z
+				WHERE z.synced_at > $1
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					code = EXCLUDED.code, name = EXCLUDED.name, updated_at = NOW()`,
+		},
+		{
+			Name:        "fact_sales_lines",
+			Incremental: true,
+			Tables:      []string{"flexibee_faktura_vydana_polozka", "flexibee_faktura_vydana"},
+			SQL: changedLines("flexibee_faktura_vydana_polozka", "flexibee_faktura_vydana", `"doklFak"`) + `
+				INSERT INTO fact_sales_lines (
+					flexibee_id, invoice_id, invoice_number, date_key,
+					customer_key, product_key, cost_center_key, project_key,
+					customer_code, product_code, cost_center_code, project_code,
+					currency, quantity, unit_price, amount_net, amount_total, updated_at
+				)
+				SELECT
+					p.id, h.id, h."kod", to_char(h."datVyst", 'YYYYMMDD')::integer,
+					c.customer_key, pr.product_key, cc.cost_center_key, pj.project_key,
+					src.customer_code, src.product_code, src.cost_center_code, src.project_code,
+					flexibee_relation_code(h."mena"), p."mnozMj", p."cenaMj", p."sumZkl", p."sumCelkem", NOW()
+				FROM changed
+				JOIN flexibee_faktura_vydana_polozka p ON p.id = changed.id
+				LEFT JOIN flexibee_faktura_vydana h ON h."kod" = flexibee_relation_code(p."doklFak")
+				CROSS JOIN LATERAL (SELECT
+					flexibee_relation_code(h."firma") AS customer_code,
+					flexibee_relation_code(p."cenik") AS product_code,
+					flexibee_relation_code(COALESCE(p."stredisko", h."stredisko")) AS cost_center_code,
+					flexibee_relation_code(COALESCE(p."zakazka", h."zakazka")) AS project_code
+				) src
+				` + lookupKey("dim_customer", "customer_key", "c", "src.customer_code") + `
+				` + lookupKey("dim_product", "product_key", "pr", "src.product_code") + `
+				` + lookupKey("dim_cost_center", "cost_center_key", "cc", "src.cost_center_code") + `
+				` + lookupKey("dim_project", "project_key", "pj", "src.project_code") + `
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					invoice_id = EXCLUDED.invoice_id, invoice_number = EXCLUDED.invoice_number,
+					date_key = EXCLUDED.date_key, customer_key = EXCLUDED.customer_key,
+					product_key = EXCLUDED.product_key, cost_center_key = EXCLUDED.cost_center_key,
+					project_key = EXCLUDED.project_key, customer_code = EXCLUDED.customer_code,
+					product_code = EXCLUDED.product_code, cost_center_code = EXCLUDED.cost_center_code,
+					project_code = EXCLUDED.project_code, currency = EXCLUDED.currency,
+					quantity = EXCLUDED.quantity, unit_price = EXCLUDED.unit_price,
+					amount_net = EXCLUDED.amount_net, amount_total = EXCLUDED.amount_total, updated_at = NOW()`,
+		},
+		{
+			Name:        "fact_stock_movements",
+			Incremental: true,
+			Tables:      []string{"flexibee_skladovy_pohyb_polozka", "flexibee_skladovy_pohyb"},
+			SQL: changedLines("flexibee_skladovy_pohyb_polozka", "flexibee_skladovy_pohyb", `"doklSklad"`) + `
+				INSERT INTO fact_stock_movements (
+					flexibee_id, document_id, document_number, date_key,
+					product_key, customer_key, cost_center_key, project_key,
+					product_code, customer_code, cost_center_code, project_code,
+					warehouse_code, direction, quantity, amount_total, updated_at
+				)
+				SELECT
+					p.id, h.id, h."kod", to_char(h."datVyst", 'YYYYMMDD')::integer,
+					pr.product_key, c.customer_key, cc.cost_center_key, pj.project_key,
+					src.product_code, src.customer_code, src.cost_center_code, src.project_code,
+					flexibee_relation_code(p."sklad"),
+					CASE h."typPohybuK"
+						WHEN 'typPohybu.prijem' THEN 'receipt'
+						WHEN 'typPohybu.vydej' THEN 'issue'
+						ELSE h."typPohybuK"
+					END,
+					p."mnozMj", p."sumCelkem", NOW()
+				FROM changed
+				JOIN flexibee_skladovy_pohyb_polozka p ON p.id = changed.id
+				LEFT JOIN flexibee_skladovy_pohyb h ON h."kod" = flexibee_relation_code(p."doklSklad")
+				CROSS JOIN LATERAL (SELECT
+					flexibee_relation_code(p."cenik") AS product_code,
+					flexibee_relation_code(h."firma") AS customer_code,
+					flexibee_relation_code(h."stredisko") AS cost_center_code,
+					flexibee_relation_code(h."zakazka") AS project_code
+				) src
+				` + lookupKey("dim_product", "product_key", "pr", "src.product_code") + `
+				` + lookupKey("dim_customer", "customer_key", "c", "src.customer_code") + `
+				` + lookupKey("dim_cost_center", "cost_center_key", "cc", "src.cost_center_code") + `
+				` + lookupKey("dim_project", "project_key", "pj", "src.project_code") + `
+				ON CONFLICT (flexibee_id) DO UPDATE SET
+					document_id = EXCLUDED.document_id, document_number = EXCLUDED.document_number,
+					date_key = EXCLUDED.date_key, product_key = EXCLUDED.product_key,
+					customer_key = EXCLUDED.customer_key, cost_center_key = EXCLUDED.cost_center_key,
+					project_key = EXCLUDED.project_key, product_code = EXCLUDED.product_code,
+					customer_code = EXCLUDED.customer_code, cost_center_code = EXCLUDED.cost_center_code,
+					project_code = EXCLUDED.project_code, warehouse_code = EXCLUDED.warehouse_code,
+					direction = EXCLUDED.direction, quantity = EXCLUDED.quantity,
+					amount_total = EXCLUDED.amount_total, updated_at = NOW()`,
+		},
+
+		lateKeyFix("fact_sales_lines", "dim_customer", "customer_key", "customer_code"),
+		lateKeyFix("fact_sales_lines", "dim_product", "product_key", "product_code"),
+		lateKeyFix("fact_sales_lines", "dim_cost_center", "cost_center_key", "cost_center_code"),
+		lateKeyFix("fact_sales_lines", "dim_project", "project_key", "project_code"),
+		lateKeyFix("fact_stock_movements", "dim_product", "product_key", "product_code"),
+		lateKeyFix("fact_stock_movements", "dim_customer", "customer_key", "customer_code"),
+		lateKeyFix("fact_stock_movements", "dim_cost_center", "cost_center_key", "cost_center_code"),
+		lateKeyFix("fact_stock_movements", "dim_project", "project_key", "project_code"),
+
+		{
+			Name: "dim_date",
+			SQL: `
+				INSERT INTO dim_date (date_key, date, year, quarter, month, day, iso_week, day_of_week, is_weekend)
+				SELECT
+					to_char(d, 'YYYYMMDD')::integer, d,
+					EXTRACT(YEAR FROM d)::integer, EXTRACT(QUARTER FROM d)::integer,
+					EXTRACT(MONTH FROM d)::integer, EXTRACT(DAY FROM d)::integer,
+					EXTRACT(WEEK FROM d)::integer, EXTRACT(ISODOW FROM d)::integer,
+					EXTRACT(ISODOW FROM d) >= 6
+				FROM (
+					SELECT g::date AS d
+					FROM generate_series(
+						(SELECT COALESCE(to_date(MIN(date_key)::text, 'YYYYMMDD'), CURRENT_DATE) FROM (
+							SELECT MIN(date_key) AS date_key FROM fact_sales_lines
+							UNION ALL
+							SELECT MIN(date_key) FROM fact_stock_movements
+						) k),
+						CURRENT_DATE + INTERVAL '1 year',
+						INTERVAL '1 day'
+					) AS g
+				) dates
+				ON CONFLICT (date_key) DO NOTHING`,
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSteps_Definitions(t *testing.T) {
+	t.Parallel()
+
+	names := make(map[string]bool)
+	for _, st := range steps() {
+		assert.False(t, names[st.Name], "duplicate step %s", st.Name)
+		names[st.Name] = true
+
+		assert.Equal(t, st.Incremental, strings.Contains(st.SQL, "$1"),
+			"step %s: only incremental steps take the watermark parameter", st.Name)
+		for _, table := range st.Tables {
+			assert.Contains(t, st.SQL, table, "step %s lists table %s it does not read", st.Name, table)
+		}
+	}
+
+	for _, name := range []string{
+		"dim_customer", "dim_product", "dim_cost_center", "dim_project", "dim_date",
+		"fact_sales_lines", "fact_stock_movements",
+	} {
+		assert.True(t, names[name], "missing step %s", name)
+	}
+}
+
+func TestSteps_DimensionsBeforeFacts(t *testing.T) {
+	t.Parallel()
+
+	order := make(map[string]int)
+	for i, st := range steps() {
+		order[st.Name] = i
+	}
+	for _, dim := range []string{"dim_customer", "dim_product", "dim_cost_center", "dim_project"} {
+		assert.Less(t, order[dim], order["fact_sales_lines"])
+		assert.Less(t, order[dim], order["fact_stock_movements"])
+	}
+	assert.Greater(t, order["dim_date"], order["fact_stock_movements"], "dim_date covers the fact date range")
+}
+
+func TestSchemaSQL_CoversSteps(t *testing.T) {
+	t.Parallel()
+
+	schema := strings.Join(schemaSQL, "\n")
+	for _, st := range steps() {
+		table, _, _ := strings.Cut(st.Name, ".")
+		assert.Contains(t, schema, "CREATE TABLE IF NOT EXISTS "+table+" ", "no table for step %s", st.Name)
+	}
+}
+
+func TestLateKeyFix(t *testing.T) {
+	t.Parallel()
+
+	st := lateKeyFix("fact_sales_lines", "dim_customer", "customer_key", "customer_code")
+	assert.Equal(t, "fact_sales_lines.customer_key", st.Name)
+	assert.Equal(t,
+		"UPDATE fact_sales_lines f SET customer_key = d.customer_key FROM dim_customer d WHERE d.updated_at > $1 AND f.customer_key IS NULL AND f.customer_code = d.code",
+		st.SQL)
+	assert.True(t, st.Incremental)
+}
+
+func TestSchemaSQL_IndexesMissingKeys(t *testing.T) {
+	t.Parallel()
+
+	schema := strings.Join(schemaSQL, "\n")
+	for _, st := range steps() {
+		fact, key, ok := strings.Cut(st.Name, ".")
+		if !ok {
+			continue
+		}
+		assert.Contains(t, schema, "ON "+fact+" ("+strings.TrimSuffix(key, "_key")+"_code) WHERE "+key+" IS NULL",
+			"no partial index for step %s", st.Name)
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	t.Parallel()
+
+	// No OR across the join, which would keep the synced_at indexes unused.
+	sql := changedLines("flexibee_faktura_vydana_polozka", "flexibee_faktura_vydana", `"doklFak"`)
+	assert.NotContains(t, sql, " OR ")
+	assert.Contains(t, sql, "SELECT p.id FROM flexibee_faktura_vydana_polozka p WHERE p.synced_at > $1")
+	assert.Contains(t, sql, `JOIN flexibee_faktura_vydana_polozka p ON p."doklFak" IN (h."kod", 'code:' || h."kod")`)
+}
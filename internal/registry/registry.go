@@ -1,11 +1,26 @@
 package registry
 
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
 // Evidence describes a Flexibee evidence type and its mapping to PostgreSQL.
 type Evidence struct {
-	Slug         string // Flexibee evidence slug (e.g. "prodejka")
-	Table        string // PostgreSQL table name (e.g. "flexibee_prodejka")
-	PrimaryKey   string // Primary key field (always "id")
-	IsMasterData bool   // Master/reference data - never cleaned up
+	Slug         string     // Flexibee evidence slug (e.g. "prodejka")
+	Table        string     // PostgreSQL table name (e.g. "flexibee_prodejka")
+	PrimaryKey   string     // Primary key field (always "id")
+	IsMasterData bool       // Master/reference data - never cleaned up
+	History      bool       // Keep SCD type 2 versions in <table>_history
+	DateColumn   string     // Document date column (e.g. "datVyst"), empty if none
+	Partitioned  bool       // Range-partition the table by month on DateColumn
+	Indexes      [][]string // Extra indexes, each given by its columns
+	SumColumns   []string   // Monetary document totals, reconciled and converted to the base currency
+	DependsOn    []string   // Slugs of evidences synced before this one
+	Priority     int        // Among evidences ready to sync, higher priorities go first
+	Schedule     string     // Own sync interval or cron expression; empty = every sync interval
 }
 
 // Registry holds all registered evidence types.
@@ -29,6 +44,18 @@ func (r *Registry) Register(ev Evidence) {
 	r.evidences[ev.Slug] = ev
 }
 
+// Update applies fn to a registered evidence type in place.
+// Returns false if the slug is not registered.
+func (r *Registry) Update(slug string, fn func(*Evidence)) bool {
+	ev, ok := r.evidences[slug]
+	if !ok {
+		return false
+	}
+	fn(&ev)
+	r.evidences[slug] = ev
+	return true
+}
+
 // Get returns an evidence type by slug.
 func (r *Registry) Get(slug string) (Evidence, bool) {
 	ev, ok := r.evidences[slug]
@@ -44,6 +71,63 @@ func (r *Registry) All() []Evidence {
 	return result
 }
 
+// Waves groups the registered evidence types into waves to be synced one
+// after another. An evidence is ready once every evidence it depends on is
+// in an earlier wave; master data is an implicit dependency of all other
+// evidences. Each wave holds the ready evidences of the highest priority,
+// in registration order.
+//
+// Returns an error if a dependency is not registered or dependencies form
+// a cycle.
+func (r *Registry) Waves() ([][]Evidence, error) {
+	deps := make(map[string][]string, len(r.order))
+	for _, slug := range r.order {
+		ev := r.evidences[slug]
+		for _, dep := range ev.DependsOn {
+			if _, ok := r.evidences[dep]; !ok {
+				return nil, fmt.Errorf("evidence %s depends on unknown evidence %s", slug, dep)
+			}
+			deps[slug] = append(deps[slug], dep)
+		}
+		if ev.IsMasterData {
+			continue
+		}
+		for _, other := range r.order {
+			if r.evidences[other].IsMasterData {
+				deps[slug] = append(deps[slug], other)
+			}
+		}
+	}
+
+	done := make(map[string]bool, len(r.order))
+	var waves [][]Evidence
+	for len(done) < len(r.order) {
+		var ready []Evidence
+		for _, slug := range r.order {
+			if !done[slug] && !slices.ContainsFunc(deps[slug], func(dep string) bool { return !done[dep] }) {
+				ready = append(ready, r.evidences[slug])
+			}
+		}
+		if len(ready) == 0 {
+			var blocked []string
+			for _, slug := range r.order {
+				if !done[slug] {
+					blocked = append(blocked, slug)
+				}
+			}
+			return nil, fmt.Errorf("dependency cycle among evidences %s", strings.Join(blocked, ", "))
+		}
+
+		top := slices.MaxFunc(ready, func(a, b Evidence) int { return cmp.Compare(a.Priority, b.Priority) }).Priority
+		wave := slices.DeleteFunc(ready, func(ev Evidence) bool { return ev.Priority < top })
+		for _, ev := range wave {
+			done[ev.Slug] = true
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
 // Len returns the number of registered evidence types.
 func (r *Registry) Len() int {
 	return len(r.evidences)
@@ -53,33 +137,39 @@ func (r *Registry) Len() int {
 func NewDefault() *Registry {
 	r := New()
 
+	// Document totals reconciled against Flexibee and converted to the
+	// base currency; each has a foreign currency counterpart <column>Men.
+	totals := []string{"sumCelkem"}
+
 	// Sales & Invoicing (transactional)
-	r.Register(Evidence{Slug: "prodejka", Table: "flexibee_prodejka", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "faktura-prijata", Table: "flexibee_faktura_prijata", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "pohledavka", Table: "flexibee_pohledavka", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "zavazek", Table: "flexibee_zavazek", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "prodejka", Table: "flexibee_prodejka", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
+	r.Register(Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
+	r.Register(Evidence{Slug: "faktura-vydana-polozka", Table: "flexibee_faktura_vydana_polozka", PrimaryKey: "id", DependsOn: []string{"faktura-vydana"}})
+	r.Register(Evidence{Slug: "faktura-prijata", Table: "flexibee_faktura_prijata", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
+	r.Register(Evidence{Slug: "pohledavka", Table: "flexibee_pohledavka", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
+	r.Register(Evidence{Slug: "zavazek", Table: "flexibee_zavazek", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
 
 	// Orders (transactional)
-	r.Register(Evidence{Slug: "objednavka-prijata", Table: "flexibee_objednavka_prijata", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "objednavka-vydana", Table: "flexibee_objednavka_vydana", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "nabidka-vydana", Table: "flexibee_nabidka_vydana", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "nabidka-prijata", Table: "flexibee_nabidka_prijata", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "poptavka-vydana", Table: "flexibee_poptavka_vydana", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "poptavka-prijata", Table: "flexibee_poptavka_prijata", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "objednavka-prijata", Table: "flexibee_objednavka_prijata", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "objednavka-vydana", Table: "flexibee_objednavka_vydana", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "nabidka-vydana", Table: "flexibee_nabidka_vydana", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "nabidka-prijata", Table: "flexibee_nabidka_prijata", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "poptavka-vydana", Table: "flexibee_poptavka_vydana", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "poptavka-prijata", Table: "flexibee_poptavka_prijata", PrimaryKey: "id", DateColumn: "datVyst"})
 
 	// Inventory
 	r.Register(Evidence{Slug: "sklad", Table: "flexibee_sklad", PrimaryKey: "id", IsMasterData: true})
-	r.Register(Evidence{Slug: "skladovy-pohyb", Table: "flexibee_skladovy_pohyb", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "skladovy-pohyb", Table: "flexibee_skladovy_pohyb", PrimaryKey: "id", DateColumn: "datVyst"})
+	r.Register(Evidence{Slug: "skladovy-pohyb-polozka", Table: "flexibee_skladovy_pohyb_polozka", PrimaryKey: "id", DependsOn: []string{"skladovy-pohyb"}})
 	r.Register(Evidence{Slug: "skladova-karta", Table: "flexibee_skladova_karta", PrimaryKey: "id", IsMasterData: true})
 
 	// Contacts (master data)
 	r.Register(Evidence{Slug: "adresar", Table: "flexibee_adresar", PrimaryKey: "id", IsMasterData: true})
-	r.Register(Evidence{Slug: "kontakt", Table: "flexibee_kontakt", PrimaryKey: "id", IsMasterData: true})
+	r.Register(Evidence{Slug: "kontakt", Table: "flexibee_kontakt", PrimaryKey: "id", IsMasterData: true, DependsOn: []string{"adresar"}})
 
 	// Cash & Banking
-	r.Register(Evidence{Slug: "banka", Table: "flexibee_banka", PrimaryKey: "id"})
-	r.Register(Evidence{Slug: "pokladni-pohyb", Table: "flexibee_pokladni_pohyb", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "banka", Table: "flexibee_banka", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
+	r.Register(Evidence{Slug: "pokladni-pohyb", Table: "flexibee_pokladni_pohyb", PrimaryKey: "id", DateColumn: "datVyst", SumColumns: totals})
 	r.Register(Evidence{Slug: "bankovni-ucet", Table: "flexibee_bankovni_ucet", PrimaryKey: "id", IsMasterData: true})
 	r.Register(Evidence{Slug: "pokladna", Table: "flexibee_pokladna", PrimaryKey: "id", IsMasterData: true})
 
@@ -94,7 +184,7 @@ func NewDefault() *Registry {
 	r.Register(Evidence{Slug: "cinnost", Table: "flexibee_cinnost", PrimaryKey: "id", IsMasterData: true})
 	r.Register(Evidence{Slug: "ucet", Table: "flexibee_ucet", PrimaryKey: "id", IsMasterData: true})
 	r.Register(Evidence{Slug: "sazba-dph", Table: "flexibee_sazba_dph", PrimaryKey: "id", IsMasterData: true})
-	r.Register(Evidence{Slug: "kurz", Table: "flexibee_kurz", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "kurz", Table: "flexibee_kurz", PrimaryKey: "id", DateColumn: "platiOdData"})
 
 	// Contracts (transactional)
 	r.Register(Evidence{Slug: "smlouva", Table: "flexibee_smlouva", PrimaryKey: "id"})
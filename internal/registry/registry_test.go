@@ -72,6 +72,21 @@ func TestRegistry_DuplicateRegister(t *testing.T) {
 	assert.Equal(t, "t_a_updated", ev.Table)
 }
 
+func TestRegistry_Update(t *testing.T) {
+	t.Parallel()
+
+	r := New()
+	r.Register(Evidence{Slug: "a", Table: "t_a", PrimaryKey: "id"})
+
+	ok := r.Update("a", func(ev *Evidence) { ev.History = true })
+	require.True(t, ok)
+	ev, _ := r.Get("a")
+	assert.True(t, ev.History)
+
+	assert.False(t, r.Update("missing", func(ev *Evidence) { ev.History = true }))
+	assert.Equal(t, 1, r.Len())
+}
+
 func TestNewDefault_Completeness(t *testing.T) {
 	t.Parallel()
 
@@ -79,12 +94,12 @@ func TestNewDefault_Completeness(t *testing.T) {
 
 	expectedSlugs := []string{
 		// Sales & Invoicing
-		"prodejka", "faktura-vydana", "faktura-prijata", "pohledavka", "zavazek",
+		"prodejka", "faktura-vydana", "faktura-vydana-polozka", "faktura-prijata", "pohledavka", "zavazek",
 		// Orders
 		"objednavka-prijata", "objednavka-vydana", "nabidka-vydana",
 		"nabidka-prijata", "poptavka-vydana", "poptavka-prijata",
 		// Inventory
-		"sklad", "skladovy-pohyb", "skladova-karta",
+		"sklad", "skladovy-pohyb", "skladovy-pohyb-polozka", "skladova-karta",
 		// Contacts
 		"adresar", "kontakt",
 		// Cash & Banking
@@ -124,8 +139,8 @@ func TestNewDefault_MasterDataFlags(t *testing.T) {
 	}
 
 	transactional := []string{
-		"prodejka", "faktura-vydana", "faktura-prijata",
-		"banka", "pokladni-pohyb", "skladovy-pohyb", "kurz",
+		"prodejka", "faktura-vydana", "faktura-vydana-polozka", "faktura-prijata",
+		"banka", "pokladni-pohyb", "skladovy-pohyb", "skladovy-pohyb-polozka", "kurz",
 		"smlouva", "dodavatelska-smlouva",
 	}
 
@@ -139,3 +154,72 @@ func TestNewDefault_MasterDataFlags(t *testing.T) {
 		assert.False(t, ev.IsMasterData, "%s should be transactional", slug)
 	}
 }
+
+func waveSlugs(waves [][]Evidence) [][]string {
+	out := make([][]string, len(waves))
+	for i, wave := range waves {
+		for _, ev := range wave {
+			out[i] = append(out[i], ev.Slug)
+		}
+	}
+	return out
+}
+
+func TestRegistry_Waves(t *testing.T) {
+	t.Parallel()
+
+	r := New()
+	r.Register(Evidence{Slug: "faktura-vydana", Table: "t_fv", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "faktura-vydana-polozka", Table: "t_fvp", PrimaryKey: "id", DependsOn: []string{"faktura-vydana"}})
+	r.Register(Evidence{Slug: "banka", Table: "t_b", PrimaryKey: "id"})
+	r.Register(Evidence{Slug: "adresar", Table: "t_a", PrimaryKey: "id", IsMasterData: true})
+	r.Register(Evidence{Slug: "kontakt", Table: "t_k", PrimaryKey: "id", IsMasterData: true, DependsOn: []string{"adresar"}})
+
+	waves, err := r.Waves()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"adresar"},
+		{"kontakt"},
+		{"faktura-vydana", "banka"},
+		{"faktura-vydana-polozka"},
+	}, waveSlugs(waves))
+
+	// Higher priorities go first among evidences that are ready.
+	r.Update("banka", func(ev *Evidence) { ev.Priority = 10 })
+	r.Update("faktura-vydana-polozka", func(ev *Evidence) { ev.Priority = 20 })
+	waves, err = r.Waves()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"adresar"},
+		{"kontakt"},
+		{"banka"},
+		{"faktura-vydana"},
+		{"faktura-vydana-polozka"},
+	}, waveSlugs(waves))
+}
+
+func TestRegistry_Waves_Errors(t *testing.T) {
+	t.Parallel()
+
+	r := New()
+	r.Register(Evidence{Slug: "a", Table: "t_a", PrimaryKey: "id", DependsOn: []string{"missing"}})
+	_, err := r.Waves()
+	assert.ErrorContains(t, err, "unknown evidence missing")
+
+	r = New()
+	r.Register(Evidence{Slug: "a", Table: "t_a", PrimaryKey: "id", DependsOn: []string{"b"}})
+	r.Register(Evidence{Slug: "b", Table: "t_b", PrimaryKey: "id", DependsOn: []string{"a"}})
+	r.Register(Evidence{Slug: "c", Table: "t_c", PrimaryKey: "id"})
+	_, err = r.Waves()
+	assert.ErrorContains(t, err, "dependency cycle among evidences a, b")
+}
+
+func TestNewDefault_Waves(t *testing.T) {
+	t.Parallel()
+
+	waves, err := NewDefault().Waves()
+	require.NoError(t, err)
+	for _, ev := range waves[0] {
+		assert.True(t, ev.IsMasterData, "%s synced before master data", ev.Slug)
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// HistoryTable returns the name of the SCD type 2 history table for a synced table.
+func HistoryTable(table string) string {
+	return table + "_history"
+}
+
+// EnsureHistoryTable creates the history table for a synced table together
+// with a <table>_as_of(timestamptz) SQL function returning the version of
+// every record that was valid at the given moment.
+func EnsureHistoryTable(ctx context.Context, pool *pgxpool.Pool, table string, logger *slog.Logger) error {
+	history := HistoryTable(table)
+	safeHistory := sanitizeIdentifier(history)
+
+	statements := []string{
+		fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				version_id   BIGSERIAL PRIMARY KEY,
+				id           BIGINT NOT NULL,
+				content_hash TEXT NOT NULL,
+				raw_data     JSONB,
+				valid_from   TIMESTAMPTZ NOT NULL,
+				valid_to     TIMESTAMPTZ
+			)
+		`, safeHistory),
+		fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (id) WHERE valid_to IS NULL",
+			sanitizeIdentifier(history+"_current_idx"), safeHistory),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (id, valid_from)",
+			sanitizeIdentifier(history+"_id_valid_from_idx"), safeHistory),
+		fmt.Sprintf(`
+			CREATE OR REPLACE FUNCTION %s(at TIMESTAMPTZ)
+			RETURNS SETOF %s
+			LANGUAGE sql STABLE AS $$
+				SELECT * FROM %s
+				WHERE valid_from <= at AND (valid_to IS NULL OR valid_to > at)
+			$$
+		`, sanitizeIdentifier(table+"_as_of"), safeHistory, safeHistory),
+	}
+
+	for _, stmt := range statements {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("ensure history table %s: %w", history, err)
+		}
+	}
+
+	logger.Debug("ensured history table", "table", table, "history", history)
+	return nil
+}
+
+// RecordHistory appends a new version to the history table for every record
+// whose content differs from its current version, closing the previous one.
+// Records whose content is unchanged are left alone. The previous version
+// is closed by its own statement before the insert, as the partial unique
+// index on current versions would reject a second open version, and both
+// run in one transaction.
+// Returns the number of new versions written.
+func (s *Store) RecordHistory(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	safeHistory := sanitizeIdentifier(HistoryTable(table))
+	closeSQL := fmt.Sprintf(
+		"UPDATE %s SET valid_to = $3 WHERE id = $1 AND valid_to IS NULL AND content_hash <> $2",
+		safeHistory,
+	)
+	insertSQL := fmt.Sprintf(`
+		INSERT INTO %[1]s (id, content_hash, raw_data, valid_from)
+		SELECT $1, $2, $4, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %[1]s WHERE id = $1 AND valid_to IS NULL AND content_hash = $2
+		)
+	`, safeHistory)
+
+	count := 0
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, record := range records {
+			id, ok := record[primaryKey]
+			if !ok {
+				continue
+			}
+
+			rawJSON, hash, err := contentHash(record)
+			if err != nil {
+				s.logger.Warn("failed to marshal record for history", "table", table, "id", id, "error", err)
+				continue
+			}
+
+			validFrom := versionTime(record)
+			if _, err := tx.Exec(ctx, closeSQL, id, hash, validFrom); err != nil {
+				return err
+			}
+			tag, err := tx.Exec(ctx, insertSQL, id, hash, validFrom, rawJSON)
+			if err != nil {
+				return err
+			}
+			count += int(tag.RowsAffected())
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("record history for %s: %w", table, err)
+	}
+
+	return count, nil
+}
+
+// contentHash returns the canonical JSON encoding of a record and its SHA-256.
+// encoding/json sorts map keys, so equal content always hashes the same.
+func contentHash(record map[string]any) ([]byte, string, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(raw)
+	return raw, hex.EncodeToString(sum[:]), nil
+}
+
+// versionTime picks the moment a version became valid: the record's
+// lastUpdate when Flexibee provides one, otherwise the current time.
+func versionTime(record map[string]any) time.Time {
+	if v, ok := record["lastUpdate"].(string); ok {
+		if t, ok := parseFlexibeeTime(v); ok {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// parseFlexibeeTime parses the timestamp formats Flexibee uses in JSON output.
+func parseFlexibeeTime(v string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryTable(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "flexibee_faktura_vydana_history", HistoryTable("flexibee_faktura_vydana"))
+}
+
+func TestContentHash_StableAcrossKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	a := map[string]any{"id": 1, "kod": "FV-001", "sumCelkem": "100.0"}
+	b := map[string]any{"sumCelkem": "100.0", "kod": "FV-001", "id": 1}
+	c := map[string]any{"id": 1, "kod": "FV-001", "sumCelkem": "120.0"}
+
+	_, hashA, err := contentHash(a)
+	require.NoError(t, err)
+	_, hashB, err := contentHash(b)
+	require.NoError(t, err)
+	_, hashC, err := contentHash(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, hashA, hashB)
+	assert.NotEqual(t, hashA, hashC)
+}
+
+func TestVersionTime(t *testing.T) {
+	t.Parallel()
+
+	got := versionTime(map[string]any{"lastUpdate": "2024-03-05T10:20:30.123+01:00"})
+	assert.True(t, got.Equal(time.Date(2024, 3, 5, 9, 20, 30, 123000000, time.UTC)))
+
+	before := time.Now()
+	got = versionTime(map[string]any{"lastUpdate": "garbage"})
+	assert.False(t, got.Before(before))
+}
+
+func TestStore_RecordHistory(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+	table := "test_history_adresar"
+	dropTestTables(t, st, HistoryTable(table))
+	require.NoError(t, EnsureHistoryTable(ctx, st.Pool(), table, st.logger))
+
+	versions := []map[string]any{
+		{"id": float64(7), "nazev": "ACME", "lastUpdate": "2024-01-01T10:00:00.000+01:00"},
+		{"id": float64(7), "nazev": "ACME s.r.o.", "lastUpdate": "2024-02-01T10:00:00.000+01:00"},
+		{"id": float64(7), "nazev": "ACME a.s.", "lastUpdate": "2024-03-01T10:00:00.000+01:00"},
+	}
+	for _, record := range versions {
+		n, err := st.RecordHistory(ctx, table, []map[string]any{record}, "id")
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+
+	// Unchanged content writes no new version.
+	n, err := st.RecordHistory(ctx, table, versions[2:], "id")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	var total, current int
+	var name string
+	require.NoError(t, st.Pool().QueryRow(ctx,
+		"SELECT COUNT(*), COUNT(*) FILTER (WHERE valid_to IS NULL) FROM test_history_adresar_history WHERE id = 7",
+	).Scan(&total, &current))
+	assert.Equal(t, 3, total)
+	assert.Equal(t, 1, current)
+
+	require.NoError(t, st.Pool().QueryRow(ctx,
+		"SELECT raw_data->>'nazev' FROM test_history_adresar_as_of('2024-02-15T00:00:00Z')",
+	).Scan(&name))
+	assert.Equal(t, "ACME s.r.o.", name)
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+// maxIdentifierLength is PostgreSQL's NAMEDATALEN - 1.
+const maxIdentifierLength = 63
+
+// indexSpec describes an index managed by the adapter.
+type indexSpec struct {
+	Name    string
+	Columns []string
+	Method  string // "btree" or "gin"
+}
+
+// IndexName returns the name of the adapter-managed index on table over
+// columns, shortened with a hash suffix when it exceeds PostgreSQL's limit.
+func IndexName(table string, columns []string) string {
+	name := table + "_" + strings.Join(columns, "_") + "_idx"
+	name = strings.ReplaceAll(name, "-", "_")
+	if len(name) <= maxIdentifierLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	suffix := "_" + hex.EncodeToString(sum[:4]) + "_idx"
+	return name[:maxIdentifierLength-len(suffix)] + suffix
+}
+
+// planIndexes returns the indexes a synced table should have: one per date,
+// datetime and relation property, lastUpdate and synced_at, an optional GIN
+// index on raw_data and any extra indexes from opts. Indexes on columns not
+// present in existing are skipped, with a warning for extra indexes.
+func planIndexes(table string, properties []flexibee.Property, opts TableOptions, existing map[string]bool, logger *slog.Logger) []indexSpec {
+	var specs []indexSpec
+	seen := make(map[string]bool)
+
+	// add plans an index, returning the first of its columns that does not
+	// exist, if any.
+	add := func(method string, columns ...string) string {
+		for _, col := range columns {
+			if !existing[col] {
+				return col
+			}
+		}
+		name := IndexName(table, columns)
+		if !seen[name] {
+			seen[name] = true
+			specs = append(specs, indexSpec{Name: name, Columns: columns, Method: method})
+		}
+		return ""
+	}
+
+	for _, prop := range properties {
+		switch prop.Type {
+		case "date", "datetime", "relation":
+			add("btree", prop.Name)
+		}
+	}
+	add("btree", "lastUpdate")
+	add("btree", "synced_at")
+
+	if opts.IndexRawData {
+		add("gin", "raw_data")
+	}
+	for _, columns := range opts.Indexes {
+		if missing := add("btree", columns...); missing != "" {
+			logger.Warn("skipping extra index on missing column", "table", table, "columns", columns, "column", missing)
+		}
+	}
+
+	return specs
+}
+
+// EnsureIndexes creates the indexes of a synced table (see planIndexes) that
+// do not exist yet. It is separate from EnsureTable so that building
+// indexes on large tables does not hold up syncing.
+func EnsureIndexes(ctx context.Context, pool *pgxpool.Pool, table string, properties []flexibee.Property, opts TableOptions, logger *slog.Logger) error {
+	existing, err := getExistingColumns(ctx, pool, table)
+	if err != nil {
+		return fmt.Errorf("get columns for %s: %w", table, err)
+	}
+
+	partitioned, err := isPartitioned(ctx, pool, table)
+	if err != nil {
+		return fmt.Errorf("check partitioning of %s: %w", table, err)
+	}
+
+	return ensureIndexes(ctx, pool, table, planIndexes(table, properties, opts, existing, logger), partitioned, logger)
+}
+
+// ensureIndexes creates the planned indexes of table that do not exist yet.
+// Indexes are built CONCURRENTLY so syncing is not blocked, except on
+// partitioned tables where PostgreSQL does not support it. Invalid indexes
+// left behind by an interrupted concurrent build are dropped and rebuilt.
+func ensureIndexes(ctx context.Context, pool *pgxpool.Pool, table string, specs []indexSpec, partitioned bool, logger *slog.Logger) error {
+	for _, spec := range specs {
+		var valid *bool
+		err := pool.QueryRow(ctx, `
+			SELECT (SELECT i.indisvalid FROM pg_index i WHERE i.indexrelid = to_regclass($1))
+		`, sanitizeIdentifier(spec.Name)).Scan(&valid)
+		if err != nil {
+			return fmt.Errorf("check index %s: %w", spec.Name, err)
+		}
+		if valid != nil && *valid {
+			continue
+		}
+
+		if valid != nil {
+			logger.Warn("rebuilding invalid index", "table", table, "index", spec.Name)
+			if _, err := pool.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+sanitizeIdentifier(spec.Name)); err != nil {
+				return fmt.Errorf("drop invalid index %s: %w", spec.Name, err)
+			}
+		}
+
+		if _, err := pool.Exec(ctx, createIndexSQL(table, spec, !partitioned)); err != nil {
+			logger.Warn("failed to create index", "table", table, "index", spec.Name, "error", err)
+			continue
+		}
+		logger.Debug("created index", "table", table, "index", spec.Name, "columns", spec.Columns)
+	}
+	return nil
+}
+
+func createIndexSQL(table string, spec indexSpec, concurrently bool) string {
+	cols := make([]string, len(spec.Columns))
+	for i, col := range spec.Columns {
+		cols[i] = sanitizeIdentifier(col)
+	}
+
+	using := ""
+	if spec.Method == "gin" {
+		using = " USING gin"
+		for i := range cols {
+			cols[i] += " jsonb_path_ops"
+		}
+	}
+
+	keyword := "CREATE INDEX"
+	if concurrently {
+		keyword += " CONCURRENTLY"
+	}
+
+	return fmt.Sprintf("%s IF NOT EXISTS %s ON %s%s (%s)",
+		keyword, sanitizeIdentifier(spec.Name), sanitizeIdentifier(table), using, strings.Join(cols, ", "))
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+func TestIndexName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "flexibee_banka_datVyst_idx", IndexName("flexibee_banka", []string{"datVyst"}))
+	assert.Equal(t, "flexibee_banka_varSym_datVyst_idx", IndexName("flexibee_banka", []string{"varSym", "datVyst"}))
+
+	long := IndexName("flexibee_dodavatelska_smlouva", []string{"stredisko", "zakazka", "datumPodpisu"})
+	assert.LessOrEqual(t, len(long), maxIdentifierLength)
+	assert.True(t, strings.HasSuffix(long, "_idx"))
+	assert.NotEqual(t, long, IndexName("flexibee_dodavatelska_smlouva", []string{"stredisko", "zakazka", "datumUcinnosti"}))
+}
+
+func TestPlanIndexes(t *testing.T) {
+	t.Parallel()
+
+	props := []flexibee.Property{
+		{Name: "id", Type: "integer"},
+		{Name: "kod", Type: "string"},
+		{Name: "datVyst", Type: "date"},
+		{Name: "lastUpdate", Type: "datetime"},
+		{Name: "firma", Type: "relation"},
+		{Name: "sumCelkem", Type: "numeric"},
+		{Name: "stredisko", Type: "relation"},
+	}
+	existing := map[string]bool{
+		"id": true, "raw_data": true, "synced_at": true, "kod": true, "datVyst": true,
+		"lastUpdate": true, "firma": true, "sumCelkem": true,
+	}
+	opts := TableOptions{
+		IndexRawData: true,
+		Indexes:      [][]string{{"kod"}, {"firma", "datVyst"}, {"missing"}},
+	}
+
+	var logs bytes.Buffer
+	specs := planIndexes("flexibee_faktura_vydana", props, opts, existing, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var names []string
+	for _, spec := range specs {
+		names = append(names, strings.Join(spec.Columns, "+")+":"+spec.Method)
+	}
+	assert.Equal(t, []string{
+		"datVyst:btree",
+		"lastUpdate:btree",
+		"firma:btree",
+		"synced_at:btree",
+		"raw_data:gin",
+		"kod:btree",
+		"firma+datVyst:btree",
+	}, names, "stredisko is skipped because the column does not exist")
+	assert.Contains(t, logs.String(), "column=missing")
+	assert.NotContains(t, logs.String(), "column=stredisko")
+}
+
+func TestCreateIndexSQL(t *testing.T) {
+	t.Parallel()
+
+	btree := indexSpec{Name: "t_datVyst_idx", Columns: []string{"datVyst"}, Method: "btree"}
+	assert.Equal(t,
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "t_datVyst_idx" ON "t" ("datVyst")`,
+		createIndexSQL("t", btree, true))
+	assert.Equal(t,
+		`CREATE INDEX IF NOT EXISTS "t_datVyst_idx" ON "t" ("datVyst")`,
+		createIndexSQL("t", btree, false))
+
+	gin := indexSpec{Name: "t_raw_data_idx", Columns: []string{"raw_data"}, Method: "gin"}
+	assert.Equal(t,
+		`CREATE INDEX CONCURRENTLY IF NOT EXISTS "t_raw_data_idx" ON "t" USING gin ("raw_data" jsonb_path_ops)`,
+		createIndexSQL("t", gin, true))
+}
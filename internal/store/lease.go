@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Lease is a named lease held by one adapter replica at a time, used for
+// leader election. It is valid until ExpiresAt unless renewed.
+type Lease struct {
+	Name       string
+	Holder     string
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// AcquireLease acquires the lease for holder, or renews it when holder
+// already has it, for ttl. It reports false when another holder has an
+// unexpired lease. Expiry is measured by the database clock.
+func (s *Store) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	var got string
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO leader_lease (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW(), NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET
+			acquired_at = CASE WHEN leader_lease.holder = EXCLUDED.holder
+				THEN leader_lease.acquired_at ELSE EXCLUDED.acquired_at END,
+			holder = EXCLUDED.holder, renewed_at = EXCLUDED.renewed_at, expires_at = EXCLUDED.expires_at
+		WHERE leader_lease.holder = EXCLUDED.holder OR leader_lease.expires_at < NOW()
+		RETURNING holder
+	`, name, holder, ttl.Milliseconds()).Scan(&got)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// ReleaseLease gives up the lease if holder has it, so that another
+// replica can take it over without waiting for it to expire.
+func (s *Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	if _, err := s.pool.Exec(ctx, "DELETE FROM leader_lease WHERE name = $1 AND holder = $2", name, holder); err != nil {
+		return fmt.Errorf("release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetLease returns the lease, or nil if it was never acquired or has been
+// released.
+func (s *Store) GetLease(ctx context.Context, name string) (*Lease, error) {
+	lease := Lease{Name: name}
+	err := s.pool.QueryRow(ctx,
+		"SELECT holder, acquired_at, renewed_at, expires_at FROM leader_lease WHERE name = $1",
+		name,
+	).Scan(&lease.Holder, &lease.AcquiredAt, &lease.RenewedAt, &lease.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lease %s: %w", name, err)
+	}
+	return &lease, nil
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+)
+
+// RegisterMetrics exposes the statistics of the connection pools.
+func (s *Store) RegisterMetrics() error {
+	if err := metrics.Register(newPgxPoolCollector(s.pool, "sync")); err != nil {
+		return err
+	}
+	if s.admin != s.pool {
+		return metrics.Register(newPgxPoolCollector(s.admin, "admin"))
+	}
+	return nil
+}
+
+// pgxPoolCollector collects the statistics of a pgx connection pool.
+type pgxPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns *prometheus.Desc
+	idleConns     *prometheus.Desc
+	totalConns    *prometheus.Desc
+	maxConns      *prometheus.Desc
+	acquires      *prometheus.Desc
+	emptyAcquires *prometheus.Desc
+	acquireWait   *prometheus.Desc
+}
+
+func newPgxPoolCollector(pool *pgxpool.Pool, name string) *pgxPoolCollector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "db_pool", metric), help,
+			nil, prometheus.Labels{"pool": name})
+	}
+	return &pgxPoolCollector{
+		pool:          pool,
+		acquiredConns: desc("acquired_connections", "Connections currently in use."),
+		idleConns:     desc("idle_connections", "Idle connections."),
+		totalConns:    desc("connections", "Open connections, in use, idle or being established."),
+		maxConns:      desc("max_connections", "Maximum size of the pool."),
+		acquires:      desc("acquires_total", "Connections acquired from the pool."),
+		emptyAcquires: desc("empty_acquires_total", "Acquires that had to wait for a connection."),
+		acquireWait:   desc("acquire_wait_seconds_total", "Time spent acquiring connections."),
+	}
+}
+
+func (c *pgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *pgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
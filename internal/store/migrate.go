@@ -0,0 +1,343 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockKey is the pg_advisory_lock key serialising migrations
+// across adapter instances starting at the same time.
+const migrationLockKey int64 = 0x666c7862 // "flxb"
+
+// Migration is one numbered schema change with its up and optional down SQL.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+// MigrationStatus describes the state of a migration in the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Modified  bool // applied checksum differs from the embedded file
+}
+
+// loadMigrations reads NNN_name.up.sql / NNN_name.down.sql pairs from dir
+// of fsys, ordered by version. Subdirectories are ignored.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: file name must end in .up.sql or .down.sql", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		num, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: file name must be NNN_name", name)
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, num)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", name, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, m.Name, label)
+		}
+
+		if direction == "up" {
+			m.Up = string(data)
+			sum := sha256.Sum256(data)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no .up.sql file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// pendingMigrations returns the migrations not applied yet. It fails if an
+// applied migration's checksum no longer matches the embedded file.
+func pendingMigrations(migrations []Migration, applied map[int]appliedMigration) ([]Migration, error) {
+	var pending []Migration
+	for _, m := range migrations {
+		a, ok := applied[m.Version]
+		if !ok {
+			pending = append(pending, m)
+			continue
+		}
+		if a.checksum != m.Checksum {
+			return nil, fmt.Errorf("migration %03d_%s was modified after being applied (checksum mismatch)", m.Version, m.Name)
+		}
+	}
+	return pending, nil
+}
+
+// revertibleMigrations returns up to steps applied migrations to revert,
+// most recent first.
+func revertibleMigrations(migrations []Migration, applied map[int]appliedMigration, steps int) ([]Migration, error) {
+	byVersion := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	versions := make([]int, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	var result []Migration
+	for _, version := range versions {
+		if len(result) >= steps {
+			break
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %03d is applied but not known to this build", version)
+		}
+		if m.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no .down.sql file", m.Version, m.Name)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// migrationStatuses combines the embedded migrations with their applied state.
+func migrationStatuses(migrations []Migration, applied map[int]appliedMigration) []MigrationStatus {
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = &a.appliedAt
+			st.Modified = a.checksum != m.Checksum
+		}
+		result = append(result, st)
+	}
+	return result
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// advisory lock, after making sure the schema_migrations table exists.
+func withMigrationLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even after cancellation.
+		_, _ = conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	}()
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func loadApplied(ctx context.Context, conn *pgxpool.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.Query(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// RunMigrations applies all pending embedded migrations in version order
+// and then grants the configured roles their privileges. It fails without
+// applying anything if an already applied migration was edited afterwards.
+func (s *Store) RunMigrations(ctx context.Context) error {
+	migrations, err := loadMigrations(migrationFS, "migrations")
+	if err != nil {
+		return err
+	}
+
+	err = withMigrationLock(ctx, s.admin, func(conn *pgxpool.Conn) error {
+		applied, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pending, err := pendingMigrations(migrations, applied)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range pending {
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx,
+					"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+					m.Version, m.Name, m.Checksum,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply migration %03d_%s: %w", m.Version, m.Name, err)
+			}
+			s.logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+
+		s.logger.Info("migrations applied successfully", "applied", len(pending), "total", len(migrations))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.grantPrivileges(ctx)
+}
+
+// MigrateDown reverts the given number of most recently applied migrations.
+func (s *Store) MigrateDown(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations(migrationFS, "migrations")
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, s.admin, func(conn *pgxpool.Conn) error {
+		applied, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		revert, err := revertibleMigrations(migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range revert {
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("revert migration %03d_%s: %w", m.Version, m.Name, err)
+			}
+			s.logger.Info("reverted migration", "version", m.Version, "name", m.Name)
+		}
+		return nil
+	})
+}
+
+// MigrationStatus returns every embedded migration with its applied state.
+// It only reads, without the migration lock, so that it is cheap enough for
+// the readiness probe; without a schema_migrations table nothing is applied.
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.admin.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !exists {
+		return migrationStatuses(migrations, nil), nil
+	}
+
+	applied, err := loadApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatuses(migrations, applied), nil
+}
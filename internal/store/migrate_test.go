@@ -0,0 +1,115 @@
+package store
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Embedded(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := loadMigrations(migrationFS, "migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	first := migrations[0]
+	assert.Equal(t, 1, first.Version)
+	assert.Equal(t, "sync_state", first.Name)
+	assert.Contains(t, first.Up, "CREATE TABLE IF NOT EXISTS sync_state")
+	assert.Contains(t, first.Up, "CREATE TABLE IF NOT EXISTS cleanup_log")
+	assert.Contains(t, first.Down, "DROP TABLE IF EXISTS sync_state")
+	assert.Len(t, first.Checksum, 64)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "migration versions must be consecutive")
+		assert.NotEmpty(t, m.Down, "migration %03d_%s should have a down file", m.Version, m.Name)
+	}
+}
+
+func TestLoadMigrations_OrderAndPairs(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"migrations/002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"migrations/001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"migrations/001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"migrations/002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	}
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, "first", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE a;", migrations[0].Down)
+	assert.Equal(t, "second", migrations[1].Name)
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{"bad suffix", fstest.MapFS{"migrations/001_a.sql": {Data: []byte("x")}}},
+		{"bad version", fstest.MapFS{"migrations/abc_a.up.sql": {Data: []byte("x")}}},
+		{"missing up", fstest.MapFS{"migrations/001_a.down.sql": {Data: []byte("x")}}},
+		{"duplicate version", fstest.MapFS{
+			"migrations/001_a.up.sql": {Data: []byte("x")},
+			"migrations/001_b.up.sql": {Data: []byte("y")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := loadMigrations(tt.fsys, "migrations")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	t.Parallel()
+
+	migrations := []Migration{
+		{Version: 1, Name: "a", Checksum: "c1"},
+		{Version: 2, Name: "b", Checksum: "c2"},
+	}
+
+	pending, err := pendingMigrations(migrations, map[int]appliedMigration{1: {checksum: "c1"}})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].Version)
+
+	_, err = pendingMigrations(migrations, map[int]appliedMigration{1: {checksum: "edited"}})
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestRevertibleMigrations(t *testing.T) {
+	t.Parallel()
+
+	migrations := []Migration{
+		{Version: 1, Name: "a", Down: "DROP a"},
+		{Version: 2, Name: "b", Down: "DROP b"},
+		{Version: 3, Name: "c"},
+	}
+	applied := map[int]appliedMigration{1: {}, 2: {}}
+
+	revert, err := revertibleMigrations(migrations, applied, 5)
+	require.NoError(t, err)
+	require.Len(t, revert, 2)
+	assert.Equal(t, 2, revert[0].Version)
+	assert.Equal(t, 1, revert[1].Version)
+
+	applied[3] = appliedMigration{}
+	_, err = revertibleMigrations(migrations, applied, 1)
+	assert.ErrorContains(t, err, "no .down.sql")
+
+	_, err = revertibleMigrations(migrations, map[int]appliedMigration{9: {}}, 1)
+	assert.ErrorContains(t, err, "not known")
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS cleanup_log;
+
+DROP TABLE IF EXISTS sync_state;
@@ -0,0 +1,4 @@
+-- CASCADE also drops the curated views built on top of the function.
+DROP FUNCTION IF EXISTS flexibee_relation_code(TEXT) CASCADE;
+
+DROP TABLE IF EXISTS curated_views;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS curated_views (
+    name       TEXT PRIMARY KEY,
+    checksum   TEXT NOT NULL,
+    built_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Strips the "code:" prefix Flexibee uses for relation values,
+-- e.g. "code:FIRMA" -> "FIRMA".
+CREATE OR REPLACE FUNCTION flexibee_relation_code(ref TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN ref LIKE 'code:%' THEN substr(ref, 6) ELSE ref END
+$$;
@@ -0,0 +1,13 @@
+-- Latest comparison of record counts and totals per evidence and period
+-- between Flexibee and the synced tables.
+CREATE TABLE IF NOT EXISTS reconciliation (
+    evidence       TEXT NOT NULL,
+    period         TEXT NOT NULL,
+    metric         TEXT NOT NULL,
+    flexibee_value NUMERIC NOT NULL,
+    synced_value   NUMERIC NOT NULL,
+    difference     NUMERIC NOT NULL,
+    drift          BOOLEAN NOT NULL,
+    checked_at     TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (evidence, period, metric)
+);
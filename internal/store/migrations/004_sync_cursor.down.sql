@@ -0,0 +1,4 @@
+ALTER TABLE sync_state
+    DROP COLUMN IF EXISTS cursor_id,
+    DROP COLUMN IF EXISTS cursor_filter,
+    DROP COLUMN IF EXISTS cursor_started_at;
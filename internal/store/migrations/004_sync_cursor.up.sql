@@ -0,0 +1,5 @@
+-- Position of an unfinished sync, so that it resumes after a restart.
+ALTER TABLE sync_state
+    ADD COLUMN IF NOT EXISTS cursor_id         BIGINT,
+    ADD COLUMN IF NOT EXISTS cursor_filter     TEXT,
+    ADD COLUMN IF NOT EXISTS cursor_started_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+-- Lease held by the adapter replica that syncs; standby replicas take it
+-- over once it expires.
+CREATE TABLE IF NOT EXISTS leader_lease (
+    name        TEXT PRIMARY KEY,
+    holder      TEXT NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    renewed_at  TIMESTAMPTZ NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
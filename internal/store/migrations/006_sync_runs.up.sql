@@ -0,0 +1,19 @@
+-- One row per evidence sync, for charting sync duration and failures.
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id               BIGSERIAL PRIMARY KEY,
+    evidence         TEXT NOT NULL,
+    started_at       TIMESTAMPTZ NOT NULL,
+    finished_at      TIMESTAMPTZ NOT NULL,
+    duration_ms      BIGINT NOT NULL,
+    mode             TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    pages            INTEGER NOT NULL,
+    records_fetched  BIGINT NOT NULL,
+    records_upserted BIGINT NOT NULL,
+    records_failed   BIGINT NOT NULL,
+    bytes            BIGINT NOT NULL,
+    error_msg        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS sync_runs_evidence_started_at_idx ON sync_runs (evidence, started_at);
+CREATE INDEX IF NOT EXISTS sync_runs_started_at_idx ON sync_runs (started_at);
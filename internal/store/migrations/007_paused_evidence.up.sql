@@ -0,0 +1,6 @@
+-- Evidences paused through the admin API; scheduled and periodic syncs
+-- skip them until they are resumed.
+CREATE TABLE IF NOT EXISTS paused_evidence (
+    evidence  TEXT PRIMARY KEY,
+    paused_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sync_state (
+    evidence    VARCHAR(191) PRIMARY KEY,
+    last_update DATETIME(3) NULL,
+    last_sync   DATETIME(3) NOT NULL,
+    row_count   BIGINT DEFAULT 0,
+    status      VARCHAR(32) DEFAULT 'ok',
+    error_msg   TEXT
+) DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE IF NOT EXISTS cleanup_log (
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    evidence     VARCHAR(191) NOT NULL,
+    cleaned_at   DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    rows_deleted BIGINT NOT NULL,
+    oldest_kept  DATETIME(3) NULL
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reconciliation (
+    evidence       VARCHAR(191) NOT NULL,
+    period         VARCHAR(16) NOT NULL,
+    metric         VARCHAR(191) NOT NULL,
+    flexibee_value DECIMAL(19, 6) NOT NULL,
+    synced_value   DECIMAL(19, 6) NOT NULL,
+    difference     DECIMAL(19, 6) NOT NULL,
+    drift          BOOLEAN NOT NULL,
+    checked_at     DATETIME(3) NOT NULL,
+    PRIMARY KEY (evidence, period, metric)
+) DEFAULT CHARSET = utf8mb4;
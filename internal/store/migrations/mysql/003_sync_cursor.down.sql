@@ -0,0 +1,4 @@
+ALTER TABLE sync_state
+    DROP COLUMN cursor_id,
+    DROP COLUMN cursor_filter,
+    DROP COLUMN cursor_started_at;
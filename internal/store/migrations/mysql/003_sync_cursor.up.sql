@@ -0,0 +1,5 @@
+-- Position of an unfinished sync, so that it resumes after a restart.
+ALTER TABLE sync_state
+    ADD COLUMN cursor_id         BIGINT NULL,
+    ADD COLUMN cursor_filter     TEXT NULL,
+    ADD COLUMN cursor_started_at DATETIME(3) NULL;
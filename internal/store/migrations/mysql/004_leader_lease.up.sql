@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS leader_lease (
+    name        VARCHAR(191) PRIMARY KEY,
+    holder      VARCHAR(255) NOT NULL,
+    acquired_at DATETIME(3) NOT NULL,
+    renewed_at  DATETIME(3) NOT NULL,
+    expires_at  DATETIME(3) NOT NULL
+) DEFAULT CHARSET = utf8mb4;
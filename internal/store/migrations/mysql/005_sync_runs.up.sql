@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+    evidence         VARCHAR(191) NOT NULL,
+    started_at       DATETIME(3) NOT NULL,
+    finished_at      DATETIME(3) NOT NULL,
+    duration_ms      BIGINT NOT NULL,
+    mode             VARCHAR(32) NOT NULL,
+    status           VARCHAR(32) NOT NULL,
+    pages            INT NOT NULL,
+    records_fetched  BIGINT NOT NULL,
+    records_upserted BIGINT NOT NULL,
+    records_failed   BIGINT NOT NULL,
+    bytes            BIGINT NOT NULL,
+    error_msg        TEXT NULL,
+    INDEX sync_runs_evidence_started_at_idx (evidence, started_at),
+    INDEX sync_runs_started_at_idx (started_at)
+) DEFAULT CHARSET = utf8mb4;
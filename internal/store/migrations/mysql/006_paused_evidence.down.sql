@@ -0,0 +1 @@
+DROP TABLE IF EXISTS paused_evidence;
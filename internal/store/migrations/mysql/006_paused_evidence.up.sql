@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS paused_evidence (
+    evidence  VARCHAR(191) PRIMARY KEY,
+    paused_at DATETIME(3) NOT NULL
+) DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS sync_state (
+    evidence    TEXT PRIMARY KEY,
+    last_update TIMESTAMP,
+    last_sync   TIMESTAMP NOT NULL,
+    row_count   INTEGER DEFAULT 0,
+    status      TEXT DEFAULT 'ok',
+    error_msg   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS cleanup_log (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    evidence     TEXT NOT NULL,
+    cleaned_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    rows_deleted INTEGER NOT NULL,
+    oldest_kept  TIMESTAMP
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS reconciliation (
+    evidence       TEXT NOT NULL,
+    period         TEXT NOT NULL,
+    metric         TEXT NOT NULL,
+    flexibee_value REAL NOT NULL,
+    synced_value   REAL NOT NULL,
+    difference     REAL NOT NULL,
+    drift          INTEGER NOT NULL,
+    checked_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (evidence, period, metric)
+);
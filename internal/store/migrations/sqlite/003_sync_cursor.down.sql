@@ -0,0 +1,3 @@
+ALTER TABLE sync_state DROP COLUMN cursor_id;
+ALTER TABLE sync_state DROP COLUMN cursor_filter;
+ALTER TABLE sync_state DROP COLUMN cursor_started_at;
@@ -0,0 +1,4 @@
+-- Position of an unfinished sync, so that it resumes after a restart.
+ALTER TABLE sync_state ADD COLUMN cursor_id INTEGER;
+ALTER TABLE sync_state ADD COLUMN cursor_filter TEXT;
+ALTER TABLE sync_state ADD COLUMN cursor_started_at TIMESTAMP;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS leader_lease (
+    name        TEXT PRIMARY KEY,
+    holder      TEXT NOT NULL,
+    acquired_at TIMESTAMP NOT NULL,
+    renewed_at  TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP NOT NULL
+);
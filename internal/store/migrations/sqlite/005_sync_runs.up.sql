@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    evidence         TEXT NOT NULL,
+    started_at       TIMESTAMP NOT NULL,
+    finished_at      TIMESTAMP NOT NULL,
+    duration_ms      INTEGER NOT NULL,
+    mode             TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    pages            INTEGER NOT NULL,
+    records_fetched  INTEGER NOT NULL,
+    records_upserted INTEGER NOT NULL,
+    records_failed   INTEGER NOT NULL,
+    bytes            INTEGER NOT NULL,
+    error_msg        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS sync_runs_evidence_started_at_idx ON sync_runs (evidence, started_at);
+CREATE INDEX IF NOT EXISTS sync_runs_started_at_idx ON sync_runs (started_at);
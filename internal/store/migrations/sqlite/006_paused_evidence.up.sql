@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS paused_evidence (
+    evidence  TEXT PRIMARY KEY,
+    paused_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// partitionSuffixLayout is the time layout of the _pYYYYMM partition suffix.
+const partitionSuffixLayout = "200601"
+
+// PartitionName returns the name of the monthly partition of table holding month.
+func PartitionName(table string, month time.Time) string {
+	return table + "_p" + month.Format(partitionSuffixLayout)
+}
+
+// monthStart truncates t to the first day of its month in UTC.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// parsePartitionMonth extracts the month from a Flexibee date or datetime
+// value such as "2024-01-15+01:00" or "2024-01-15T10:00:00.000+01:00".
+func parsePartitionMonth(v any) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok || len(s) < len("2006-01-02") {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006-01-02", s[:len("2006-01-02")])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return monthStart(t), true
+}
+
+// createPartitionedTable creates a table range-partitioned by month on column.
+// The partition column has to be part of the primary key.
+func createPartitionedTable(ctx context.Context, pool *pgxpool.Pool, table, column, columnType string) error {
+	safeTable := sanitizeIdentifier(table)
+	safeCol := sanitizeIdentifier(column)
+
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id BIGINT NOT NULL,
+			raw_data JSONB,
+			synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			%s %s NOT NULL,
+			PRIMARY KEY (id, %s)
+		) PARTITION BY RANGE (%s)
+	`, safeTable, safeCol, columnType, safeCol, safeCol)
+
+	if _, err := pool.Exec(ctx, createSQL); err != nil {
+		return fmt.Errorf("create partitioned table %s: %w", table, err)
+	}
+	return nil
+}
+
+// createPartition creates the monthly partition of table holding month.
+func createPartition(ctx context.Context, pool *pgxpool.Pool, table string, month time.Time) error {
+	month = monthStart(month)
+	partSQL := fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		sanitizeIdentifier(PartitionName(table, month)),
+		sanitizeIdentifier(table),
+		month.Format("2006-01-02"),
+		month.AddDate(0, 1, 0).Format("2006-01-02"),
+	)
+	if _, err := pool.Exec(ctx, partSQL); err != nil {
+		return fmt.Errorf("create partition %s: %w", PartitionName(table, month), err)
+	}
+
+	// A partition of a table with row-level security cannot be read
+	// directly around the table's policies.
+	var rowSecurity bool
+	if err := pool.QueryRow(ctx,
+		"SELECT relrowsecurity FROM pg_class WHERE oid = to_regclass($1)", sanitizeIdentifier(table),
+	).Scan(&rowSecurity); err != nil {
+		return fmt.Errorf("check row security of %s: %w", table, err)
+	}
+	if rowSecurity {
+		rlsSQL := fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", sanitizeIdentifier(PartitionName(table, month)))
+		if _, err := pool.Exec(ctx, rlsSQL); err != nil {
+			return fmt.Errorf("enable row security on %s: %w", PartitionName(table, month), err)
+		}
+	}
+	return nil
+}
+
+// ensurePartitionsAhead creates partitions for the current month and the
+// given number of following months.
+func ensurePartitionsAhead(ctx context.Context, pool *pgxpool.Pool, table string, monthsAhead int, logger *slog.Logger) error {
+	current := monthStart(time.Now())
+	for i := 0; i <= monthsAhead; i++ {
+		if err := createPartition(ctx, pool, table, current.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	logger.Debug("ensured partitions ahead", "table", table, "months_ahead", monthsAhead)
+	return nil
+}
+
+// isPartitioned reports whether table exists as a partitioned table.
+func isPartitioned(ctx context.Context, pool *pgxpool.Pool, table string) (bool, error) {
+	var partitioned bool
+	err := pool.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass($1))",
+		sanitizeIdentifier(table),
+	).Scan(&partitioned)
+	return partitioned, err
+}
+
+// partitionColumn returns the range partition column of table, or "" when
+// the table is not partitioned. Results are cached per table.
+func (s *Store) partitionColumn(ctx context.Context, table string) (string, error) {
+	s.mu.Lock()
+	col, ok := s.partitionKeys[table]
+	s.mu.Unlock()
+	if ok {
+		return col, nil
+	}
+
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE((
+			SELECT a.attname
+			FROM pg_partitioned_table pt
+			JOIN pg_attribute a ON a.attrelid = pt.partrelid AND a.attnum = pt.partattrs[0]
+			WHERE pt.partrelid = to_regclass($1)
+		), '')
+	`, sanitizeIdentifier(table)).Scan(&col)
+	if err != nil {
+		return "", fmt.Errorf("look up partition column of %s: %w", table, err)
+	}
+
+	s.mu.Lock()
+	s.partitionKeys[table] = col
+	s.mu.Unlock()
+	return col, nil
+}
+
+// ensureRecordPartitions creates any monthly partitions missing for the
+// partition column values of records, so historic data can be loaded into
+// months that were not created ahead of time.
+func (s *Store) ensureRecordPartitions(ctx context.Context, table, column string, records []map[string]any) error {
+	for _, record := range records {
+		month, ok := parsePartitionMonth(record[column])
+		if !ok {
+			continue
+		}
+		name := PartitionName(table, month)
+
+		s.mu.Lock()
+		known := s.partitions[name]
+		s.mu.Unlock()
+		if known {
+			continue
+		}
+
+		if err := createPartition(ctx, s.admin, table, month); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.partitions[name] = true
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// DropPartitionsBefore detaches and drops every monthly partition of table
+// whose whole range lies before cutoff. Returns the number of rows dropped,
+// or ErrNotPartitioned if table exists without partitioning.
+func (s *Store) DropPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error) {
+	expired, err := s.expiredPartitions(ctx, table, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	var totalDropped int64
+	for _, name := range expired {
+		safePart := sanitizeIdentifier(name)
+
+		count, err := s.countPartition(ctx, name)
+		if err != nil {
+			return totalDropped, err
+		}
+
+		detachSQL := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", sanitizeIdentifier(table), safePart)
+		if _, err := s.admin.Exec(ctx, detachSQL); err != nil {
+			return totalDropped, fmt.Errorf("detach partition %s: %w", name, err)
+		}
+		if _, err := s.admin.Exec(ctx, fmt.Sprintf("DROP TABLE %s", safePart)); err != nil {
+			return totalDropped, fmt.Errorf("drop partition %s: %w", name, err)
+		}
+
+		s.mu.Lock()
+		delete(s.partitions, name)
+		s.mu.Unlock()
+
+		s.logger.Info("dropped partition", "table", table, "partition", name, "rows", count)
+		totalDropped += count
+	}
+
+	return totalDropped, nil
+}
+
+// CountPartitionsBefore returns the number of rows DropPartitionsBefore
+// would drop, without dropping anything.
+func (s *Store) CountPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error) {
+	expired, err := s.expiredPartitions(ctx, table, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, name := range expired {
+		count, err := s.countPartition(ctx, name)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// expiredPartitions returns the partitions of table whose whole range lies
+// before cutoff. It fails with ErrNotPartitioned for a plain table.
+func (s *Store) expiredPartitions(ctx context.Context, table string, cutoff time.Time) ([]string, error) {
+	partCol, err := s.partitionColumn(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if partCol == "" {
+		return nil, fmt.Errorf("table %s: %w", table, ErrNotPartitioned)
+	}
+
+	partitions, err := partitionsOf(ctx, s.admin, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	for _, name := range partitions {
+		if partitionExpired(table, name, cutoff) {
+			expired = append(expired, name)
+		}
+	}
+	return expired, nil
+}
+
+func (s *Store) countPartition(ctx context.Context, name string) (int64, error) {
+	var count int64
+	if err := s.admin.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", sanitizeIdentifier(name))).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count partition %s: %w", name, err)
+	}
+	return count, nil
+}
+
+// partitionsOf returns the names of the partitions of table, which are
+// none for a plain table.
+func partitionsOf(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)
+		ORDER BY c.relname
+	`, sanitizeIdentifier(table))
+	if err != nil {
+		return nil, fmt.Errorf("list partitions of %s: %w", table, err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("list partitions of %s: %w", table, err)
+	}
+	return names, nil
+}
+
+// partitionExpired reports whether the partition named name of table covers
+// a month that ends on or before cutoff. Partitions not following the
+// _pYYYYMM naming scheme are never considered expired.
+func partitionExpired(table, name string, cutoff time.Time) bool {
+	suffix, ok := strings.CutPrefix(name, table+"_p")
+	if !ok {
+		return false
+	}
+	month, err := time.Parse(partitionSuffixLayout, suffix)
+	if err != nil {
+		return false
+	}
+	return !month.AddDate(0, 1, 0).After(cutoff)
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionName(t *testing.T) {
+	t.Parallel()
+	month := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "flexibee_banka_p202403", PartitionName("flexibee_banka", month))
+}
+
+func TestParsePartitionMonth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input any
+		want  time.Time
+		ok    bool
+	}{
+		{"2024-03-15+01:00", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-12-31T23:59:59.000+01:00", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-03", time.Time{}, false},
+		{nil, time.Time{}, false},
+		{42, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parsePartitionMonth(tt.input)
+		assert.Equal(t, tt.ok, ok, "input %v", tt.input)
+		assert.True(t, tt.want.Equal(got), "input %v: got %v", tt.input, got)
+	}
+}
+
+func TestPartitionExpired(t *testing.T) {
+	t.Parallel()
+
+	cutoff := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	assert.True(t, partitionExpired("flexibee_banka", "flexibee_banka_p202401", cutoff))
+	assert.True(t, partitionExpired("flexibee_banka", "flexibee_banka_p202402", cutoff))
+	assert.False(t, partitionExpired("flexibee_banka", "flexibee_banka_p202403", cutoff), "partition still holds rows newer than cutoff")
+	assert.False(t, partitionExpired("flexibee_banka", "flexibee_banka_default", cutoff))
+	assert.False(t, partitionExpired("flexibee_banka", "flexibee_pokladna_p202001", cutoff))
+}
+
+func TestStore_DropPartitionsBefore_Unpartitioned(t *testing.T) {
+	ctx := context.Background()
+	st := newTestStore(t)
+	dropTestTables(t, st, "test_unpartitioned")
+	_, err := st.Pool().Exec(ctx, "CREATE TABLE test_unpartitioned (id BIGINT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	_, err = st.DropPartitionsBefore(ctx, "test_unpartitioned", time.Now())
+	assert.ErrorIs(t, err, ErrNotPartitioned)
+	_, err = st.CountPartitionsBefore(ctx, "test_unpartitioned", time.Now())
+	assert.ErrorIs(t, err, ErrNotPartitioned)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PauseEvidence pauses the syncing of evidence. Pausing a paused evidence
+// keeps its original pause time.
+func (s *Store) PauseEvidence(ctx context.Context, evidence string) error {
+	_, err := s.pool.Exec(ctx,
+		"INSERT INTO paused_evidence (evidence, paused_at) VALUES ($1, NOW()) ON CONFLICT (evidence) DO NOTHING",
+		evidence)
+	if err != nil {
+		return fmt.Errorf("pause %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// ResumeEvidence resumes the syncing of a paused evidence.
+func (s *Store) ResumeEvidence(ctx context.Context, evidence string) error {
+	if _, err := s.pool.Exec(ctx, "DELETE FROM paused_evidence WHERE evidence = $1", evidence); err != nil {
+		return fmt.Errorf("resume %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// PausedEvidences returns the paused evidences with the time they were
+// paused.
+func (s *Store) PausedEvidences(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.pool.Query(ctx, "SELECT evidence, paused_at FROM paused_evidence")
+	if err != nil {
+		return nil, fmt.Errorf("list paused evidences: %w", err)
+	}
+	defer rows.Close()
+
+	paused := make(map[string]time.Time)
+	for rows.Next() {
+		var evidence string
+		var at time.Time
+		if err := rows.Scan(&evidence, &at); err != nil {
+			return nil, fmt.Errorf("list paused evidences: %w", err)
+		}
+		paused[evidence] = at
+	}
+	return paused, rows.Err()
+}
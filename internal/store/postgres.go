@@ -2,33 +2,68 @@ package store
 
 import (
 	"context"
-	_ "embed"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-//go:embed migrations/001_sync_state.sql
-var migrationSQL string
-
 // SyncState tracks the last sync state for an evidence type.
 type SyncState struct {
 	Evidence   string
 	LastUpdate *time.Time
 	LastSync   time.Time
-	RowCount   int64
+	RowCount   int64 // rows in the table, counted after the last sync changing it
 	Status     string
 	ErrorMsg   string
+	Cursor     *SyncCursor // set while a sync is unfinished
+}
+
+// SyncCursor is the position of an unfinished sync, saved after every page
+// so that the sync resumes there after a restart.
+type SyncCursor struct {
+	LastID    int64     // highest id synced so far; pages are fetched in id order
+	Filter    string    // Flexibee filter the sync started with
+	StartedAt time.Time // when the sync started; becomes LastUpdate once it completes
+}
+
+// cursorValues returns the cursor_id, cursor_filter and cursor_started_at
+// values of c, all nil without a cursor.
+func cursorValues(c *SyncCursor) (id, filter any, startedAt *time.Time) {
+	if c == nil {
+		return nil, nil, nil
+	}
+	return c.LastID, c.Filter, &c.StartedAt
+}
+
+// scannedCursor builds the cursor scanned from sync_state, nil if none.
+func scannedCursor(id sql.NullInt64, filter sql.NullString, startedAt *time.Time) *SyncCursor {
+	if startedAt == nil {
+		return nil
+	}
+	return &SyncCursor{LastID: id.Int64, Filter: filter.String, StartedAt: *startedAt}
 }
 
 // Store manages PostgreSQL operations for synced Flexibee data.
 type Store struct {
 	pool   *pgxpool.Pool
+	admin  *pgxpool.Pool // migrations and DDL; the same as pool unless an admin URL is set
 	logger *slog.Logger
+
+	writer      string // sync user when DDL runs as an admin user, otherwise ""
+	readerRole  string // role granted read access to everything the adapter creates
+	rowSecurity []RowSecurityRule
+	company     string          // Flexibee company of the synced data, matched against rule companies
+	unfiltered  map[string]bool // tables without a cost center that restricted roles may read in full
+
+	mu            sync.Mutex
+	partitionKeys map[string]string // table -> partition column ("" = not partitioned)
+	partitions    map[string]bool   // partitions known to exist
 }
 
 // NewStore creates a new Store with a connection pool.
@@ -43,32 +78,49 @@ func NewStore(ctx context.Context, databaseURL string, logger *slog.Logger) (*St
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
 
-	return &Store{pool: pool, logger: logger}, nil
+	return &Store{
+		pool:          pool,
+		admin:         pool,
+		logger:        logger,
+		partitionKeys: make(map[string]string),
+		partitions:    make(map[string]bool),
+	}, nil
 }
 
 // Pool returns the underlying connection pool (for schema operations).
+// With an admin URL this is the admin pool.
 func (s *Store) Pool() *pgxpool.Pool {
-	return s.pool
-}
-
-// RunMigrations executes the embedded migration SQL.
-func (s *Store) RunMigrations(ctx context.Context) error {
-	if _, err := s.pool.Exec(ctx, migrationSQL); err != nil {
-		return fmt.Errorf("run migrations: %w", err)
-	}
-	s.logger.Info("migrations applied successfully")
-	return nil
+	return s.admin
 }
 
 // UpsertRecords inserts or updates records in the given table.
 // Returns the number of records upserted.
+//
+// For tables partitioned by a date column, missing monthly partitions are
+// created first and a record whose date moved to another month is removed
+// from its old partition. Records without a partition column value cannot
+// be stored and are left out of the count.
 func (s *Store) UpsertRecords(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error) {
 	if len(records) == 0 {
 		return 0, nil
 	}
 
+	partCol, err := s.partitionColumn(ctx, table)
+	if err != nil {
+		return 0, err
+	}
+	if partCol != "" {
+		if err := s.ensureRecordPartitions(ctx, table, partCol, records); err != nil {
+			return 0, err
+		}
+	}
+
 	safeTable := sanitizeIdentifier(table)
 	safePK := sanitizeIdentifier(primaryKey)
+	conflictCols := safePK
+	if partCol != "" {
+		conflictCols = safePK + ", " + sanitizeIdentifier(partCol)
+	}
 	count := 0
 
 	for _, record := range records {
@@ -83,6 +135,10 @@ func (s *Store) UpsertRecords(ctx context.Context, table string, records []map[s
 			s.logger.Warn("record missing primary key", "key", primaryKey)
 			continue
 		}
+		if partCol != "" && record[partCol] == nil {
+			s.logger.Warn("record missing partition column", "table", table, "id", id, "column", partCol)
+			continue
+		}
 
 		// Build column names and values for the upsert
 		cols := []string{safePK, sanitizeIdentifier("raw_data"), sanitizeIdentifier("synced_at")}
@@ -94,10 +150,14 @@ func (s *Store) UpsertRecords(ctx context.Context, table string, records []map[s
 		args := []any{id, rawJSON}
 
 		argIdx := 3
+		partIdx := 0
 		for k, v := range record {
 			if k == primaryKey {
 				continue
 			}
+			if k == partCol {
+				partIdx = argIdx
+			}
 			safeCol := sanitizeIdentifier(k)
 			cols = append(cols, safeCol)
 			placeholders = append(placeholders, fmt.Sprintf("$%d", argIdx))
@@ -111,9 +171,16 @@ func (s *Store) UpsertRecords(ctx context.Context, table string, records []map[s
 			safeTable,
 			strings.Join(cols, ", "),
 			strings.Join(placeholders, ", "),
-			safePK,
+			conflictCols,
 			strings.Join(updates, ", "),
 		)
+		if partCol != "" {
+			// Drop the previous version if its date moved it to another partition.
+			query = fmt.Sprintf(
+				"WITH moved AS (DELETE FROM %s WHERE %s = $1 AND %s IS DISTINCT FROM $%d) %s",
+				safeTable, safePK, sanitizeIdentifier(partCol), partIdx, query,
+			)
+		}
 
 		if _, err := s.pool.Exec(ctx, query, args...); err != nil {
 			s.logger.Warn("failed to upsert record", "table", table, "id", id, "error", err)
@@ -154,10 +221,16 @@ func (s *Store) DeleteRecords(ctx context.Context, table string, ids []any) (int
 // GetSyncState returns the sync state for an evidence type.
 func (s *Store) GetSyncState(ctx context.Context, evidence string) (*SyncState, error) {
 	var state SyncState
-	err := s.pool.QueryRow(ctx,
-		"SELECT evidence, last_update, last_sync, row_count, status, COALESCE(error_msg, '') FROM sync_state WHERE evidence = $1",
+	var cursorID sql.NullInt64
+	var cursorFilter sql.NullString
+	var cursorStartedAt *time.Time
+	err := s.pool.QueryRow(ctx, `
+		SELECT evidence, last_update, last_sync, row_count, status, COALESCE(error_msg, ''),
+			cursor_id, cursor_filter, cursor_started_at
+		FROM sync_state WHERE evidence = $1`,
 		evidence,
-	).Scan(&state.Evidence, &state.LastUpdate, &state.LastSync, &state.RowCount, &state.Status, &state.ErrorMsg)
+	).Scan(&state.Evidence, &state.LastUpdate, &state.LastSync, &state.RowCount, &state.Status, &state.ErrorMsg,
+		&cursorID, &cursorFilter, &cursorStartedAt)
 
 	if err != nil {
 		if err.Error() == "no rows in result set" {
@@ -166,17 +239,22 @@ func (s *Store) GetSyncState(ctx context.Context, evidence string) (*SyncState,
 		return nil, fmt.Errorf("get sync state for %s: %w", evidence, err)
 	}
 
+	state.Cursor = scannedCursor(cursorID, cursorFilter, cursorStartedAt)
 	return &state, nil
 }
 
 // SetSyncState creates or updates the sync state for an evidence type.
 func (s *Store) SetSyncState(ctx context.Context, evidence string, state SyncState) error {
+	cursorID, cursorFilter, cursorStartedAt := cursorValues(state.Cursor)
 	_, err := s.pool.Exec(ctx, `
-		INSERT INTO sync_state (evidence, last_update, last_sync, row_count, status, error_msg)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO sync_state (evidence, last_update, last_sync, row_count, status, error_msg,
+			cursor_id, cursor_filter, cursor_started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		ON CONFLICT (evidence) DO UPDATE SET
-			last_update = $2, last_sync = $3, row_count = $4, status = $5, error_msg = $6
-	`, evidence, state.LastUpdate, state.LastSync, state.RowCount, state.Status, state.ErrorMsg)
+			last_update = $2, last_sync = $3, row_count = $4, status = $5, error_msg = $6,
+			cursor_id = $7, cursor_filter = $8, cursor_started_at = $9
+	`, evidence, state.LastUpdate, state.LastSync, state.RowCount, state.Status, state.ErrorMsg,
+		cursorID, cursorFilter, cursorStartedAt)
 
 	if err != nil {
 		return fmt.Errorf("set sync state for %s: %w", evidence, err)
@@ -214,6 +292,17 @@ func (s *Store) CleanupOldRecords(ctx context.Context, table string, olderThan t
 	return totalDeleted, nil
 }
 
+// CountOldRecords returns the number of records CleanupOldRecords would
+// delete, without deleting anything.
+func (s *Store) CountOldRecords(ctx context.Context, table string, olderThan time.Time) (int64, error) {
+	var count int64
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE "synced_at" < $1`, sanitizeIdentifier(table))
+	if err := s.pool.QueryRow(ctx, query, olderThan).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count old records of %s: %w", table, err)
+	}
+	return count, nil
+}
+
 // LogCleanup records a cleanup operation.
 func (s *Store) LogCleanup(ctx context.Context, evidence string, rowsDeleted int64, oldestKept *time.Time) error {
 	_, err := s.pool.Exec(ctx,
@@ -226,7 +315,15 @@ func (s *Store) LogCleanup(ctx context.Context, evidence string, rowsDeleted int
 	return nil
 }
 
-// Close closes the connection pool.
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Close closes the connection pools.
 func (s *Store) Close() {
+	if s.admin != s.pool {
+		s.admin.Close()
+	}
 	s.pool.Close()
 }
@@ -1,16 +1,38 @@
 package store
 
 import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
 	"testing"
 
-	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestMigrationSQL_Embedded(t *testing.T) {
-	t.Parallel()
-	// Verify the migration SQL is properly embedded and contains expected statements
-	assert.Contains(t, migrationSQL, "CREATE TABLE IF NOT EXISTS sync_state")
-	assert.Contains(t, migrationSQL, "CREATE TABLE IF NOT EXISTS cleanup_log")
-	assert.Contains(t, migrationSQL, "evidence    TEXT PRIMARY KEY")
-	assert.Contains(t, migrationSQL, "rows_deleted BIGINT NOT NULL")
+// newTestStore connects to the PostgreSQL database at TEST_DATABASE_URL,
+// skipping the test when it is not set.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	st, err := NewStore(context.Background(), url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(st.Close)
+	return st
+}
+
+// dropTestTables drops tables created by a test once it finishes.
+func dropTestTables(t *testing.T, st *Store, tables ...string) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		for _, table := range tables {
+			_, _ = st.Pool().Exec(context.Background(), "DROP TABLE IF EXISTS "+sanitizeIdentifier(table)+" CASCADE")
+		}
+	})
 }
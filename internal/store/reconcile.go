@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AggregateQuery selects the records of a synced table to aggregate.
+type AggregateQuery struct {
+	// DateColumn restricts the records to From <= DateColumn < To (dates
+	// only); empty aggregates the whole table.
+	DateColumn string
+	From, To   time.Time
+	// SumColumns are the columns to total.
+	SumColumns []string
+}
+
+// Aggregate is the record count and column totals of a synced table.
+type Aggregate struct {
+	Count int64
+	Sums  map[string]float64
+}
+
+// Reconciliation is the comparison of one metric of an evidence and period
+// between Flexibee and the synced table.
+type Reconciliation struct {
+	Evidence  string
+	Period    string // "YYYY-MM", or "all" for the whole evidence
+	Metric    string // "count" or the name of a totalled column
+	Flexibee  float64
+	Synced    float64
+	Drift     bool
+	CheckedAt time.Time
+}
+
+// aggregateSQL builds the query counting and totalling the records of
+// table selected by q. quote quotes identifiers; the date bounds use the
+// given placeholders.
+func aggregateSQL(table string, q AggregateQuery, quote func(string) string, from, to string) string {
+	cols := []string{"COUNT(*)"}
+	for _, col := range q.SumColumns {
+		cols = append(cols, fmt.Sprintf("COALESCE(SUM(%s), 0)", quote(col)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), quote(table))
+	if q.DateColumn != "" {
+		dc := quote(q.DateColumn)
+		query += fmt.Sprintf(" WHERE %s >= %s AND %s < %s", dc, from, dc, to)
+	}
+	return query
+}
+
+// aggregateArgs returns the date bound arguments of q.
+func aggregateArgs(q AggregateQuery) []any {
+	if q.DateColumn == "" {
+		return nil
+	}
+	return []any{q.From.Format(time.DateOnly), q.To.Format(time.DateOnly)}
+}
+
+// scanAggregate scans a row of aggregateSQL into an Aggregate.
+func scanAggregate(q AggregateQuery, scan func(dest ...any) error) (Aggregate, error) {
+	agg := Aggregate{Sums: make(map[string]float64, len(q.SumColumns))}
+	sums := make([]float64, len(q.SumColumns))
+	dest := []any{&agg.Count}
+	for i := range sums {
+		dest = append(dest, &sums[i])
+	}
+	if err := scan(dest...); err != nil {
+		return Aggregate{}, err
+	}
+	for i, col := range q.SumColumns {
+		agg.Sums[col] = sums[i]
+	}
+	return agg, nil
+}
+
+// AggregateRecords counts and totals the records of table selected by q.
+func (s *Store) AggregateRecords(ctx context.Context, table string, q AggregateQuery) (Aggregate, error) {
+	query := aggregateSQL(table, q, sanitizeIdentifier, "$1", "$2")
+	agg, err := scanAggregate(q, s.pool.QueryRow(ctx, query, aggregateArgs(q)...).Scan)
+	if err != nil {
+		return Aggregate{}, fmt.Errorf("aggregate %s: %w", table, err)
+	}
+	return agg, nil
+}
+
+// SaveReconciliation stores the results of a reconciliation run, replacing
+// earlier results of the same evidence, period and metric.
+func (s *Store) SaveReconciliation(ctx context.Context, results []Reconciliation) error {
+	for _, r := range results {
+		_, err := s.pool.Exec(ctx, `
+			INSERT INTO reconciliation (evidence, period, metric, flexibee_value, synced_value, difference, drift, checked_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (evidence, period, metric) DO UPDATE SET
+				flexibee_value = $4, synced_value = $5, difference = $6, drift = $7, checked_at = $8
+		`, r.Evidence, r.Period, r.Metric, r.Flexibee, r.Synced, r.Synced-r.Flexibee, r.Drift, r.CheckedAt)
+		if err != nil {
+			return fmt.Errorf("save reconciliation of %s: %w", r.Evidence, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// errRolesUnsupported is returned by sinks other than PostgreSQL when an
+// admin URL or reader role is configured.
+var errRolesUnsupported = errors.New("database roles are only supported on PostgreSQL")
+
+// Options are optional connection settings of a sink.
+type Options struct {
+	// AdminURL connects as a privileged user for migrations and DDL. The
+	// database URL is then only used to read and write records, and its
+	// user is granted exactly that on the adapter's tables.
+	AdminURL string
+	// ReaderRole is created if missing and granted SELECT on every table
+	// and view the adapter creates, including future ones.
+	ReaderRole string
+	// RowSecurity enables row-level security on the synced tables,
+	// restricting each listed role to rows of its cost centers.
+	RowSecurity []RowSecurityRule
+	// Company is the Flexibee company the synced data belongs to. Rules
+	// limited to other companies grant their role nothing.
+	Company string
+	// UnfilteredTables lists the synced tables without a cost center column
+	// that restricted roles may read in full. Restricted roles get no access
+	// to other such tables.
+	UnfilteredTables []string
+}
+
+// empty reports whether no options are set. Company and UnfilteredTables
+// only refine RowSecurity.
+func (o Options) empty() bool {
+	return o.AdminURL == "" && o.ReaderRole == "" && len(o.RowSecurity) == 0
+}
+
+// configure applies opts to a freshly connected store.
+func (s *Store) configure(ctx context.Context, opts Options) error {
+	if opts.AdminURL != "" {
+		admin, err := pgxpool.New(ctx, opts.AdminURL)
+		if err != nil {
+			return fmt.Errorf("connect to database as admin: %w", err)
+		}
+		if err := admin.Ping(ctx); err != nil {
+			admin.Close()
+			return fmt.Errorf("ping database as admin: %w", err)
+		}
+		s.admin = admin
+
+		if err := s.pool.QueryRow(ctx, "SELECT current_user").Scan(&s.writer); err != nil {
+			return fmt.Errorf("look up sync user: %w", err)
+		}
+	}
+	s.readerRole = opts.ReaderRole
+	s.rowSecurity = opts.RowSecurity
+	s.company = opts.Company
+	s.unfiltered = make(map[string]bool, len(opts.UnfilteredTables))
+	for _, table := range opts.UnfilteredTables {
+		s.unfiltered[table] = true
+	}
+	return nil
+}
+
+// grantPrivileges provisions the reader role, the roles of row security
+// rules and, with an admin URL, the sync user's privileges. Default
+// privileges are set for the admin user, so they cover every table it
+// creates later.
+func (s *Store) grantPrivileges(ctx context.Context) error {
+	if s.readerRole == "" && s.writer == "" && len(s.rowSecurity) == 0 {
+		return nil
+	}
+
+	var schema string
+	if err := s.admin.QueryRow(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		return fmt.Errorf("look up schema: %w", err)
+	}
+
+	if s.readerRole != "" {
+		if err := s.ensureRole(ctx, s.readerRole); err != nil {
+			return err
+		}
+	}
+
+	stmts := grantStatements(schema, s.readerRole, s.writer)
+	for _, rule := range s.rowSecurity {
+		if !rule.appliesTo(s.company) {
+			continue
+		}
+		// Restricted roles are granted SELECT per table, next to the table's
+		// row-level security policies.
+		if err := s.ensureRole(ctx, rule.Role); err != nil {
+			return err
+		}
+		stmts = append(stmts, fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s",
+			pgx.Identifier{schema}.Sanitize(), pgx.Identifier{rule.Role}.Sanitize()))
+	}
+
+	for _, stmt := range stmts {
+		if _, err := s.admin.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("grant privileges: %w", err)
+		}
+	}
+	s.logger.Info("granted database privileges", "schema", schema, "reader", s.readerRole, "writer", s.writer)
+	return nil
+}
+
+// ensureRole creates role as a NOLOGIN role if it does not exist.
+func (s *Store) ensureRole(ctx context.Context, role string) error {
+	var exists bool
+	if err := s.admin.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = $1)", role,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("look up role %s: %w", role, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := s.admin.Exec(ctx, fmt.Sprintf("CREATE ROLE %s NOLOGIN", pgx.Identifier{role}.Sanitize())); err != nil {
+		return fmt.Errorf("create role %s: %w", role, err)
+	}
+	s.logger.Info("created database role", "role", role)
+	return nil
+}
+
+// grantStatements returns the GRANT statements giving reader read access
+// and writer read-write access to every current and future table, view and
+// sequence in schema. Empty roles are skipped.
+func grantStatements(schema, reader, writer string) []string {
+	safeSchema := pgx.Identifier{schema}.Sanitize()
+
+	var stmts []string
+	if reader != "" {
+		r := pgx.Identifier{reader}.Sanitize()
+		stmts = append(stmts,
+			fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", safeSchema, r),
+			fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA %s TO %s", safeSchema, r),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA %s GRANT SELECT ON TABLES TO %s", safeSchema, r),
+		)
+	}
+	if writer != "" {
+		w := pgx.Identifier{writer}.Sanitize()
+		stmts = append(stmts,
+			fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", safeSchema, w),
+			fmt.Sprintf("GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %s TO %s", safeSchema, w),
+			fmt.Sprintf("GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA %s TO %s", safeSchema, w),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA %s GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO %s", safeSchema, w),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA %s GRANT USAGE, SELECT ON SEQUENCES TO %s", safeSchema, w),
+		)
+	}
+	return stmts
+}
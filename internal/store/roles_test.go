@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantStatements(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, grantStatements("public", "", ""))
+
+	stmts := grantStatements("public", "metabase-reader", "")
+	assert.Equal(t, []string{
+		`GRANT USAGE ON SCHEMA "public" TO "metabase-reader"`,
+		`GRANT SELECT ON ALL TABLES IN SCHEMA "public" TO "metabase-reader"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" GRANT SELECT ON TABLES TO "metabase-reader"`,
+	}, stmts)
+
+	stmts = grantStatements("flexibee", "reader", "sync")
+	assert.Len(t, stmts, 8)
+	assert.Contains(t, stmts, `GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA "flexibee" TO "sync"`)
+	assert.Contains(t, stmts, `ALTER DEFAULT PRIVILEGES IN SCHEMA "flexibee" GRANT USAGE, SELECT ON SEQUENCES TO "sync"`)
+	assert.NotContains(t, stmts, `GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA "flexibee" TO "reader"`)
+}
+
+func TestOpen_OptionsRequirePostgres(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := Options{ReaderRole: "reader"}
+
+	_, err := Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "roles.db"), opts, logger)
+	assert.ErrorIs(t, err, errRolesUnsupported)
+
+	_, err = Open(context.Background(), "mysql://user@localhost/flexibee", opts, logger)
+	assert.ErrorIs(t, err, errRolesUnsupported)
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rowSecurityPolicyPrefix marks the row-level security policies managed by
+// the adapter; other policies on synced tables are left alone.
+const rowSecurityPolicyPrefix = "flexibee_"
+
+// costCenterColumn is the Flexibee relation to a cost center (středisko).
+const costCenterColumn = "stredisko"
+
+// RowSecurityRule restricts a database role to rows of the listed Flexibee
+// cost centers (stredisko codes, e.g. "PRAHA"). A rule without cost
+// centers allows the role to read all rows. A rule with companies grants
+// nothing unless the synced company is one of them.
+type RowSecurityRule struct {
+	Role        string
+	Companies   []string
+	CostCenters []string
+}
+
+// appliesTo reports whether the rule covers the data of company.
+func (r RowSecurityRule) appliesTo(company string) bool {
+	return len(r.Companies) == 0 || slices.Contains(r.Companies, company)
+}
+
+// rowPolicy is the SELECT policy of a role on one table.
+type rowPolicy struct {
+	role      string
+	condition string
+}
+
+// ensureRowSecurity enables row-level security on table with one policy per
+// rule, filtering by the cost center column of source: table itself, or
+// the synced table of a history table. Restricted roles get no policy on
+// tables without a cost center column unless source is an unfiltered table,
+// so they cannot read them. Without rules, previously created policies are
+// dropped and row-level security is disabled again.
+func (s *Store) ensureRowSecurity(ctx context.Context, table, source string) error {
+	existing, err := managedPolicies(ctx, s.admin, table)
+	if err != nil {
+		return err
+	}
+	if len(s.rowSecurity) == 0 && len(existing) == 0 {
+		return nil
+	}
+
+	filter := ""
+	if len(s.rowSecurity) > 0 {
+		columns, err := getExistingColumns(ctx, s.admin, source)
+		if err != nil {
+			return fmt.Errorf("get columns for %s: %w", source, err)
+		}
+		if columns[costCenterColumn] {
+			filter = fmt.Sprintf("flexibee_relation_code(%s)", sanitizeIdentifier(costCenterColumn))
+			if table != source {
+				filter = fmt.Sprintf("flexibee_relation_code(raw_data->>'%s')", costCenterColumn)
+			}
+		}
+	}
+
+	partitions, err := partitionsOf(ctx, s.admin, table)
+	if err != nil {
+		return err
+	}
+
+	policies, denied := rowPolicies(s.rowSecurity, s.company, filter, s.unfiltered[source])
+	if len(denied) > 0 {
+		s.logger.Info("denied restricted roles a table without cost center", "table", table, "roles", denied)
+	}
+
+	stmts := rowSecurityStatements(table, len(s.rowSecurity) > 0, policies, s.unrestrictedRoles(), existing, partitions)
+	err = pgx.BeginFunc(ctx, s.admin, func(tx pgx.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ensure row security on %s: %w", table, err)
+	}
+
+	s.logger.Debug("ensured row security", "table", table, "policies", len(policies), "filtered", filter != "")
+	return nil
+}
+
+// unrestrictedRoles are the roles that keep access to all rows: the sync
+// user when it does not own the tables, and the reader role.
+func (s *Store) unrestrictedRoles() []string {
+	var roles []string
+	if s.writer != "" {
+		roles = append(roles, s.writer)
+	}
+	if s.readerRole != "" {
+		roles = append(roles, s.readerRole)
+	}
+	return roles
+}
+
+// managedPolicies returns the names of the adapter's policies on table.
+func managedPolicies(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT policyname FROM pg_policies
+		WHERE schemaname = current_schema() AND tablename = $1 AND starts_with(policyname, $2)
+		ORDER BY policyname
+	`, table, rowSecurityPolicyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("list policies of %s: %w", table, err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("list policies of %s: %w", table, err)
+	}
+	return names, nil
+}
+
+// rowPolicies returns the policies of rules on a table whose cost center is
+// read by filter ("" when it has none). Roles of rules for other companies
+// get no policy. Roles restricted to cost centers get no policy on tables
+// without one, unless unfiltered allows reading them in full; those roles
+// are returned as denied.
+func rowPolicies(rules []RowSecurityRule, company, filter string, unfiltered bool) (policies []rowPolicy, denied []string) {
+	for _, rule := range rules {
+		if !rule.appliesTo(company) {
+			continue
+		}
+		if filter == "" && len(rule.CostCenters) > 0 && !unfiltered {
+			denied = append(denied, rule.Role)
+			continue
+		}
+		policies = append(policies, rowPolicy{role: rule.Role, condition: costCenterCondition(filter, rule.CostCenters)})
+	}
+	return policies, denied
+}
+
+// rowSecurityStatements returns the statements replacing the existing
+// adapter policies of table with policies, enabling or disabling row-level
+// security. Roles without a policy see no rows while it is enabled.
+// Partitions get row security enabled without policies, so they cannot be
+// read directly around the policies of their table.
+func rowSecurityStatements(table string, enabled bool, policies []rowPolicy, unrestricted, existing, partitions []string) []string {
+	safeTable := sanitizeIdentifier(table)
+
+	var stmts []string
+	for _, name := range existing {
+		stmts = append(stmts, fmt.Sprintf("DROP POLICY IF EXISTS %s ON %s", pgx.Identifier{name}.Sanitize(), safeTable))
+	}
+
+	action := "ENABLE"
+	if !enabled {
+		action = "DISABLE"
+	}
+	for _, t := range append([]string{table}, partitions...) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s %s ROW LEVEL SECURITY", sanitizeIdentifier(t), action))
+	}
+	if !enabled {
+		return stmts
+	}
+
+	if len(unrestricted) > 0 {
+		roles := make([]string, len(unrestricted))
+		for i, r := range unrestricted {
+			roles[i] = pgx.Identifier{r}.Sanitize()
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE POLICY %s ON %s FOR ALL TO %s USING (true) WITH CHECK (true)",
+			pgx.Identifier{rowSecurityPolicyPrefix + "unrestricted"}.Sanitize(), safeTable, strings.Join(roles, ", "),
+		))
+	}
+
+	for _, p := range policies {
+		role := pgx.Identifier{p.role}.Sanitize()
+		stmts = append(stmts,
+			fmt.Sprintf("CREATE POLICY %s ON %s FOR SELECT TO %s USING (%s)",
+				pgx.Identifier{rowSecurityPolicyPrefix + "rls_" + p.role}.Sanitize(), safeTable, role, p.condition),
+			fmt.Sprintf("GRANT SELECT ON %s TO %s", safeTable, role),
+		)
+	}
+	return stmts
+}
+
+// costCenterCondition returns the policy condition allowing the rows whose
+// filter expression matches one of costCenters, or all rows without either.
+func costCenterCondition(filter string, costCenters []string) string {
+	if filter == "" || len(costCenters) == 0 {
+		return "true"
+	}
+	quoted := make([]string, len(costCenters))
+	for i, code := range costCenters {
+		quoted[i] = "'" + strings.ReplaceAll(code, "'", "''") + "'"
+	}
+	return fmt.Sprintf("%s IN (%s)", filter, strings.Join(quoted, ", "))
+}
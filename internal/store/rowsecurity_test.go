@@ -0,0 +1,90 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostCenterCondition(t *testing.T) {
+	t.Parallel()
+
+	filter := `flexibee_relation_code("stredisko")`
+	assert.Equal(t, `flexibee_relation_code("stredisko") IN ('PRAHA', 'O''HARA')`, costCenterCondition(filter, []string{"PRAHA", "O'HARA"}))
+	assert.Equal(t, "true", costCenterCondition(filter, nil), "rules without cost centers allow all rows")
+	assert.Equal(t, "true", costCenterCondition("", []string{"PRAHA"}), "unfiltered tables are readable in full")
+}
+
+func TestRowPolicies(t *testing.T) {
+	t.Parallel()
+
+	filter := `flexibee_relation_code("stredisko")`
+	rules := []RowSecurityRule{
+		{Role: "manager_praha", CostCenters: []string{"PRAHA"}},
+		{Role: "manager_firma_b", Companies: []string{"firma_b"}, CostCenters: []string{"BRNO"}},
+		{Role: "auditor", Companies: []string{"firma_a", "firma_b"}},
+	}
+
+	policies, denied := rowPolicies(rules, "firma_a", filter, false)
+	assert.Equal(t, []rowPolicy{
+		{role: "manager_praha", condition: `flexibee_relation_code("stredisko") IN ('PRAHA')`},
+		{role: "auditor", condition: "true"},
+	}, policies, "rules of other companies get no policy")
+	assert.Empty(t, denied)
+
+	policies, denied = rowPolicies(rules, "firma_a", "", false)
+	assert.Equal(t, []rowPolicy{{role: "auditor", condition: "true"}}, policies)
+	assert.Equal(t, []string{"manager_praha"}, denied, "restricted roles are denied tables without a cost center")
+
+	policies, denied = rowPolicies(rules, "firma_a", "", true)
+	assert.Equal(t, []rowPolicy{
+		{role: "manager_praha", condition: "true"},
+		{role: "auditor", condition: "true"},
+	}, policies, "unfiltered tables are readable in full")
+	assert.Empty(t, denied)
+}
+
+func TestRowSecurityStatements(t *testing.T) {
+	t.Parallel()
+
+	policies := []rowPolicy{
+		{role: "manager_praha", condition: `flexibee_relation_code("stredisko") IN ('PRAHA')`},
+		{role: "auditor", condition: "true"},
+	}
+	stmts := rowSecurityStatements("flexibee_banka", true, policies,
+		[]string{"sync", "metabase_reader"}, []string{"flexibee_rls_old"}, []string{"flexibee_banka_p202401"})
+
+	assert.Equal(t, []string{
+		`DROP POLICY IF EXISTS "flexibee_rls_old" ON "flexibee_banka"`,
+		`ALTER TABLE "flexibee_banka" ENABLE ROW LEVEL SECURITY`,
+		`ALTER TABLE "flexibee_banka_p202401" ENABLE ROW LEVEL SECURITY`,
+		`CREATE POLICY "flexibee_unrestricted" ON "flexibee_banka" FOR ALL TO "sync", "metabase_reader" USING (true) WITH CHECK (true)`,
+		`CREATE POLICY "flexibee_rls_manager_praha" ON "flexibee_banka" FOR SELECT TO "manager_praha" USING (flexibee_relation_code("stredisko") IN ('PRAHA'))`,
+		`GRANT SELECT ON "flexibee_banka" TO "manager_praha"`,
+		`CREATE POLICY "flexibee_rls_auditor" ON "flexibee_banka" FOR SELECT TO "auditor" USING (true)`,
+		`GRANT SELECT ON "flexibee_banka" TO "auditor"`,
+	}, stmts)
+}
+
+func TestRowSecurityStatements_NoRulesDisables(t *testing.T) {
+	t.Parallel()
+
+	stmts := rowSecurityStatements("flexibee_banka", false, nil, nil, []string{"flexibee_rls_auditor", "flexibee_unrestricted"}, nil)
+
+	assert.Equal(t, []string{
+		`DROP POLICY IF EXISTS "flexibee_rls_auditor" ON "flexibee_banka"`,
+		`DROP POLICY IF EXISTS "flexibee_unrestricted" ON "flexibee_banka"`,
+		`ALTER TABLE "flexibee_banka" DISABLE ROW LEVEL SECURITY`,
+	}, stmts)
+}
+
+func TestRowSecurityStatements_NoPolicies(t *testing.T) {
+	t.Parallel()
+
+	stmts := rowSecurityStatements("flexibee_adresar", true, nil, []string{"sync"}, nil, nil)
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "flexibee_adresar" ENABLE ROW LEVEL SECURITY`,
+		`CREATE POLICY "flexibee_unrestricted" ON "flexibee_adresar" FOR ALL TO "sync" USING (true) WITH CHECK (true)`,
+	}, stmts, "roles without a policy see no rows")
+}
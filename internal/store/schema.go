@@ -32,23 +32,43 @@ func FlexibeeTypeToPG(prop flexibee.Property) string {
 	}
 }
 
+// TableOptions controls optional physical layout of a synced table.
+type TableOptions struct {
+	// PartitionColumn range-partitions a new table by month on this date
+	// column ("" = plain table). Existing plain tables are left unchanged.
+	PartitionColumn string
+	// PartitionMonthsAhead is how many future monthly partitions to create.
+	PartitionMonthsAhead int
+	// IndexRawData adds a GIN index on raw_data for JSON containment queries.
+	IndexRawData bool
+	// Indexes lists extra indexes to create, each given by its columns.
+	Indexes [][]string
+}
+
 // EnsureTable creates a table if it doesn't exist and adds any new columns
-// based on the Flexibee property definitions.
-func EnsureTable(ctx context.Context, pool *pgxpool.Pool, table string, properties []flexibee.Property, logger *slog.Logger) error {
+// based on the Flexibee property definitions. Its indexes are created by
+// EnsureIndexes.
+func EnsureTable(ctx context.Context, pool *pgxpool.Pool, table string, properties []flexibee.Property, opts TableOptions, logger *slog.Logger) error {
 	// Sanitize table name
 	safeTable := sanitizeIdentifier(table)
 
-	// Create table with base columns if it doesn't exist
-	createSQL := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id BIGINT PRIMARY KEY,
-			raw_data JSONB,
-			synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		)
-	`, safeTable)
-
-	if _, err := pool.Exec(ctx, createSQL); err != nil {
-		return fmt.Errorf("create table %s: %w", table, err)
+	if opts.PartitionColumn != "" {
+		if err := ensurePartitionedTable(ctx, pool, table, properties, opts, logger); err != nil {
+			return err
+		}
+	} else {
+		// Create table with base columns if it doesn't exist
+		createSQL := fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id BIGINT PRIMARY KEY,
+				raw_data JSONB,
+				synced_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+			)
+		`, safeTable)
+
+		if _, err := pool.Exec(ctx, createSQL); err != nil {
+			return fmt.Errorf("create table %s: %w", table, err)
+		}
 	}
 
 	// Get existing columns
@@ -74,12 +94,49 @@ func EnsureTable(ctx context.Context, pool *pgxpool.Pool, table string, properti
 			logger.Warn("failed to add column", "table", table, "column", prop.Name, "error", err)
 			continue
 		}
+		existing[prop.Name] = true
 		logger.Debug("added column", "table", table, "column", prop.Name, "type", pgType)
 	}
 
 	return nil
 }
 
+// ensurePartitionedTable creates table partitioned by opts.PartitionColumn
+// and its partitions ahead of time. A table that already exists without
+// partitioning is kept as is, since converting it would mean rewriting it;
+// the cleanup then deletes its old rows instead of dropping partitions.
+func ensurePartitionedTable(ctx context.Context, pool *pgxpool.Pool, table string, properties []flexibee.Property, opts TableOptions, logger *slog.Logger) error {
+	existing, err := getExistingColumns(ctx, pool, table)
+	if err != nil {
+		return fmt.Errorf("get columns for %s: %w", table, err)
+	}
+
+	if len(existing) == 0 {
+		colType := "DATE"
+		for _, prop := range properties {
+			if prop.Name == opts.PartitionColumn {
+				colType = FlexibeeTypeToPG(prop)
+			}
+		}
+		if err := createPartitionedTable(ctx, pool, table, opts.PartitionColumn, colType); err != nil {
+			return err
+		}
+		logger.Info("created partitioned table", "table", table, "column", opts.PartitionColumn)
+	} else {
+		partitioned, err := isPartitioned(ctx, pool, table)
+		if err != nil {
+			return fmt.Errorf("check partitioning of %s: %w", table, err)
+		}
+		if !partitioned {
+			logger.Warn("table exists without partitioning, old rows are deleted instead; drop it to recreate as partitioned",
+				"table", table, "column", opts.PartitionColumn)
+			return nil
+		}
+	}
+
+	return ensurePartitionsAhead(ctx, pool, table, opts.PartitionMonthsAhead, logger)
+}
+
 func getExistingColumns(ctx context.Context, pool *pgxpool.Pool, table string) (map[string]bool, error) {
 	rows, err := pool.Query(ctx,
 		"SELECT column_name FROM information_schema.columns WHERE table_name = $1",
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+// ColumnDiff is a difference between the columns Flexibee describes for an
+// evidence and the columns of its PostgreSQL table.
+type ColumnDiff struct {
+	Table    string
+	Column   string
+	Expected string // "" = column not described by Flexibee
+	Actual   string // "" = column missing from the table
+}
+
+// pgDataTypes maps the types of FlexibeeTypeToPG to their names in
+// information_schema.columns.
+var pgDataTypes = map[string]string{
+	"BIGINT":      "bigint",
+	"NUMERIC":     "numeric",
+	"DATE":        "date",
+	"TIMESTAMPTZ": "timestamp with time zone",
+	"BOOLEAN":     "boolean",
+	"TEXT":        "text",
+}
+
+// DiffTable compares the columns of table with the columns properties map
+// to. Base columns created for every table are ignored.
+func (s *Store) DiffTable(ctx context.Context, table string, properties []flexibee.Property) ([]ColumnDiff, error) {
+	actual, err := getColumnTypes(ctx, s.pool, table)
+	if err != nil {
+		return nil, fmt.Errorf("get columns for %s: %w", table, err)
+	}
+	return diffColumns(table, properties, actual), nil
+}
+
+// diffColumns lists missing and mistyped columns in property order,
+// followed by columns Flexibee does not describe, by name.
+func diffColumns(table string, properties []flexibee.Property, actual map[string]string) []ColumnDiff {
+	base := map[string]bool{"id": true, "raw_data": true, "synced_at": true}
+
+	var diffs []ColumnDiff
+	expected := make(map[string]bool, len(properties))
+	for _, prop := range properties {
+		column := strings.ReplaceAll(prop.Name, "-", "_")
+		expected[column] = true
+		if base[column] {
+			continue
+		}
+
+		want := pgDataTypes[FlexibeeTypeToPG(prop)]
+		if got := actual[column]; got != want {
+			diffs = append(diffs, ColumnDiff{Table: table, Column: column, Expected: want, Actual: got})
+		}
+	}
+
+	for _, column := range slices.Sorted(maps.Keys(actual)) {
+		if !expected[column] && !base[column] {
+			diffs = append(diffs, ColumnDiff{Table: table, Column: column, Actual: actual[column]})
+		}
+	}
+	return diffs
+}
+
+func getColumnTypes(ctx context.Context, pool *pgxpool.Pool, table string) (map[string]string, error) {
+	rows, err := pool.Query(ctx,
+		"SELECT column_name, data_type FROM information_schema.columns WHERE table_name = $1",
+		table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols := make(map[string]string)
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, err
+		}
+		cols[name] = dataType
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+func TestDiffColumns(t *testing.T) {
+	t.Parallel()
+
+	props := []flexibee.Property{
+		{Name: "id", Type: "integer"},
+		{Name: "kod", Type: "string"},
+		{Name: "sumCelkem", Type: "numeric"},
+		{Name: "datVyst", Type: "date"},
+		{Name: "storno", Type: "logic"},
+	}
+	actual := map[string]string{
+		"id":        "bigint",
+		"raw_data":  "jsonb",
+		"synced_at": "timestamp with time zone",
+		"kod":       "text",
+		"sumCelkem": "text",
+		"datVyst":   "date",
+		"stary":     "text",
+	}
+
+	assert.Equal(t, []ColumnDiff{
+		{Table: "flexibee_faktura_vydana", Column: "sumCelkem", Expected: "numeric", Actual: "text"},
+		{Table: "flexibee_faktura_vydana", Column: "storno", Expected: "boolean"},
+		{Table: "flexibee_faktura_vydana", Column: "stary", Actual: "text"},
+	}, diffColumns("flexibee_faktura_vydana", props, actual))
+
+	assert.Empty(t, diffColumns("flexibee_faktura_vydana", props[:2], map[string]string{"id": "bigint", "kod": "text"}))
+}
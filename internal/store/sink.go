@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+// Sink is a database synced Flexibee data is written to. Store (PostgreSQL),
+// MySQLStore and SQLiteStore implement it.
+type Sink interface {
+	// RunMigrations applies pending migrations of the adapter's own tables.
+	RunMigrations(ctx context.Context) error
+	// MigrateDown reverts the given number of most recently applied migrations.
+	MigrateDown(ctx context.Context, steps int) error
+	// MigrationStatus returns every embedded migration with its applied
+	// state. It only reads, without taking the migration lock.
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+
+	// EnsureTable creates or extends the table of an evidence.
+	EnsureTable(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error
+	// EnsureIndexes creates the missing indexes of the table of an evidence.
+	EnsureIndexes(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error
+	// EnsureHistoryTable creates the change history table of an evidence.
+	EnsureHistoryTable(ctx context.Context, table string) error
+
+	UpsertRecords(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error)
+	RecordHistory(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error)
+	DeleteRecords(ctx context.Context, table string, ids []any) (int, error)
+
+	GetSyncState(ctx context.Context, evidence string) (*SyncState, error)
+	SetSyncState(ctx context.Context, evidence string, state SyncState) error
+
+	CleanupOldRecords(ctx context.Context, table string, olderThan time.Time, batchSize int) (int64, error)
+	DropPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error)
+	CountOldRecords(ctx context.Context, table string, olderThan time.Time) (int64, error)
+	CountPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error)
+	LogCleanup(ctx context.Context, evidence string, rowsDeleted int64, oldestKept *time.Time) error
+
+	RecordSyncRun(ctx context.Context, run SyncRun) error
+	CleanupSyncRuns(ctx context.Context, olderThan time.Time) (int64, error)
+
+	AggregateRecords(ctx context.Context, table string, q AggregateQuery) (Aggregate, error)
+	SaveReconciliation(ctx context.Context, results []Reconciliation) error
+
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	GetLease(ctx context.Context, name string) (*Lease, error)
+
+	PauseEvidence(ctx context.Context, evidence string) error
+	ResumeEvidence(ctx context.Context, evidence string) error
+	PausedEvidences(ctx context.Context) (map[string]time.Time, error)
+
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+	// RegisterMetrics exposes the connection pool statistics as metrics.
+	RegisterMetrics() error
+
+	Close()
+}
+
+var (
+	_ Sink = (*Store)(nil)
+	_ Sink = (*SQLiteStore)(nil)
+	_ Sink = (*MySQLStore)(nil)
+)
+
+// errPartitioningUnsupported is returned by sinks other than PostgreSQL
+// for partitioning requests.
+var errPartitioningUnsupported = errors.New("partitioning is only supported on PostgreSQL")
+
+// ErrNotPartitioned is returned when dropping or counting the partitions
+// of a table that exists without partitioning.
+var ErrNotPartitioned = errors.New("table is not partitioned")
+
+// sqliteScheme prefixes database URLs pointing at a SQLite file,
+// e.g. sqlite:///var/lib/adapter/flexibee.db or sqlite://flexibee.db.
+const sqliteScheme = "sqlite://"
+
+// Open connects to the sink selected by the database URL scheme:
+// sqlite:// opens a SQLite file, mysql:// and mariadb:// connect to
+// MySQL/MariaDB, anything else is passed to PostgreSQL. Options are only
+// supported on PostgreSQL.
+func Open(ctx context.Context, databaseURL string, opts Options, logger *slog.Logger) (Sink, error) {
+	switch {
+	case strings.HasPrefix(databaseURL, sqliteScheme):
+		if !opts.empty() {
+			return nil, errRolesUnsupported
+		}
+		return NewSQLiteStore(ctx, strings.TrimPrefix(databaseURL, sqliteScheme), logger)
+	case strings.HasPrefix(databaseURL, "mysql://"), strings.HasPrefix(databaseURL, "mariadb://"):
+		if !opts.empty() {
+			return nil, errRolesUnsupported
+		}
+		return NewMySQLStore(ctx, databaseURL, logger)
+	}
+
+	st, err := NewStore(ctx, databaseURL, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.configure(ctx, opts); err != nil {
+		st.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// EnsureTable implements Sink using the package-level EnsureTable and
+// keeps the table's row-level security policies up to date.
+func (s *Store) EnsureTable(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error {
+	if err := EnsureTable(ctx, s.admin, table, properties, opts, s.logger); err != nil {
+		return err
+	}
+	return s.ensureRowSecurity(ctx, table, table)
+}
+
+// EnsureIndexes implements Sink using the package-level EnsureIndexes.
+func (s *Store) EnsureIndexes(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error {
+	return EnsureIndexes(ctx, s.admin, table, properties, opts, s.logger)
+}
+
+// EnsureHistoryTable implements Sink using the package-level
+// EnsureHistoryTable. The history table gets the policies of its table.
+func (s *Store) EnsureHistoryTable(ctx context.Context, table string) error {
+	if err := EnsureHistoryTable(ctx, s.admin, table, s.logger); err != nil {
+		return err
+	}
+	return s.ensureRowSecurity(ctx, HistoryTable(table), table)
+}
@@ -0,0 +1,826 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrationFS embed.FS
+
+// sqliteTimeLayout is how timestamps are stored in SQLite: UTC text that
+// sorts chronologically and is understood by SQLite's date functions.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+// FlexibeeTypeToSQLite maps a Flexibee property type to a SQLite column type.
+func FlexibeeTypeToSQLite(prop flexibee.Property) string {
+	switch prop.Type {
+	case "integer":
+		return "INTEGER"
+	case "numeric":
+		return "NUMERIC"
+	case "date":
+		return "DATE"
+	case "datetime":
+		return "TIMESTAMP"
+	case "logic":
+		return "BOOLEAN"
+	default:
+		return "TEXT"
+	}
+}
+
+// SQLiteStore writes synced Flexibee data to a single SQLite file. It has
+// the same table layout as Store, with raw_data kept as JSON text.
+type SQLiteStore struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path.
+func NewSQLiteStore(ctx context.Context, path string, logger *slog.Logger) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, errors.New("sqlite database path is empty")
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY
+	// between the engine's concurrent evidence syncs.
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+
+	return &SQLiteStore{db: db, logger: logger}, nil
+}
+
+// DB returns the underlying database handle.
+func (s *SQLiteStore) DB() *sql.DB {
+	return s.db
+}
+
+// RunMigrations applies all pending embedded SQLite migrations in version order.
+func (s *SQLiteStore) RunMigrations(ctx context.Context) error {
+	migrations, err := loadMigrations(sqliteMigrationFS, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.loadApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(migrations, applied)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				m.Version, m.Name, m.Checksum, formatSQLiteTime(time.Now()),
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("apply migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		s.logger.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+
+	s.logger.Info("migrations applied successfully", "applied", len(pending), "total", len(migrations))
+	return nil
+}
+
+// MigrateDown reverts the given number of most recently applied migrations.
+func (s *SQLiteStore) MigrateDown(ctx context.Context, steps int) error {
+	migrations, err := loadMigrations(sqliteMigrationFS, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+
+	applied, err := s.loadApplied(ctx)
+	if err != nil {
+		return err
+	}
+
+	revert, err := revertibleMigrations(migrations, applied, steps)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range revert {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		s.logger.Info("reverted migration", "version", m.Version, "name", m.Name)
+	}
+	return nil
+}
+
+// MigrationStatus returns every embedded migration with its applied state.
+// It only reads; without a schema_migrations table nothing is applied.
+func (s *SQLiteStore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(sqliteMigrationFS, "migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')",
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check schema_migrations: %w", err)
+	}
+	if !exists {
+		return migrationStatuses(migrations, nil), nil
+	}
+
+	applied, err := s.readApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatuses(migrations, applied), nil
+}
+
+// loadApplied reads schema_migrations, creating it first if needed.
+func (s *SQLiteStore) loadApplied(ctx context.Context) (map[int]appliedMigration, error) {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return s.readApplied(ctx)
+}
+
+// readApplied reads schema_migrations.
+func (s *SQLiteStore) readApplied(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		if a.appliedAt, err = parseSQLiteTime(appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// EnsureTable creates a table if it doesn't exist and adds any new columns
+// based on the Flexibee property definitions.
+func (s *SQLiteStore) EnsureTable(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error {
+	if opts.PartitionColumn != "" {
+		return fmt.Errorf("table %s: %w", table, errPartitioningUnsupported)
+	}
+
+	safeTable := sanitizeIdentifier(table)
+	createSQL := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY,
+			raw_data TEXT,
+			synced_at TIMESTAMP NOT NULL
+		)
+	`, safeTable)
+	if _, err := s.db.ExecContext(ctx, createSQL); err != nil {
+		return fmt.Errorf("create table %s: %w", table, err)
+	}
+
+	existing, err := s.existingColumns(ctx, table)
+	if err != nil {
+		return fmt.Errorf("get columns for %s: %w", table, err)
+	}
+
+	for _, prop := range properties {
+		if existing[prop.Name] || prop.Name == "id" {
+			continue
+		}
+
+		colType := FlexibeeTypeToSQLite(prop)
+		alterSQL := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", safeTable, sanitizeIdentifier(prop.Name), colType)
+		if _, err := s.db.ExecContext(ctx, alterSQL); err != nil {
+			s.logger.Warn("failed to add column", "table", table, "column", prop.Name, "error", err)
+			continue
+		}
+		existing[prop.Name] = true
+		s.logger.Debug("added column", "table", table, "column", prop.Name, "type", colType)
+	}
+	return nil
+}
+
+// EnsureIndexes creates the same indexes as on PostgreSQL, except the GIN
+// index on raw_data.
+func (s *SQLiteStore) EnsureIndexes(ctx context.Context, table string, properties []flexibee.Property, opts TableOptions) error {
+	existing, err := s.existingColumns(ctx, table)
+	if err != nil {
+		return fmt.Errorf("get columns for %s: %w", table, err)
+	}
+
+	for _, spec := range planIndexes(table, properties, opts, existing, s.logger) {
+		if spec.Method == "gin" {
+			s.logger.Debug("skipping raw_data index, not supported by SQLite", "table", table)
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, createIndexSQL(table, spec, false)); err != nil {
+			s.logger.Warn("failed to create index", "table", table, "index", spec.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) existingColumns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", strings.ReplaceAll(table, "-", "_"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+// EnsureHistoryTable creates the history table for a synced table. SQLite
+// has no table functions, so unlike PostgreSQL no <table>_as_of is created.
+func (s *SQLiteStore) EnsureHistoryTable(ctx context.Context, table string) error {
+	history := HistoryTable(table)
+	safeHistory := sanitizeIdentifier(history)
+
+	statements := []string{
+		fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				version_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+				id           INTEGER NOT NULL,
+				content_hash TEXT NOT NULL,
+				raw_data     TEXT,
+				valid_from   TIMESTAMP NOT NULL,
+				valid_to     TIMESTAMP
+			)
+		`, safeHistory),
+		fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (id) WHERE valid_to IS NULL",
+			sanitizeIdentifier(history+"_current_idx"), safeHistory),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (id, valid_from)",
+			sanitizeIdentifier(history+"_id_valid_from_idx"), safeHistory),
+	}
+
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("ensure history table %s: %w", history, err)
+		}
+	}
+
+	s.logger.Debug("ensured history table", "table", table, "history", history)
+	return nil
+}
+
+// UpsertRecords inserts or updates records in the given table within one
+// transaction. Returns the number of records upserted.
+func (s *SQLiteStore) UpsertRecords(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	safeTable := sanitizeIdentifier(table)
+	safePK := sanitizeIdentifier(primaryKey)
+	syncedAt := formatSQLiteTime(time.Now())
+	count := 0
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, record := range records {
+			rawJSON, err := json.Marshal(record)
+			if err != nil {
+				s.logger.Warn("failed to marshal record", "error", err)
+				continue
+			}
+
+			id, ok := record[primaryKey]
+			if !ok {
+				s.logger.Warn("record missing primary key", "key", primaryKey)
+				continue
+			}
+
+			cols := []string{safePK, sanitizeIdentifier("raw_data"), sanitizeIdentifier("synced_at")}
+			args := []any{sqliteValue(id), string(rawJSON), syncedAt}
+			for k, v := range record {
+				if k == primaryKey {
+					continue
+				}
+				cols = append(cols, sanitizeIdentifier(k))
+				args = append(args, sqliteValue(v))
+			}
+
+			updates := make([]string, 0, len(cols)-1)
+			for _, col := range cols[1:] {
+				updates = append(updates, fmt.Sprintf("%s = excluded.%s", col, col))
+			}
+
+			query := fmt.Sprintf(
+				"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+				safeTable,
+				strings.Join(cols, ", "),
+				strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "),
+				safePK,
+				strings.Join(updates, ", "),
+			)
+
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				s.logger.Warn("failed to upsert record", "table", table, "id", id, "error", err)
+				continue
+			}
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("upsert records into %s: %w", table, err)
+	}
+
+	return count, nil
+}
+
+// RecordHistory appends a new version to the history table for every record
+// whose content differs from its current version, closing the previous one.
+// Returns the number of new versions written.
+func (s *SQLiteStore) RecordHistory(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	safeHistory := sanitizeIdentifier(HistoryTable(table))
+	closeSQL := fmt.Sprintf(
+		"UPDATE %s SET valid_to = ?3 WHERE id = ?1 AND valid_to IS NULL AND content_hash <> ?2",
+		safeHistory,
+	)
+	insertSQL := fmt.Sprintf(`
+		INSERT INTO %[1]s (id, content_hash, raw_data, valid_from)
+		SELECT ?1, ?2, ?4, ?3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %[1]s WHERE id = ?1 AND valid_to IS NULL AND content_hash = ?2
+		)
+	`, safeHistory)
+
+	count := 0
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, record := range records {
+			id, ok := record[primaryKey]
+			if !ok {
+				continue
+			}
+
+			rawJSON, hash, err := contentHash(record)
+			if err != nil {
+				s.logger.Warn("failed to marshal record for history", "table", table, "id", id, "error", err)
+				continue
+			}
+
+			args := []any{sqliteValue(id), hash, formatSQLiteTime(versionTime(record)), string(rawJSON)}
+			if _, err := tx.ExecContext(ctx, closeSQL, args[:3]...); err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx, insertSQL, args...)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			count += int(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("record history for %s: %w", table, err)
+	}
+
+	return count, nil
+}
+
+// DeleteRecords removes records by their primary key values.
+func (s *SQLiteStore) DeleteRecords(ctx context.Context, table string, ids []any) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = sqliteValue(id)
+	}
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE \"id\" IN (%s)",
+		sanitizeIdentifier(table),
+		strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "),
+	)
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete records from %s: %w", table, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete records from %s: %w", table, err)
+	}
+
+	return int(n), nil
+}
+
+// GetSyncState returns the sync state for an evidence type.
+func (s *SQLiteStore) GetSyncState(ctx context.Context, evidence string) (*SyncState, error) {
+	var state SyncState
+	var lastUpdate sql.NullString
+	var lastSync string
+	var cursorID sql.NullInt64
+	var cursorFilter, cursorStartedAt sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT evidence, last_update, last_sync, row_count, status, COALESCE(error_msg, ''),
+			cursor_id, cursor_filter, cursor_started_at
+		FROM sync_state WHERE evidence = ?`,
+		evidence,
+	).Scan(&state.Evidence, &lastUpdate, &lastSync, &state.RowCount, &state.Status, &state.ErrorMsg,
+		&cursorID, &cursorFilter, &cursorStartedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sync state for %s: %w", evidence, err)
+	}
+
+	if state.LastSync, err = parseSQLiteTime(lastSync); err != nil {
+		return nil, fmt.Errorf("get sync state for %s: %w", evidence, err)
+	}
+	if lastUpdate.Valid {
+		t, err := parseSQLiteTime(lastUpdate.String)
+		if err != nil {
+			return nil, fmt.Errorf("get sync state for %s: %w", evidence, err)
+		}
+		state.LastUpdate = &t
+	}
+	if cursorStartedAt.Valid {
+		t, err := parseSQLiteTime(cursorStartedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("get sync state for %s: %w", evidence, err)
+		}
+		state.Cursor = scannedCursor(cursorID, cursorFilter, &t)
+	}
+
+	return &state, nil
+}
+
+// SetSyncState creates or updates the sync state for an evidence type.
+func (s *SQLiteStore) SetSyncState(ctx context.Context, evidence string, state SyncState) error {
+	cursorID, cursorFilter, cursorStartedAt := cursorValues(state.Cursor)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sync_state (evidence, last_update, last_sync, row_count, status, error_msg,
+			cursor_id, cursor_filter, cursor_started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (evidence) DO UPDATE SET
+			last_update = excluded.last_update, last_sync = excluded.last_sync, row_count = excluded.row_count,
+			status = excluded.status, error_msg = excluded.error_msg,
+			cursor_id = excluded.cursor_id, cursor_filter = excluded.cursor_filter,
+			cursor_started_at = excluded.cursor_started_at
+	`, evidence, sqliteTimePtr(state.LastUpdate), formatSQLiteTime(state.LastSync), state.RowCount, state.Status, state.ErrorMsg,
+		cursorID, cursorFilter, sqliteTimePtr(cursorStartedAt))
+	if err != nil {
+		return fmt.Errorf("set sync state for %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// CleanupOldRecords deletes records older than the given time in batches.
+// Returns total number of deleted rows.
+func (s *SQLiteStore) CleanupOldRecords(ctx context.Context, table string, olderThan time.Time, batchSize int) (int64, error) {
+	safeTable := sanitizeIdentifier(table)
+	query := fmt.Sprintf(
+		`DELETE FROM %s WHERE rowid IN (
+			SELECT rowid FROM %s WHERE "synced_at" < ? LIMIT ?
+		)`,
+		safeTable, safeTable,
+	)
+	cutoff := formatSQLiteTime(olderThan)
+	var totalDeleted int64
+
+	for {
+		res, err := s.db.ExecContext(ctx, query, cutoff, batchSize)
+		if err != nil {
+			return totalDeleted, fmt.Errorf("cleanup %s: %w", table, err)
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return totalDeleted, fmt.Errorf("cleanup %s: %w", table, err)
+		}
+		totalDeleted += deleted
+
+		if deleted < int64(batchSize) {
+			break
+		}
+	}
+
+	return totalDeleted, nil
+}
+
+// CountOldRecords returns the number of records CleanupOldRecords would
+// delete, without deleting anything.
+func (s *SQLiteStore) CountOldRecords(ctx context.Context, table string, olderThan time.Time) (int64, error) {
+	var count int64
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE "synced_at" < ?`, sanitizeIdentifier(table))
+	if err := s.db.QueryRowContext(ctx, query, formatSQLiteTime(olderThan)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count old records of %s: %w", table, err)
+	}
+	return count, nil
+}
+
+// CountPartitionsBefore always fails: SQLite tables are never partitioned.
+func (s *SQLiteStore) CountPartitionsBefore(_ context.Context, table string, _ time.Time) (int64, error) {
+	return 0, fmt.Errorf("table %s: %w", table, errPartitioningUnsupported)
+}
+
+// DropPartitionsBefore always fails: SQLite tables are never partitioned.
+func (s *SQLiteStore) DropPartitionsBefore(_ context.Context, table string, _ time.Time) (int64, error) {
+	return 0, fmt.Errorf("table %s: %w", table, errPartitioningUnsupported)
+}
+
+// LogCleanup records a cleanup operation.
+func (s *SQLiteStore) LogCleanup(ctx context.Context, evidence string, rowsDeleted int64, oldestKept *time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO cleanup_log (evidence, rows_deleted, oldest_kept) VALUES (?, ?, ?)",
+		evidence, rowsDeleted, sqliteTimePtr(oldestKept),
+	)
+	if err != nil {
+		return fmt.Errorf("log cleanup for %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// Ping checks that the database is reachable.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() {
+	_ = s.db.Close()
+}
+
+func (s *SQLiteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteValue converts a decoded JSON value to a value SQLite can store:
+// booleans become 0/1, nested objects and arrays are stored as JSON text.
+func sqliteValue(v any) any {
+	switch v := v.(type) {
+	case nil, string, float64, int, int64, json.Number:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case time.Time:
+		return formatSQLiteTime(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(raw)
+	}
+}
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func sqliteTimePtr(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatSQLiteTime(*t)
+}
+
+// parseSQLiteTime parses a stored timestamp. RFC 3339 is accepted as well
+// since the driver may hand TIMESTAMP columns back already converted.
+func parseSQLiteTime(v string) (time.Time, error) {
+	for _, layout := range []string{sqliteTimeLayout, time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", v)
+}
+
+// RecordSyncRun stores the outcome of a sync.
+func (s *SQLiteStore) RecordSyncRun(ctx context.Context, run SyncRun) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sync_runs (evidence, started_at, finished_at, duration_ms, mode, status,
+			pages, records_fetched, records_upserted, records_failed, bytes, error_msg)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.Evidence, formatSQLiteTime(run.StartedAt), formatSQLiteTime(run.FinishedAt), run.Duration().Milliseconds(),
+		run.Mode, run.Status, run.Pages, run.Fetched, run.Upserted, run.Failed, run.Bytes, nullString(run.ErrorMsg))
+	if err != nil {
+		return fmt.Errorf("record sync run of %s: %w", run.Evidence, err)
+	}
+	return nil
+}
+
+// CleanupSyncRuns deletes the runs started before olderThan.
+func (s *SQLiteStore) CleanupSyncRuns(ctx context.Context, olderThan time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sync_runs WHERE started_at < ?", formatSQLiteTime(olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("clean up sync runs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// PauseEvidence pauses the syncing of evidence. Pausing a paused evidence
+// keeps its original pause time.
+func (s *SQLiteStore) PauseEvidence(ctx context.Context, evidence string) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO paused_evidence (evidence, paused_at) VALUES (?, ?) ON CONFLICT (evidence) DO NOTHING",
+		evidence, formatSQLiteTime(time.Now()))
+	if err != nil {
+		return fmt.Errorf("pause %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// ResumeEvidence resumes the syncing of a paused evidence.
+func (s *SQLiteStore) ResumeEvidence(ctx context.Context, evidence string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM paused_evidence WHERE evidence = ?", evidence); err != nil {
+		return fmt.Errorf("resume %s: %w", evidence, err)
+	}
+	return nil
+}
+
+// PausedEvidences returns the paused evidences with the time they were
+// paused.
+func (s *SQLiteStore) PausedEvidences(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT evidence, paused_at FROM paused_evidence")
+	if err != nil {
+		return nil, fmt.Errorf("list paused evidences: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	paused := make(map[string]time.Time)
+	for rows.Next() {
+		var evidence, at string
+		if err := rows.Scan(&evidence, &at); err != nil {
+			return nil, fmt.Errorf("list paused evidences: %w", err)
+		}
+		if paused[evidence], err = parseSQLiteTime(at); err != nil {
+			return nil, fmt.Errorf("list paused evidences: %w", err)
+		}
+	}
+	return paused, rows.Err()
+}
+
+// RegisterMetrics exposes the statistics of the connection pool.
+func (s *SQLiteStore) RegisterMetrics() error {
+	return metrics.RegisterDB(s.db, "sqlite")
+}
+
+// AcquireLease acquires the lease for holder, or renews it when holder
+// already has it, for ttl. It reports false when another holder has an
+// unexpired lease.
+func (s *SQLiteStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO leader_lease (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			acquired_at = CASE WHEN leader_lease.holder = excluded.holder
+				THEN leader_lease.acquired_at ELSE excluded.acquired_at END,
+			holder = excluded.holder, renewed_at = excluded.renewed_at, expires_at = excluded.expires_at
+		WHERE leader_lease.holder = excluded.holder OR leader_lease.expires_at < excluded.renewed_at
+	`, name, holder, formatSQLiteTime(now), formatSQLiteTime(now), formatSQLiteTime(now.Add(ttl)))
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	return n > 0, nil
+}
+
+// ReleaseLease gives up the lease if holder has it.
+func (s *SQLiteStore) ReleaseLease(ctx context.Context, name, holder string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM leader_lease WHERE name = ? AND holder = ?", name, holder); err != nil {
+		return fmt.Errorf("release lease %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetLease returns the lease, or nil if it is not held.
+func (s *SQLiteStore) GetLease(ctx context.Context, name string) (*Lease, error) {
+	lease := Lease{Name: name}
+	var acquiredAt, renewedAt, expiresAt string
+	err := s.db.QueryRowContext(ctx,
+		"SELECT holder, acquired_at, renewed_at, expires_at FROM leader_lease WHERE name = ?",
+		name,
+	).Scan(&lease.Holder, &acquiredAt, &renewedAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get lease %s: %w", name, err)
+	}
+
+	for _, t := range []struct {
+		dst *time.Time
+		src string
+	}{{&lease.AcquiredAt, acquiredAt}, {&lease.RenewedAt, renewedAt}, {&lease.ExpiresAt, expiresAt}} {
+		if *t.dst, err = parseSQLiteTime(t.src); err != nil {
+			return nil, fmt.Errorf("get lease %s: %w", name, err)
+		}
+	}
+	return &lease, nil
+}
+
+// AggregateRecords counts and totals the records of table selected by q.
+func (s *SQLiteStore) AggregateRecords(ctx context.Context, table string, q AggregateQuery) (Aggregate, error) {
+	query := aggregateSQL(table, q, sanitizeIdentifier, "?", "?")
+	agg, err := scanAggregate(q, s.db.QueryRowContext(ctx, query, aggregateArgs(q)...).Scan)
+	if err != nil {
+		return Aggregate{}, fmt.Errorf("aggregate %s: %w", table, err)
+	}
+	return agg, nil
+}
+
+// SaveReconciliation stores the results of a reconciliation run, replacing
+// earlier results of the same evidence, period and metric.
+func (s *SQLiteStore) SaveReconciliation(ctx context.Context, results []Reconciliation) error {
+	for _, r := range results {
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO reconciliation (evidence, period, metric, flexibee_value, synced_value, difference, drift, checked_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (evidence, period, metric) DO UPDATE SET
+				flexibee_value = excluded.flexibee_value, synced_value = excluded.synced_value,
+				difference = excluded.difference, drift = excluded.drift, checked_at = excluded.checked_at
+		`, r.Evidence, r.Period, r.Metric, r.Flexibee, r.Synced, r.Synced-r.Flexibee, r.Drift, formatSQLiteTime(r.CheckedAt))
+		if err != nil {
+			return fmt.Errorf("save reconciliation of %s: %w", r.Evidence, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+
+	ctx := context.Background()
+	st, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "test.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(st.Close)
+
+	require.NoError(t, st.RunMigrations(ctx))
+	return st
+}
+
+func TestOpen_SQLite(t *testing.T) {
+	t.Parallel()
+
+	sink, err := Open(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "open.db"), Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	assert.IsType(t, &SQLiteStore{}, sink)
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	// Running again is a no-op.
+	require.NoError(t, st.RunMigrations(ctx))
+
+	statuses, err := st.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, s := range statuses {
+		assert.True(t, s.Applied, "migration %03d", s.Version)
+		assert.False(t, s.Modified)
+	}
+
+	require.NoError(t, st.MigrateDown(ctx, len(statuses)))
+	statuses, err = st.MigrationStatus(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.False(t, s.Applied)
+	}
+}
+
+func TestSQLiteStore_MigrationStatus_NotMigrated(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "test.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(st.Close)
+
+	statuses, err := st.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, statuses)
+	for _, s := range statuses {
+		assert.False(t, s.Applied, "migration %03d", s.Version)
+	}
+
+	// Reading the status does not create schema_migrations.
+	var tables int
+	require.NoError(t, st.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'",
+	).Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestSQLiteStore_EnsureTableAndUpsert(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	props := []flexibee.Property{
+		{Name: "id", Type: "integer"},
+		{Name: "kod", Type: "string"},
+		{Name: "sumCelkem", Type: "numeric"},
+		{Name: "datVyst", Type: "date"},
+		{Name: "firma", Type: "relation"},
+	}
+	require.NoError(t, st.EnsureTable(ctx, "flexibee_faktura_vydana", props, TableOptions{IndexRawData: true}))
+	require.NoError(t, st.EnsureIndexes(ctx, "flexibee_faktura_vydana", props, TableOptions{IndexRawData: true}))
+	// Ensuring again must not fail on existing columns and indexes.
+	require.NoError(t, st.EnsureTable(ctx, "flexibee_faktura_vydana", props, TableOptions{}))
+	require.NoError(t, st.EnsureIndexes(ctx, "flexibee_faktura_vydana", props, TableOptions{}))
+
+	var indexes int
+	require.NoError(t, st.DB().QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'flexibee_faktura_vydana'",
+	).Scan(&indexes))
+	assert.Equal(t, 3, indexes) // datVyst, firma, synced_at
+
+	records := []map[string]any{
+		{"id": float64(1), "kod": "FV-1", "sumCelkem": "100.50", "datVyst": "2024-01-15", "firma": "code:ACME"},
+		{"id": float64(2), "kod": "FV-2", "sumCelkem": "20"},
+	}
+	n, err := st.UpsertRecords(ctx, "flexibee_faktura_vydana", records, "id")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	records[0]["kod"] = "FV-1b"
+	n, err = st.UpsertRecords(ctx, "flexibee_faktura_vydana", records[:1], "id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var kod string
+	var total float64
+	var raw string
+	require.NoError(t, st.DB().QueryRowContext(ctx,
+		`SELECT "kod", "sumCelkem", raw_data FROM flexibee_faktura_vydana WHERE id = 1`,
+	).Scan(&kod, &total, &raw))
+	assert.Equal(t, "FV-1b", kod)
+	assert.InDelta(t, 100.5, total, 0.001)
+	assert.Contains(t, raw, `"kod":"FV-1b"`)
+
+	deleted, err := st.DeleteRecords(ctx, "flexibee_faktura_vydana", []any{float64(2)})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestSQLiteStore_Partitioning(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	err := st.EnsureTable(ctx, "flexibee_banka", nil, TableOptions{PartitionColumn: "datVyst"})
+	assert.ErrorIs(t, err, errPartitioningUnsupported)
+
+	_, err = st.DropPartitionsBefore(ctx, "flexibee_banka", time.Now())
+	assert.ErrorIs(t, err, errPartitioningUnsupported)
+
+	_, err = st.CountPartitionsBefore(ctx, "flexibee_banka", time.Now())
+	assert.ErrorIs(t, err, errPartitioningUnsupported)
+}
+
+func TestSQLiteStore_SyncState(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	state, err := st.GetSyncState(ctx, "adresar")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	lastUpdate := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	lastSync := time.Date(2024, 3, 1, 12, 35, 0, 0, time.UTC)
+	require.NoError(t, st.SetSyncState(ctx, "adresar", SyncState{
+		LastUpdate: &lastUpdate,
+		LastSync:   lastSync,
+		RowCount:   42,
+		Status:     "ok",
+	}))
+
+	state, err = st.GetSyncState(ctx, "adresar")
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	require.NotNil(t, state.LastUpdate)
+	assert.True(t, lastUpdate.Equal(*state.LastUpdate))
+	assert.True(t, lastSync.Equal(state.LastSync))
+	assert.Equal(t, int64(42), state.RowCount)
+	assert.Equal(t, "ok", state.Status)
+
+	require.NoError(t, st.SetSyncState(ctx, "adresar", SyncState{LastSync: lastSync, Status: "error", ErrorMsg: "boom"}))
+	state, err = st.GetSyncState(ctx, "adresar")
+	require.NoError(t, err)
+	assert.Nil(t, state.LastUpdate)
+	assert.Equal(t, "boom", state.ErrorMsg)
+}
+
+func TestSQLiteStore_SyncCursor(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	started := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	cursor := &SyncCursor{LastID: 1234, Filter: "lastUpdate > '2024-01-01T00:00:00Z'", StartedAt: started}
+	require.NoError(t, st.SetSyncState(ctx, "banka", SyncState{LastSync: started, Status: "running", Cursor: cursor}))
+
+	state, err := st.GetSyncState(ctx, "banka")
+	require.NoError(t, err)
+	assert.Equal(t, cursor, state.Cursor)
+
+	require.NoError(t, st.SetSyncState(ctx, "banka", SyncState{LastUpdate: &started, LastSync: started, Status: "ok"}))
+	state, err = st.GetSyncState(ctx, "banka")
+	require.NoError(t, err)
+	assert.Nil(t, state.Cursor)
+}
+
+func TestSQLiteStore_CleanupOldRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+	require.NoError(t, st.EnsureTable(ctx, "flexibee_banka", nil, TableOptions{}))
+
+	records := make([]map[string]any, 5)
+	for i := range records {
+		records[i] = map[string]any{"id": float64(i + 1)}
+	}
+	_, err := st.UpsertRecords(ctx, "flexibee_banka", records, "id")
+	require.NoError(t, err)
+
+	count, err := st.CountOldRecords(ctx, "flexibee_banka", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	deleted, err := st.CleanupOldRecords(ctx, "flexibee_banka", time.Now().Add(-time.Hour), 2)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = st.CleanupOldRecords(ctx, "flexibee_banka", time.Now().Add(time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+
+	require.NoError(t, st.LogCleanup(ctx, "banka", deleted, nil))
+}
+
+func TestSQLiteStore_Reconciliation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+	props := []flexibee.Property{
+		{Name: "datVyst", Type: "date"},
+		{Name: "sumCelkem", Type: "numeric"},
+	}
+	require.NoError(t, st.EnsureTable(ctx, "flexibee_faktura_vydana", props, TableOptions{}))
+
+	records := []map[string]any{
+		{"id": float64(1), "datVyst": "2024-01-15+01:00", "sumCelkem": 100.5},
+		{"id": float64(2), "datVyst": "2024-01-31+01:00", "sumCelkem": 200.0},
+		{"id": float64(3), "datVyst": "2024-02-01+01:00", "sumCelkem": 50.0},
+	}
+	_, err := st.UpsertRecords(ctx, "flexibee_faktura_vydana", records, "id")
+	require.NoError(t, err)
+
+	agg, err := st.AggregateRecords(ctx, "flexibee_faktura_vydana", AggregateQuery{
+		DateColumn: "datVyst",
+		From:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:         time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		SumColumns: []string{"sumCelkem"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Aggregate{Count: 2, Sums: map[string]float64{"sumCelkem": 300.5}}, agg)
+
+	agg, err = st.AggregateRecords(ctx, "flexibee_faktura_vydana", AggregateQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), agg.Count)
+
+	result := Reconciliation{
+		Evidence: "faktura-vydana", Period: "2024-01", Metric: "count",
+		Flexibee: 3, Synced: 2, Drift: true, CheckedAt: time.Now(),
+	}
+	require.NoError(t, st.SaveReconciliation(ctx, []Reconciliation{result}))
+	result.Synced, result.Drift = 3, false
+	require.NoError(t, st.SaveReconciliation(ctx, []Reconciliation{result}))
+
+	var count int
+	var difference float64
+	var drift bool
+	require.NoError(t, st.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), MAX(difference), MAX(drift) FROM reconciliation").Scan(&count, &difference, &drift))
+	assert.Equal(t, 1, count)
+	assert.Zero(t, difference)
+	assert.False(t, drift)
+}
+
+func TestSQLiteStore_SyncRuns(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	started := time.Now().Add(-time.Minute)
+	old := SyncRun{Evidence: "banka", StartedAt: started.AddDate(0, 0, -100), FinishedAt: started.AddDate(0, 0, -100).Add(time.Second),
+		Mode: "full", Status: "error", ErrorMsg: "timeout"}
+	recent := SyncRun{Evidence: "banka", StartedAt: started, FinishedAt: started.Add(1500 * time.Millisecond),
+		Mode: "incremental", Status: "ok", Pages: 2, Fetched: 150, Upserted: 149, Failed: 1, Bytes: 4096}
+	require.NoError(t, st.RecordSyncRun(ctx, old))
+	require.NoError(t, st.RecordSyncRun(ctx, recent))
+
+	var durationMS, upserted int64
+	var errMsg sql.NullString
+	require.NoError(t, st.DB().QueryRowContext(ctx,
+		"SELECT duration_ms, records_upserted, error_msg FROM sync_runs WHERE mode = 'incremental'",
+	).Scan(&durationMS, &upserted, &errMsg))
+	assert.Equal(t, int64(1500), durationMS)
+	assert.Equal(t, int64(149), upserted)
+	assert.False(t, errMsg.Valid)
+
+	deleted, err := st.CleanupSyncRuns(ctx, time.Now().AddDate(0, 0, -90))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestSQLiteStore_Lease(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	lease, err := st.GetLease(ctx, "sync")
+	require.NoError(t, err)
+	assert.Nil(t, lease)
+
+	ok, err := st.AcquireLease(ctx, "sync", "a", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = st.AcquireLease(ctx, "sync", "b", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, ok, "lease is held by a")
+
+	ok, err = st.AcquireLease(ctx, "sync", "a", -time.Second)
+	require.NoError(t, err)
+	assert.True(t, ok, "holder renews its lease")
+
+	ok, err = st.AcquireLease(ctx, "sync", "b", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ok, "expired lease is taken over")
+
+	lease, err = st.GetLease(ctx, "sync")
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	assert.Equal(t, "b", lease.Holder)
+	assert.True(t, lease.ExpiresAt.After(time.Now()))
+
+	require.NoError(t, st.ReleaseLease(ctx, "sync", "a"))
+	lease, err = st.GetLease(ctx, "sync")
+	require.NoError(t, err)
+	assert.NotNil(t, lease, "only the holder releases the lease")
+
+	require.NoError(t, st.ReleaseLease(ctx, "sync", "b"))
+	lease, err = st.GetLease(ctx, "sync")
+	require.NoError(t, err)
+	assert.Nil(t, lease)
+}
+
+func TestSQLiteStore_RecordHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+	require.NoError(t, st.EnsureHistoryTable(ctx, "flexibee_adresar"))
+
+	record := map[string]any{"id": float64(7), "nazev": "ACME", "lastUpdate": "2024-01-01T10:00:00.000+01:00"}
+	n, err := st.RecordHistory(ctx, "flexibee_adresar", []map[string]any{record}, "id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Unchanged content writes no new version.
+	n, err = st.RecordHistory(ctx, "flexibee_adresar", []map[string]any{record}, "id")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	changed := map[string]any{"id": float64(7), "nazev": "ACME s.r.o.", "lastUpdate": "2024-02-01T10:00:00.000+01:00"}
+	n, err = st.RecordHistory(ctx, "flexibee_adresar", []map[string]any{changed}, "id")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	var versions, current int
+	require.NoError(t, st.DB().QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(*) FILTER (WHERE valid_to IS NULL) FROM flexibee_adresar_history WHERE id = 7",
+	).Scan(&versions, &current))
+	assert.Equal(t, 2, versions)
+	assert.Equal(t, 1, current)
+}
+
+func TestSQLiteValue(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, sqliteValue(nil))
+	assert.Equal(t, "abc", sqliteValue("abc"))
+	assert.Equal(t, 1, sqliteValue(true))
+	assert.Equal(t, 0, sqliteValue(false))
+	assert.Equal(t, `{"a":1}`, sqliteValue(map[string]any{"a": 1}))
+	assert.Equal(t, "2024-01-02 03:04:05.000", sqliteValue(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func TestSQLiteStore_PauseEvidence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	st := newTestSQLiteStore(t)
+
+	require.NoError(t, st.PauseEvidence(ctx, "banka"))
+	paused, err := st.PausedEvidences(ctx)
+	require.NoError(t, err)
+	require.Contains(t, paused, "banka")
+	pausedAt := paused["banka"]
+
+	require.NoError(t, st.PauseEvidence(ctx, "banka"), "pausing twice is allowed")
+	paused, err = st.PausedEvidences(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, pausedAt, paused["banka"], "the original pause time is kept")
+
+	require.NoError(t, st.ResumeEvidence(ctx, "banka"))
+	paused, err = st.PausedEvidences(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, paused)
+}
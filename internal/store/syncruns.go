@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SyncRun is the outcome of one sync of an evidence, kept in sync_runs.
+type SyncRun struct {
+	Evidence   string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Mode       string // "full" or "incremental"
+	Status     string // "ok" or "error"
+	Pages      int
+	Fetched    int64 // records fetched from Flexibee
+	Upserted   int64
+	Failed     int64 // fetched records that were not stored
+	Bytes      int64 // size of the fetched pages
+	ErrorMsg   string
+}
+
+// Duration is how long the run took.
+func (r SyncRun) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// nullString returns nil for an empty string, stored as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// RecordSyncRun stores the outcome of a sync.
+func (s *Store) RecordSyncRun(ctx context.Context, run SyncRun) error {
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO sync_runs (evidence, started_at, finished_at, duration_ms, mode, status,
+			pages, records_fetched, records_upserted, records_failed, bytes, error_msg)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, run.Evidence, run.StartedAt, run.FinishedAt, run.Duration().Milliseconds(), run.Mode, run.Status,
+		run.Pages, run.Fetched, run.Upserted, run.Failed, run.Bytes, nullString(run.ErrorMsg))
+	if err != nil {
+		return fmt.Errorf("record sync run of %s: %w", run.Evidence, err)
+	}
+	return nil
+}
+
+// CleanupSyncRuns deletes the runs started before olderThan.
+func (s *Store) CleanupSyncRuns(ctx context.Context, olderThan time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, "DELETE FROM sync_runs WHERE started_at < $1", olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("clean up sync runs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,16 +2,23 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"time"
 
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
 )
 
 // CleanupConfig controls data retention behavior.
 type CleanupConfig struct {
 	RetentionDays int
 	BatchSize     int
+	// RunRetentionDays is how long sync runs are kept (0 = forever).
+	RunRetentionDays int
+	// DryRun only counts and logs the records a cleanup would remove.
+	DryRun bool
 }
 
 // Cleaner handles data retention cleanup.
@@ -33,7 +40,12 @@ func NewCleaner(st SyncStore, reg *registry.Registry, cfg CleanupConfig, logger
 }
 
 // Run performs cleanup for all registered evidence types.
+// Partitioned tables are cleaned up by dropping whole monthly partitions
+// older than the retention period instead of deleting rows. Sync runs have
+// a retention period of their own.
 func (c *Cleaner) Run(ctx context.Context) error {
+	c.cleanupSyncRuns(ctx)
+
 	if c.config.RetentionDays <= 0 {
 		c.logger.Info("cleanup disabled (retention_days=0)")
 		return nil
@@ -48,12 +60,18 @@ func (c *Cleaner) Run(ctx context.Context) error {
 			continue
 		}
 
-		deleted, err := c.store.CleanupOldRecords(ctx, ev.Table, cutoff, c.config.BatchSize)
+		deleted, err := c.cleanupTable(ctx, ev, cutoff)
 		if err != nil {
 			c.logger.Error("cleanup failed", "evidence", ev.Slug, "error", err)
 			continue
 		}
 
+		if c.config.DryRun {
+			c.logger.Info("would clean up records", "evidence", ev.Slug, "records", deleted)
+			continue
+		}
+
+		metrics.CleanupDeleted.WithLabelValues(ev.Slug).Add(float64(deleted))
 		if deleted > 0 {
 			c.logger.Info("cleaned up records", "evidence", ev.Slug, "deleted", deleted)
 			if err := c.store.LogCleanup(ctx, ev.Slug, deleted, &cutoff); err != nil {
@@ -65,3 +83,44 @@ func (c *Cleaner) Run(ctx context.Context) error {
 	c.logger.Info("cleanup complete")
 	return nil
 }
+
+// cleanupTable removes (or in a dry run counts) the records of ev older than
+// cutoff. A table configured for partitioning that exists without it has
+// its rows deleted instead.
+func (c *Cleaner) cleanupTable(ctx context.Context, ev registry.Evidence, cutoff time.Time) (int64, error) {
+	if ev.Partitioned {
+		var deleted int64
+		var err error
+		if c.config.DryRun {
+			deleted, err = c.store.CountPartitionsBefore(ctx, ev.Table, cutoff)
+		} else {
+			deleted, err = c.store.DropPartitionsBefore(ctx, ev.Table, cutoff)
+		}
+		if !errors.Is(err, store.ErrNotPartitioned) {
+			return deleted, err
+		}
+		c.logger.Warn("table is not partitioned, deleting old rows instead", "evidence", ev.Slug, "table", ev.Table)
+	}
+
+	if c.config.DryRun {
+		return c.store.CountOldRecords(ctx, ev.Table, cutoff)
+	}
+	return c.store.CleanupOldRecords(ctx, ev.Table, cutoff, c.config.BatchSize)
+}
+
+// cleanupSyncRuns deletes sync runs older than their retention period.
+func (c *Cleaner) cleanupSyncRuns(ctx context.Context) {
+	if c.config.RunRetentionDays <= 0 || c.config.DryRun {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -c.config.RunRetentionDays)
+	deleted, err := c.store.CleanupSyncRuns(ctx, cutoff)
+	if err != nil {
+		c.logger.Error("sync run cleanup failed", "error", err)
+		return
+	}
+	if deleted > 0 {
+		c.logger.Info("cleaned up sync runs", "deleted", deleted, "cutoff", cutoff)
+	}
+}
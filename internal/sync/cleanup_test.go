@@ -52,3 +52,69 @@ func TestCleaner_RunsCleanup(t *testing.T) {
 	err := c.Run(context.Background())
 	assert.NoError(t, err)
 }
+
+func TestCleaner_DropsPartitions(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "part", Table: "flexibee_part", PrimaryKey: "id", DateColumn: "datVyst", Partitioned: true})
+	reg.Register(registry.Evidence{Slug: "trans", Table: "flexibee_trans", PrimaryKey: "id"})
+
+	ms := newMockSyncStore()
+	c := NewCleaner(ms, reg, CleanupConfig{RetentionDays: 30, BatchSize: 100}, discardLogger)
+
+	err := c.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ms.dropped["flexibee_part"])
+	assert.Zero(t, ms.dropped["flexibee_trans"])
+}
+
+func TestCleaner_FallsBackForUnpartitionedTable(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "part", Table: "flexibee_part", PrimaryKey: "id", DateColumn: "datVyst", Partitioned: true})
+
+	ms := newMockSyncStore()
+	ms.unpartitioned["flexibee_part"] = true
+	c := NewCleaner(ms, reg, CleanupConfig{RetentionDays: 30, BatchSize: 100}, discardLogger)
+
+	err := c.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, ms.dropped["flexibee_part"])
+	assert.Equal(t, 1, ms.cleaned["flexibee_part"])
+}
+
+func TestCleaner_DryRun(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "part", Table: "flexibee_part", PrimaryKey: "id", DateColumn: "datVyst", Partitioned: true})
+	reg.Register(registry.Evidence{Slug: "trans", Table: "flexibee_trans", PrimaryKey: "id"})
+
+	ms := newMockSyncStore()
+	ms.cleanups["flexibee_trans"] = 10
+	c := NewCleaner(ms, reg, CleanupConfig{RetentionDays: 30, BatchSize: 100, DryRun: true}, discardLogger)
+
+	err := c.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ms.counted["flexibee_part"])
+	assert.Equal(t, 1, ms.counted["flexibee_trans"])
+	assert.Zero(t, ms.dropped["flexibee_part"])
+}
+
+func TestCleaner_CleansUpSyncRuns(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	ms := newMockSyncStore()
+
+	// Sync runs have their own retention, also without data retention.
+	c := NewCleaner(ms, reg, CleanupConfig{RetentionDays: 0, RunRetentionDays: 90}, discardLogger)
+	assert.NoError(t, c.Run(context.Background()))
+	assert.Equal(t, 1, ms.runsCleaned)
+
+	c = NewCleaner(ms, reg, CleanupConfig{RunRetentionDays: 90, DryRun: true}, discardLogger)
+	assert.NoError(t, c.Run(context.Background()))
+	assert.Equal(t, 1, ms.runsCleaned)
+}
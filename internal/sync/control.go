@@ -0,0 +1,306 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	gosync "sync"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+var (
+	// ErrUnknownEvidence is returned for evidences not in the registry.
+	ErrUnknownEvidence = errors.New("unknown evidence")
+	// ErrPaused is returned for manual syncs of a paused evidence.
+	ErrPaused = errors.New("evidence is paused")
+	// ErrNotRunning is returned for runs triggered while the engine is not
+	// syncing, e.g. on a replica standing by for leadership.
+	ErrNotRunning = errors.New("engine is not running")
+	// ErrBusy is returned for a cleanup triggered while one is running.
+	ErrBusy = errors.New("already running")
+)
+
+// Run triggers of an Activity.
+const (
+	TriggerScheduled = "scheduled"
+	TriggerManual    = "manual"
+)
+
+// cleanupActivity names the cleanup among the running activities.
+const cleanupActivity = "cleanup"
+
+// Activity is a sync or cleanup the engine is running.
+type Activity struct {
+	Name    string // evidence slug, or "cleanup"
+	Trigger string // TriggerScheduled or TriggerManual
+	Since   time.Time
+}
+
+// EvidenceStatus is an evidence with its sync state as seen by the engine.
+type EvidenceStatus struct {
+	Evidence registry.Evidence
+	State    *store.SyncState // nil if never synced
+	PausedAt *time.Time       // nil unless paused
+	Running  *Activity        // nil unless being synced
+}
+
+// Evidences returns the status of every registered evidence.
+func (e *Engine) Evidences(ctx context.Context) ([]EvidenceStatus, error) {
+	paused, err := e.syncStore.PausedEvidences(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	running := make(map[string]Activity, len(e.running))
+	for name, a := range e.running {
+		running[name] = a
+	}
+	e.mu.Unlock()
+
+	var statuses []EvidenceStatus
+	for _, ev := range e.registry.All() {
+		state, err := e.syncStore.GetSyncState(ctx, ev.Slug)
+		if err != nil {
+			return nil, fmt.Errorf("get sync state of %s: %w", ev.Slug, err)
+		}
+		status := EvidenceStatus{Evidence: ev, State: state}
+		if at, ok := paused[ev.Slug]; ok {
+			status.PausedAt = &at
+		}
+		if a, ok := running[ev.Slug]; ok {
+			status.Running = &a
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Running returns the syncs and cleanups being run, oldest first.
+func (e *Engine) Running() []Activity {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	activities := make([]Activity, 0, len(e.running))
+	for _, a := range e.running {
+		activities = append(activities, a)
+	}
+	slices.SortFunc(activities, func(a, b Activity) int { return a.Since.Compare(b.Since) })
+	return activities
+}
+
+// Pause stops scheduled and periodic syncs of an evidence, from its next
+// sync on, until it is resumed. The pause is stored in the database and
+// applies to all replicas.
+func (e *Engine) Pause(ctx context.Context, slug string) error {
+	if _, ok := e.registry.Get(slug); !ok {
+		return fmt.Errorf("%w %s", ErrUnknownEvidence, slug)
+	}
+	if err := e.syncStore.PauseEvidence(ctx, slug); err != nil {
+		return err
+	}
+	e.logger.Info("evidence paused", "evidence", slug)
+	return nil
+}
+
+// Resume resumes the syncing of a paused evidence.
+func (e *Engine) Resume(ctx context.Context, slug string) error {
+	if _, ok := e.registry.Get(slug); !ok {
+		return fmt.Errorf("%w %s", ErrUnknownEvidence, slug)
+	}
+	if err := e.syncStore.ResumeEvidence(ctx, slug); err != nil {
+		return err
+	}
+	e.logger.Info("evidence resumed", "evidence", slug)
+	return nil
+}
+
+// TriggerSync starts syncing an evidence in the background, once a sync
+// of it that is already running has finished.
+func (e *Engine) TriggerSync(ctx context.Context, slug string) error {
+	return e.trigger(ctx, slug, func(ctx context.Context, ev registry.Evidence) error {
+		return e.syncManual(ctx, ev, false, nil)
+	})
+}
+
+// TriggerResync starts syncing an evidence again in the background: its
+// sync state is reset to records changed since since, or to all records
+// if since is nil.
+func (e *Engine) TriggerResync(ctx context.Context, slug string, since *time.Time) error {
+	return e.trigger(ctx, slug, func(ctx context.Context, ev registry.Evidence) error {
+		return e.syncManual(ctx, ev, true, since)
+	})
+}
+
+// TriggerSyncAll starts a sync pass over all evidences in the background.
+// Paused evidences are skipped.
+func (e *Engine) TriggerSyncAll() error {
+	return e.background("sync pass", func(ctx context.Context) error {
+		_, err := e.runPass(ctx, all, func(ctx context.Context, ev registry.Evidence) error {
+			return e.syncManual(ctx, ev, false, nil)
+		})
+		return err
+	})
+}
+
+// TriggerResyncAll starts resyncing all evidences in the background, as
+// TriggerResync does for one. Paused evidences are skipped.
+func (e *Engine) TriggerResyncAll(since *time.Time) error {
+	return e.background("resync pass", func(ctx context.Context) error {
+		_, err := e.runPass(ctx, all, func(ctx context.Context, ev registry.Evidence) error {
+			return e.syncManual(ctx, ev, true, since)
+		})
+		return err
+	})
+}
+
+// TriggerCleanup starts the retention cleanup in the background unless it
+// is already running.
+func (e *Engine) TriggerCleanup() error {
+	if !e.cleanupMu.TryLock() {
+		return fmt.Errorf("cleanup %w", ErrBusy)
+	}
+	err := e.background("cleanup", func(ctx context.Context) error {
+		defer e.cleanupMu.Unlock()
+		defer e.track(cleanupActivity, TriggerManual)()
+		return e.cleaner.Run(ctx)
+	})
+	if err != nil {
+		e.cleanupMu.Unlock()
+	}
+	return err
+}
+
+// trigger runs sync for slug in the background if it may be synced
+// manually.
+func (e *Engine) trigger(ctx context.Context, slug string, sync func(context.Context, registry.Evidence) error) error {
+	ev, err := e.manualEvidence(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return e.background("sync of "+slug, func(ctx context.Context) error { return sync(ctx, ev) })
+}
+
+// manualEvidence returns the evidence slug if it is known and not paused.
+func (e *Engine) manualEvidence(ctx context.Context, slug string) (registry.Evidence, error) {
+	ev, ok := e.registry.Get(slug)
+	if !ok {
+		return registry.Evidence{}, fmt.Errorf("%w %s", ErrUnknownEvidence, slug)
+	}
+	paused, err := e.syncStore.PausedEvidences(ctx)
+	if err != nil {
+		return registry.Evidence{}, err
+	}
+	if _, ok := paused[slug]; ok {
+		return registry.Evidence{}, fmt.Errorf("%s: %w", slug, ErrPaused)
+	}
+	return ev, nil
+}
+
+// background runs fn with the context of the running engine, which waits
+// for it on shutdown.
+func (e *Engine) background(name string, fn func(context.Context) error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runCtx == nil {
+		return ErrNotRunning
+	}
+
+	ctx := e.runCtx
+	e.manual.Go(func() {
+		e.logger.Info("manual run started", "run", name)
+		if err := fn(ctx); err != nil {
+			e.logger.Error("manual run failed", "run", name, "error", err)
+			return
+		}
+		e.logger.Info("manual run complete", "run", name)
+	})
+	return nil
+}
+
+// syncExclusive syncs ev unless a sync of ev is already running, in which
+// case it is skipped with errAlreadyRunning.
+func (e *Engine) syncExclusive(ctx context.Context, ev registry.Evidence) error {
+	lock := e.lock(ev.Slug)
+	if !lock.TryLock() {
+		e.logger.InfoContext(ctx, "sync already running, skipping", "evidence", ev.Slug)
+		return errAlreadyRunning
+	}
+	defer lock.Unlock()
+	defer e.track(ev.Slug, TriggerScheduled)()
+
+	return syncEvidence(ctx, e.client, heartbeatStore{e.syncStore, e.beat}, ev, e.batchSize, e.logger)
+}
+
+// syncManual syncs ev after a sync of ev that is already running. With
+// reset, the sync state is first reset to records changed since since.
+func (e *Engine) syncManual(ctx context.Context, ev registry.Evidence, reset bool, since *time.Time) error {
+	lock := e.lock(ev.Slug)
+	lock.Lock()
+	defer lock.Unlock()
+	defer e.track(ev.Slug, TriggerManual)()
+
+	if reset {
+		state := store.SyncState{Evidence: ev.Slug, LastUpdate: since, LastSync: time.Now(), Status: "pending"}
+		if err := e.syncStore.SetSyncState(ctx, ev.Slug, state); err != nil {
+			return fmt.Errorf("reset sync state of %s: %w", ev.Slug, err)
+		}
+		e.logger.InfoContext(ctx, "resyncing evidence", "evidence", ev.Slug, "since", since)
+	}
+	return syncEvidence(ctx, e.client, heartbeatStore{e.syncStore, e.beat}, ev, e.batchSize, e.logger)
+}
+
+// runCleanup runs the retention cleanup unless a manually triggered one
+// is running.
+func (e *Engine) runCleanup(ctx context.Context) error {
+	if !e.cleanupMu.TryLock() {
+		e.logger.Info("cleanup already running, skipping")
+		return nil
+	}
+	defer e.cleanupMu.Unlock()
+	defer e.track(cleanupActivity, TriggerScheduled)()
+	return e.cleaner.Run(ctx)
+}
+
+// lock returns the lock serialising the syncs of an evidence.
+func (e *Engine) lock(slug string) *gosync.Mutex {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lock, ok := e.locks[slug]
+	if !ok {
+		lock = &gosync.Mutex{}
+		e.locks[slug] = lock
+	}
+	return lock
+}
+
+// track records name as running until the returned function is called.
+func (e *Engine) track(name, trigger string) func() {
+	e.mu.Lock()
+	e.running[name] = Activity{Name: name, Trigger: trigger, Since: time.Now()}
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		delete(e.running, name)
+		e.mu.Unlock()
+		e.beat()
+	}
+}
+
+// paused returns the paused evidences. A failure to read them is logged
+// and treated as none being paused.
+func (e *Engine) paused(ctx context.Context) map[string]time.Time {
+	paused, err := e.syncStore.PausedEvidences(ctx)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "failed to read paused evidences", "error", err)
+	}
+	return paused
+}
+
+// all includes every evidence in a sync pass.
+func all(registry.Evidence) bool { return true }
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func newControlTestEngine(t *testing.T, ms *mockSyncStore) *Engine {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"1","banka":[{"id":1}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "banka", Table: "flexibee_banka", PrimaryKey: "id"})
+
+	return &Engine{
+		client:    flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger),
+		syncStore: ms,
+		registry:  reg,
+		logger:    discardLogger,
+		batchSize: 100,
+		running:   make(map[string]Activity),
+		locks:     make(map[string]*gosync.Mutex),
+	}
+}
+
+func TestEngine_TriggerSync(t *testing.T) {
+	t.Parallel()
+
+	ms := newMockSyncStore()
+	e := newControlTestEngine(t, ms)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, e.TriggerSync(ctx, "faktura"), ErrUnknownEvidence)
+	assert.ErrorIs(t, e.TriggerSync(ctx, "banka"), ErrNotRunning, "standby replicas do not sync")
+
+	e.runCtx = ctx
+	require.NoError(t, e.Pause(ctx, "banka"))
+	assert.ErrorIs(t, e.TriggerSync(ctx, "banka"), ErrPaused)
+	require.NoError(t, e.Resume(ctx, "banka"))
+
+	// A manual sync waits for the running sync of the evidence.
+	lock := e.lock("banka")
+	lock.Lock()
+	require.NoError(t, e.TriggerSync(ctx, "banka"))
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, ms.runs, "manual sync started while another one was running")
+
+	lock.Unlock()
+	e.manual.Wait()
+	require.Len(t, ms.runs, 1)
+	assert.Equal(t, "ok", ms.states["banka"].Status)
+	assert.Empty(t, e.Running())
+}
+
+func TestEngine_RunOnce_SkipsPaused(t *testing.T) {
+	t.Parallel()
+
+	ms := newMockSyncStore()
+	e := newControlTestEngine(t, ms)
+	require.NoError(t, e.Pause(context.Background(), "banka"))
+
+	result, err := e.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, ms.runs)
+	assert.Equal(t, []EvidenceResult{{Evidence: "banka", Outcome: OutcomeSkipped, Reason: "paused"}}, result.Evidences)
+}
@@ -2,29 +2,59 @@ package sync
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/tracing"
 )
 
-// Engine orchestrates syncing Flexibee data to PostgreSQL.
+var tracer = otel.Tracer("github.com/anaryk/metabase-flexibee-adapter/internal/sync")
+
+// Engine orchestrates syncing Flexibee data to a store.Sink.
 type Engine struct {
-	client    *flexibee.Client
-	store     *store.Store
-	syncStore SyncStore
-	registry  *registry.Registry
-	cleaner   *Cleaner
-	logger    *slog.Logger
-
-	syncInterval    time.Duration
-	cleanupInterval time.Duration
-	batchSize       int
-	concurrency     int
+	client     *flexibee.Client
+	store      store.Sink
+	syncStore  SyncStore
+	registry   *registry.Registry
+	cleaner    *Cleaner
+	reconciler *Reconciler
+	elector    *LeaderElector
+	stages     []Stage
+	logger     *slog.Logger
+
+	syncInterval      time.Duration
+	cleanupInterval   time.Duration
+	reconcileInterval time.Duration
+	batchSize         int
+	concurrency       int
+	monthsAhead       int
+	indexRawData      bool
+	location          *time.Location
+
+	mu      gosync.Mutex
+	schemas []TableSchema            // tables ensured by Setup
+	running map[string]Activity      // syncs and cleanups being run, by name
+	locks   map[string]*gosync.Mutex // serialise the syncs of each evidence
+	runCtx  context.Context          // context of run while running, for manual runs
+	manual  gosync.WaitGroup         // manual runs
+
+	cleanupMu gosync.Mutex // held while the cleanup runs
+	stagesMu  gosync.Mutex // held while the stages run
+
+	active    atomic.Bool  // run is running
+	heartbeat atomic.Int64 // Unix nanoseconds of the last beat (see Alive)
 }
 
 // EngineConfig holds the engine's configuration values.
@@ -33,98 +63,386 @@ type EngineConfig struct {
 	CleanupInterval time.Duration
 	BatchSize       int
 	Concurrency     int
+
+	// PartitionMonthsAhead is how many future monthly partitions are
+	// created for partitioned evidence tables.
+	PartitionMonthsAhead int
+
+	// IndexRawData adds GIN indexes on raw_data of every synced table.
+	IndexRawData bool
+
+	// Stages run after the tables are ensured and after every sync pass.
+	Stages []Stage
+
+	// Location is the time zone of cron schedules (see ParseSchedule).
+	Location *time.Location
+
+	// Reconciler, if set, runs every ReconcileInterval.
+	Reconciler        *Reconciler
+	ReconcileInterval time.Duration
+
+	// Elector, if set, makes the engine sync only while it is the leader
+	// among the adapter replicas.
+	Elector *LeaderElector
 }
 
 // NewEngine creates a new sync engine.
-func NewEngine(client *flexibee.Client, st *store.Store, reg *registry.Registry, cleaner *Cleaner, cfg EngineConfig, logger *slog.Logger) *Engine {
+func NewEngine(client *flexibee.Client, st store.Sink, reg *registry.Registry, cleaner *Cleaner, cfg EngineConfig, logger *slog.Logger) *Engine {
 	return &Engine{
-		client:          client,
-		store:           st,
-		syncStore:       st,
-		registry:        reg,
-		cleaner:         cleaner,
-		logger:          logger,
-		syncInterval:    cfg.SyncInterval,
-		cleanupInterval: cfg.CleanupInterval,
-		batchSize:       cfg.BatchSize,
-		concurrency:     cfg.Concurrency,
+		client:            client,
+		store:             st,
+		syncStore:         st,
+		registry:          reg,
+		cleaner:           cleaner,
+		reconciler:        cfg.Reconciler,
+		elector:           cfg.Elector,
+		stages:            cfg.Stages,
+		logger:            logger,
+		syncInterval:      cfg.SyncInterval,
+		cleanupInterval:   cfg.CleanupInterval,
+		reconcileInterval: cfg.ReconcileInterval,
+		batchSize:         cfg.BatchSize,
+		concurrency:       cfg.Concurrency,
+		monthsAhead:       cfg.PartitionMonthsAhead,
+		indexRawData:      cfg.IndexRawData,
+		location:          cfg.Location,
+		running:           make(map[string]Activity),
+		locks:             make(map[string]*gosync.Mutex),
 	}
 }
 
 // Start runs the sync engine until the context is cancelled.
-// It runs migrations, ensures tables, performs an initial sync,
-// then runs periodic sync and cleanup.
+// It sets the engine up (see Setup), performs an initial sync,
+// then runs periodic sync and cleanup. Evidences with their own schedule
+// are synced on it; all others every sync interval. With leader election,
+// all of this happens only while the engine is the leader.
 func (e *Engine) Start(ctx context.Context) error {
-	// Run migrations
-	e.logger.Info("running migrations")
-	if err := e.store.RunMigrations(ctx); err != nil {
+	schedules, err := e.schedules()
+	if err != nil {
 		return err
 	}
 
-	// Ensure tables exist for all registered evidence types
-	e.logger.Info("ensuring tables for registered evidence types")
-	if err := e.ensureTables(ctx); err != nil {
+	if e.elector != nil {
+		err = e.elector.Run(ctx, func(ctx context.Context) error { return e.run(ctx, schedules) })
+	} else {
+		err = e.run(ctx, schedules)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.logger.Info("engine shutting down")
+	return nil
+}
+
+// run syncs until ctx is cancelled.
+func (e *Engine) run(ctx context.Context, schedules map[string]Schedule) error {
+	e.beat()
+	e.active.Store(true)
+	defer e.active.Store(false)
+
+	if err := e.Setup(ctx); err != nil {
 		return err
 	}
 
+	// Accept manual runs until shutdown, then wait for them.
+	defer e.manual.Wait()
+	e.mu.Lock()
+	e.runCtx = ctx
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.runCtx = nil
+		e.mu.Unlock()
+	}()
+
 	// Run initial sync
 	e.logger.Info("running initial sync")
-	if err := e.RunOnce(ctx); err != nil {
-		e.logger.Error("initial sync failed", "error", err)
+	if _, err := e.RunOnce(ctx); err != nil {
+		e.logger.Error("initial sync incomplete", "error", err)
 		// Don't return - continue with periodic sync
 	}
 
-	// Start periodic sync and cleanup
+	// Start scheduled, periodic sync and cleanup
+	var wg gosync.WaitGroup
+	defer wg.Wait()
+	wg.Go(func() {
+		if err := e.EnsureIndexes(ctx); err != nil {
+			e.logger.Error("index build stopped", "error", err)
+		}
+	})
+	for _, ev := range e.registry.All() {
+		if schedule, ok := schedules[ev.Slug]; ok {
+			wg.Go(func() { e.runSchedule(ctx, ev, schedule) })
+		}
+	}
+	unscheduled := func(ev registry.Evidence) bool { return schedules[ev.Slug] == nil }
+
 	syncTicker := time.NewTicker(e.syncInterval)
 	defer syncTicker.Stop()
 
 	cleanupTicker := time.NewTicker(e.cleanupInterval)
 	defer cleanupTicker.Stop()
 
-	e.logger.Info("engine started", "sync_interval", e.syncInterval, "cleanup_interval", e.cleanupInterval)
+	// Reconciliation is optional; a nil channel never fires.
+	var reconcileC <-chan time.Time
+	if e.reconciler != nil && e.reconcileInterval > 0 {
+		reconcileTicker := time.NewTicker(e.reconcileInterval)
+		defer reconcileTicker.Stop()
+		reconcileC = reconcileTicker.C
+	}
+
+	heartbeatTicker := time.NewTicker(heartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	e.logger.Info("engine started", "sync_interval", e.syncInterval, "cleanup_interval", e.cleanupInterval,
+		"reconcile_interval", e.reconcileInterval, "scheduled_evidences", len(schedules))
 
 	for {
+		e.beat()
 		select {
 		case <-ctx.Done():
-			e.logger.Info("engine shutting down")
 			return nil
+		case <-heartbeatTicker.C:
 		case <-syncTicker.C:
 			e.logger.Info("starting periodic sync")
-			if err := e.RunOnce(ctx); err != nil {
-				e.logger.Error("periodic sync failed", "error", err)
+			if _, err := e.runPass(ctx, unscheduled, e.syncExclusive); err != nil {
+				e.logger.Error("periodic sync incomplete", "error", err)
 			}
 		case <-cleanupTicker.C:
 			e.logger.Info("starting periodic cleanup")
-			if err := e.cleaner.Run(ctx); err != nil {
+			if err := e.runCleanup(ctx); err != nil {
 				e.logger.Error("periodic cleanup failed", "error", err)
 			}
+		case <-reconcileC:
+			e.logger.Info("starting reconciliation")
+			if err := e.reconciler.Run(ctx); err != nil {
+				e.logger.Error("reconciliation failed", "error", err)
+			}
 		}
 	}
 }
 
-// RunOnce performs a single sync pass across all registered evidence types
-// with bounded concurrency.
-func (e *Engine) RunOnce(ctx context.Context) error {
-	evidences := e.registry.All()
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(e.concurrency)
-
-	for _, ev := range evidences {
-		g.Go(func() error {
-			return syncEvidence(ctx, e.client, e.syncStore, ev, e.batchSize, e.logger)
-		})
+// Setup runs migrations, ensures tables for all registered evidence types
+// and prepares the stages. It must be called before RunOnce or
+// SyncEvidence; Start calls it itself.
+func (e *Engine) Setup(ctx context.Context) error {
+	e.logger.Info("running migrations")
+	if err := e.store.RunMigrations(ctx); err != nil {
+		return err
 	}
 
-	if err := g.Wait(); err != nil {
+	e.logger.Info("ensuring tables for registered evidence types")
+	schemas, err := e.ensureTables(ctx)
+	if err != nil {
 		return err
 	}
+	e.mu.Lock()
+	e.schemas = schemas
+	e.mu.Unlock()
 
-	e.logger.Info("sync pass complete", "evidence_count", len(evidences))
+	for _, stage := range e.stages {
+		if obs, ok := stage.(SchemaObserver); ok {
+			obs.ObserveSchema(schemas)
+		}
+	}
+	for _, stage := range e.stages {
+		if err := stage.Prepare(ctx); err != nil {
+			e.logger.Error("stage preparation failed", "stage", stage.Name(), "error", err)
+		}
+	}
+	return nil
+}
+
+// EnsureIndexes creates the missing indexes of the tables ensured by Setup.
+// Building indexes on large tables takes a while, so Start does it in the
+// background after the initial sync rather than in Setup. Failures of
+// single indexes are logged; it only fails when ctx is done.
+func (e *Engine) EnsureIndexes(ctx context.Context) error {
+	e.mu.Lock()
+	schemas := e.schemas
+	e.mu.Unlock()
+
+	e.logger.InfoContext(ctx, "ensuring indexes", "tables", len(schemas))
+	for _, schema := range schemas {
+		ev := schema.Evidence
+		if err := e.store.EnsureIndexes(ctx, ev.Table, schema.Properties, e.tableOptions(ev)); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			e.logger.ErrorContext(ctx, "failed to ensure indexes", "evidence", ev.Slug, "error", err)
+		}
+	}
+	e.logger.InfoContext(ctx, "indexes ensured")
 	return nil
 }
 
-func (e *Engine) ensureTables(ctx context.Context) error {
+// ResyncEvidence resets the sync state of a single evidence to records
+// changed since since, or to all records if since is nil, and syncs it
+// like SyncEvidence.
+func (e *Engine) ResyncEvidence(ctx context.Context, slug string, since *time.Time) error {
+	ev, err := e.manualEvidence(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return e.syncManual(ctx, ev, true, since)
+}
+
+// SyncEvidence syncs a single evidence, ignoring its dependencies and
+// without running the stages. A sync of it that is already running is
+// waited for; paused evidences are not synced.
+func (e *Engine) SyncEvidence(ctx context.Context, slug string) error {
+	ev, err := e.manualEvidence(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return e.syncManual(ctx, ev, false, nil)
+}
+
+// RunOnce performs a single sync pass across all registered evidence types.
+// Evidences are synced in dependency waves (see registry.Registry.Waves),
+// each wave with bounded concurrency, so that master data and declared
+// dependencies are complete before the evidences referencing them start.
+//
+// A failing evidence does not stop the others, except those declaring a
+// dependency on it, which are skipped. The result lists the outcome of every evidence;
+// the error wraps ErrPassFailed if any failed.
+func (e *Engine) RunOnce(ctx context.Context) (*PassResult, error) {
+	return e.runPass(ctx, all, e.syncExclusive)
+}
+
+// runPass syncs the evidences selected by include with sync in dependency
+// waves and then runs the stages. Paused evidences are skipped, and so are
+// evidences whose declared dependency failed (or was itself skipped for
+// that reason); the others, including those waiting only for master data
+// to be synced first, are synced regardless of failures.
+// The error is the result's Err unless the pass could not run at all.
+func (e *Engine) runPass(ctx context.Context, include func(registry.Evidence) bool, sync func(context.Context, registry.Evidence) error) (_ *PassResult, err error) {
+	ctx, span := tracer.Start(ctx, "sync.pass")
+	defer func() { tracing.End(span, err) }()
+
+	waves, err := e.registry.Waves()
+	if err != nil {
+		return nil, err
+	}
+
+	paused := e.paused(ctx)
+	result := &PassResult{StartedAt: time.Now()}
+
+	// Evidences that failed or were skipped for a failed dependency, which
+	// block the evidences declaring them in DependsOn.
+	blocked := make(map[string]bool)
+	for i, wave := range waves {
+		var g errgroup.Group
+		g.SetLimit(e.concurrency)
+
+		// Allocated up front so that the slots the goroutines write stay
+		// in place; each evidence writes only its own.
+		results := make([]EvidenceResult, 0, len(wave))
+		for _, ev := range wave {
+			if !include(ev) {
+				continue
+			}
+			if _, ok := paused[ev.Slug]; ok {
+				e.logger.InfoContext(ctx, "evidence paused, skipping", "evidence", ev.Slug)
+				results = append(results, EvidenceResult{Evidence: ev.Slug, Outcome: OutcomeSkipped, Reason: "paused"})
+				continue
+			}
+			if slices.ContainsFunc(ev.DependsOn, func(dep string) bool { return blocked[dep] }) {
+				e.logger.WarnContext(ctx, "dependency failed, skipping", "evidence", ev.Slug)
+				results = append(results, EvidenceResult{Evidence: ev.Slug, Outcome: OutcomeSkipped, Reason: "dependency failed"})
+				blocked[ev.Slug] = true
+				continue
+			}
+			results = append(results, EvidenceResult{Evidence: ev.Slug})
+			res := &results[len(results)-1]
+			g.Go(func() error {
+				start := time.Now()
+				err := sync(ctx, ev)
+				res.Duration = time.Since(start)
+				switch {
+				case errors.Is(err, errAlreadyRunning):
+					res.Outcome, res.Reason = OutcomeSkipped, "already running"
+				case err != nil:
+					res.Outcome, res.Err = OutcomeFailed, err
+					e.logger.ErrorContext(ctx, "evidence sync failed", "evidence", ev.Slug, "error", err)
+				default:
+					res.Outcome = OutcomeOK
+				}
+				return nil
+			})
+		}
+
+		_ = g.Wait()
+		for _, res := range results {
+			if res.Outcome == OutcomeFailed {
+				blocked[res.Evidence] = true
+			}
+		}
+		e.logger.DebugContext(ctx, "sync wave complete", "wave", i+1, "evidence_count", len(results))
+		result.Evidences = append(result.Evidences, results...)
+	}
+	result.FinishedAt = time.Now()
+
+	ok, failed, skipped := result.Count(OutcomeOK), result.Count(OutcomeFailed), result.Count(OutcomeSkipped)
+	span.SetAttributes(
+		attribute.String("sync.status", result.Status()),
+		attribute.Int("sync.evidences_ok", ok),
+		attribute.Int("sync.evidences_failed", failed),
+		attribute.Int("sync.evidences_skipped", skipped),
+		attribute.Int("sync.waves", len(waves)),
+	)
+	level := slog.LevelInfo
+	if failed > 0 {
+		level = slog.LevelWarn
+	}
+	e.logger.Log(ctx, level, "sync pass complete", "status", result.Status(),
+		"ok", ok, "failed", failed, "skipped", skipped, "waves", len(waves),
+		"duration", result.FinishedAt.Sub(result.StartedAt))
+
+	e.runStages(ctx)
+	return result, result.Err()
+}
+
+// runStages runs every stage after a sync pass. Stage failures are logged
+// and do not fail the sync itself. Stages are not safe for concurrent use,
+// so a manual pass finishing alongside a scheduled one waits for its stages.
+func (e *Engine) runStages(ctx context.Context) {
+	e.stagesMu.Lock()
+	defer e.stagesMu.Unlock()
+
+	for _, stage := range e.stages {
+		if err := stage.Run(ctx); err != nil {
+			e.logger.ErrorContext(ctx, "stage failed", "stage", stage.Name(), "error", err)
+		}
+	}
+}
+
+// schedules parses the schedules of evidences with their own schedule.
+func (e *Engine) schedules() (map[string]Schedule, error) {
+	loc := e.location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	schedules := make(map[string]Schedule)
+	for _, ev := range e.registry.All() {
+		if ev.Schedule == "" {
+			continue
+		}
+		schedule, err := ParseSchedule(ev.Schedule, loc)
+		if err != nil {
+			return nil, fmt.Errorf("schedule of %s: %w", ev.Slug, err)
+		}
+		schedules[ev.Slug] = schedule
+	}
+	return schedules, nil
+}
+
+func (e *Engine) ensureTables(ctx context.Context) ([]TableSchema, error) {
+	var schemas []TableSchema
 	for _, ev := range e.registry.All() {
+		e.beat()
 		props, err := e.client.FetchEvidenceProperties(ctx, ev.Slug)
 		if err != nil {
 			e.logger.Warn("failed to fetch properties, creating table with base columns only",
@@ -132,9 +450,30 @@ func (e *Engine) ensureTables(ctx context.Context) error {
 			props = nil
 		}
 
-		if err := store.EnsureTable(ctx, e.store.Pool(), ev.Table, props, e.logger); err != nil {
-			return err
+		if err := e.store.EnsureTable(ctx, ev.Table, props, e.tableOptions(ev)); err != nil {
+			return nil, err
+		}
+
+		if ev.History {
+			if err := e.store.EnsureHistoryTable(ctx, ev.Table); err != nil {
+				return nil, err
+			}
 		}
+
+		schemas = append(schemas, TableSchema{Evidence: ev, Properties: props})
 	}
-	return nil
+	return schemas, nil
+}
+
+// tableOptions returns the options of the table of ev.
+func (e *Engine) tableOptions(ev registry.Evidence) store.TableOptions {
+	opts := store.TableOptions{
+		PartitionMonthsAhead: e.monthsAhead,
+		IndexRawData:         e.indexRawData,
+		Indexes:              ev.Indexes,
+	}
+	if ev.Partitioned {
+		opts.PartitionColumn = ev.DateColumn
+	}
+	return opts
 }
@@ -1,10 +1,20 @@
 package sync
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	gosync "sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
 )
 
 func TestEngineConfig_Defaults(t *testing.T) {
@@ -22,3 +32,208 @@ func TestEngineConfig_Defaults(t *testing.T) {
 	assert.Equal(t, 100, cfg.BatchSize)
 	assert.Equal(t, 4, cfg.Concurrency)
 }
+
+func TestEngine_RunOnce_SyncsInWaves(t *testing.T) {
+	t.Parallel()
+
+	var mu gosync.Mutex
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, strings.TrimSuffix(path.Base(r.URL.Path), ".json"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "faktura-vydana-polozka", Table: "t_fvp", PrimaryKey: "id", DependsOn: []string{"faktura-vydana"}})
+	reg.Register(registry.Evidence{Slug: "faktura-vydana", Table: "t_fv", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "adresar", Table: "t_a", PrimaryKey: "id", IsMasterData: true})
+
+	e := &Engine{
+		client:      flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger),
+		syncStore:   newMockSyncStore(),
+		registry:    reg,
+		logger:      discardLogger,
+		batchSize:   100,
+		concurrency: 1,
+		running:     make(map[string]Activity),
+		locks:       make(map[string]*gosync.Mutex),
+	}
+	_, err := e.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"adresar", "faktura-vydana", "faktura-vydana-polozka"}, fetched)
+}
+
+func TestEngine_RunOnce_ContinuesOnError(t *testing.T) {
+	t.Parallel()
+
+	// faktura-vydana is rejected; the evidences syncing alongside it are
+	// still synced, while those depending on it are skipped.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := strings.TrimSuffix(path.Base(r.URL.Path), ".json")
+		if slug == "faktura-vydana" {
+			http.Error(w, "bad filter", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"1","` + slug + `":[{"id":1}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "faktura-vydana", Table: "t_fv", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "banka", Table: "t_b", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "faktura-vydana-polozka", Table: "t_fvp", PrimaryKey: "id", DependsOn: []string{"faktura-vydana"}})
+
+	ms := newMockSyncStore()
+	e := &Engine{
+		client:      flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger),
+		syncStore:   ms,
+		registry:    reg,
+		logger:      discardLogger,
+		batchSize:   100,
+		concurrency: 1,
+		running:     make(map[string]Activity),
+		locks:       make(map[string]*gosync.Mutex),
+	}
+	result, err := e.RunOnce(context.Background())
+	require.ErrorIs(t, err, ErrPassFailed)
+	assert.ErrorContains(t, err, "partial, 1 of 3 evidences failed")
+
+	require.NotNil(t, result)
+	assert.Equal(t, PassPartial, result.Status())
+	outcomes := map[string]string{}
+	for _, ev := range result.Evidences {
+		outcomes[ev.Evidence] = ev.Outcome
+	}
+	assert.Equal(t, map[string]string{
+		"faktura-vydana":         OutcomeFailed,
+		"banka":                  OutcomeOK,
+		"faktura-vydana-polozka": OutcomeSkipped,
+	}, outcomes)
+	require.Len(t, result.Failed(), 1)
+	assert.ErrorContains(t, result.Failed()[0].Err, "unexpected status 400")
+	assert.Equal(t, "ok", ms.states["banka"].Status)
+	assert.Nil(t, ms.states["faktura-vydana-polozka"], "dependent evidence was synced")
+}
+
+func TestEngine_RunOnce_SkipsOnlyDeclaredDependents(t *testing.T) {
+	t.Parallel()
+
+	// The failing master data evidence sazba-dph only orders the waves, so
+	// faktura-vydana still syncs; faktura-vydana-polozka declares a
+	// dependency on the failing banka and is skipped, and so is
+	// banka-polozka, which depends on it in turn.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug := strings.TrimSuffix(path.Base(r.URL.Path), ".json")
+		if slug == "sazba-dph" || slug == "banka" {
+			http.Error(w, "bad filter", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"1","` + slug + `":[{"id":1}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "sazba-dph", Table: "t_s", PrimaryKey: "id", IsMasterData: true})
+	reg.Register(registry.Evidence{Slug: "faktura-vydana", Table: "t_fv", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "banka", Table: "t_b", PrimaryKey: "id"})
+	reg.Register(registry.Evidence{Slug: "faktura-vydana-polozka", Table: "t_fvp", PrimaryKey: "id", DependsOn: []string{"banka"}})
+	reg.Register(registry.Evidence{Slug: "banka-polozka", Table: "t_bp", PrimaryKey: "id", DependsOn: []string{"faktura-vydana-polozka"}})
+
+	ms := newMockSyncStore()
+	e := &Engine{
+		client:      flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger),
+		syncStore:   ms,
+		registry:    reg,
+		logger:      discardLogger,
+		batchSize:   100,
+		concurrency: 1,
+		running:     make(map[string]Activity),
+		locks:       make(map[string]*gosync.Mutex),
+	}
+	result, err := e.RunOnce(context.Background())
+	require.ErrorIs(t, err, ErrPassFailed)
+
+	reasons := map[string]string{}
+	for _, ev := range result.Evidences {
+		reasons[ev.Evidence] = ev.Outcome + " " + ev.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"sazba-dph":              OutcomeFailed + " ",
+		"faktura-vydana":         OutcomeOK + " ",
+		"banka":                  OutcomeFailed + " ",
+		"faktura-vydana-polozka": OutcomeSkipped + " dependency failed",
+		"banka-polozka":          OutcomeSkipped + " dependency failed",
+	}, reasons)
+	assert.Equal(t, "ok", ms.states["faktura-vydana"].Status)
+	assert.Nil(t, ms.states["banka-polozka"])
+}
+
+// overlapStage records the most runs of it in progress at once.
+type overlapStage struct {
+	mu      gosync.Mutex
+	running int
+	most    int
+}
+
+func (s *overlapStage) Name() string                  { return "overlap" }
+func (s *overlapStage) Prepare(context.Context) error { return nil }
+
+func (s *overlapStage) Run(context.Context) error {
+	s.mu.Lock()
+	s.running++
+	s.most = max(s.most, s.running)
+	s.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	return nil
+}
+
+func TestEngine_RunStages_Serialised(t *testing.T) {
+	t.Parallel()
+
+	stage := &overlapStage{}
+	e := &Engine{stages: []Stage{stage}, logger: discardLogger}
+
+	var wg gosync.WaitGroup
+	for range 3 {
+		wg.Go(func() { e.runStages(context.Background()) })
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, stage.most)
+}
+
+func TestPassResult_Status(t *testing.T) {
+	t.Parallel()
+
+	ok := EvidenceResult{Outcome: OutcomeOK}
+	failed := EvidenceResult{Evidence: "banka", Outcome: OutcomeFailed, Err: assert.AnError}
+	skipped := EvidenceResult{Outcome: OutcomeSkipped}
+
+	tests := []struct {
+		evidences []EvidenceResult
+		want      string
+	}{
+		{nil, PassOK},
+		{[]EvidenceResult{ok, skipped}, PassOK},
+		{[]EvidenceResult{ok, failed}, PassPartial},
+		{[]EvidenceResult{failed, skipped}, PassFailed},
+	}
+	for _, tt := range tests {
+		r := &PassResult{Evidences: tt.evidences}
+		assert.Equal(t, tt.want, r.Status())
+		assert.Equal(t, tt.want != PassOK, r.Err() != nil)
+	}
+}
@@ -2,18 +2,36 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/tracing"
 )
 
 // syncEvidence performs a single sync pass for one evidence type.
 // It uses incremental sync based on the lastUpdate timestamp.
-func syncEvidence(ctx context.Context, client *flexibee.Client, st SyncStore, ev registry.Evidence, batchSize int, logger *slog.Logger) error {
+//
+// Pages are fetched in id order and a cursor is saved after every page, so
+// a sync interrupted by an error or restart resumes after the last synced
+// id with the filter it started with. Every sync that gets that far is
+// recorded in the sync run history, whether it succeeds or fails.
+func syncEvidence(ctx context.Context, client *flexibee.Client, st SyncStore, ev registry.Evidence, batchSize int, logger *slog.Logger) (err error) {
+	ctx, span := tracer.Start(ctx, "sync.evidence", trace.WithAttributes(
+		attribute.String("sync.evidence", ev.Slug),
+		attribute.String("sync.table", ev.Table),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	logger = logger.With("evidence", ev.Slug, "table", ev.Table)
 
 	// Get current sync state
@@ -22,77 +40,201 @@ func syncEvidence(ctx context.Context, client *flexibee.Client, st SyncStore, ev
 		return fmt.Errorf("get sync state: %w", err)
 	}
 
+	progress := store.SyncState{Evidence: ev.Slug, Status: "running"}
+	if state != nil {
+		progress.LastUpdate = state.LastUpdate
+		progress.RowCount = state.RowCount
+		progress.Cursor = state.Cursor
+	}
+
+	cursor := progress.Cursor
+	switch {
+	case cursor != nil:
+		logger.InfoContext(ctx, "resuming sync", "after_id", cursor.LastID, "started_at", cursor.StartedAt)
+	case progress.LastUpdate != nil:
+		// Incremental sync: only fetch records modified since last sync
+		cursor = &store.SyncCursor{
+			Filter:    fmt.Sprintf("lastUpdate > '%s'", progress.LastUpdate.Format(time.RFC3339)),
+			StartedAt: time.Now(),
+		}
+		logger.InfoContext(ctx, "incremental sync", "since", progress.LastUpdate)
+	default:
+		cursor = &store.SyncCursor{StartedAt: time.Now()}
+		logger.InfoContext(ctx, "full sync (first run)")
+	}
+	progress.Cursor = cursor
+
+	run := store.SyncRun{Evidence: ev.Slug, StartedAt: time.Now(), Mode: syncMode(cursor)}
+	span.SetAttributes(attribute.String("sync.mode", run.Mode))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("sync.pages", run.Pages),
+			attribute.Int64("sync.records_fetched", run.Fetched),
+			attribute.Int64("sync.records_upserted", run.Upserted),
+		)
+		recordSyncRun(ctx, st, run, err, logger)
+	}()
+
 	// Build fetch options
 	opts := flexibee.FetchOptions{
 		Limit:  batchSize,
 		Detail: "full",
-	}
-
-	// Incremental sync: only fetch records modified since last sync
-	if state != nil && state.LastUpdate != nil {
-		opts.Filter = fmt.Sprintf("lastUpdate > '%s'", state.LastUpdate.Format(time.RFC3339))
-		logger.Info("incremental sync", "since", state.LastUpdate)
-	} else {
-		logger.Info("full sync (first run)")
+		Filter: cursorFilter(cursor),
+		Order:  ev.PrimaryKey,
 	}
 
 	// Iterate through all pages
 	it := client.IterateEvidence(ctx, ev.Slug, opts)
-	totalUpserted := 0
 
 	for {
 		records, err := it.Next(ctx)
+		run.Bytes = it.Bytes()
 		if err != nil {
 			// Save error state
-			saveErrorState(ctx, st, ev.Slug, state, err, logger)
+			saveErrorState(ctx, st, progress, err, logger)
 			return fmt.Errorf("fetch page: %w", err)
 		}
 		if records == nil {
 			break
 		}
 
-		upserted, err := st.UpsertRecords(ctx, ev.Table, records, ev.PrimaryKey)
+		run.Pages++
+		run.Fetched += int64(len(records))
+
+		upsertCtx, upsertSpan := tracer.Start(ctx, "store.upsert", trace.WithAttributes(
+			attribute.String("sync.table", ev.Table),
+			attribute.Int("sync.records", len(records)),
+		))
+		upserted, err := st.UpsertRecords(upsertCtx, ev.Table, records, ev.PrimaryKey)
+		tracing.End(upsertSpan, err)
 		if err != nil {
-			saveErrorState(ctx, st, ev.Slug, state, err, logger)
+			run.Failed += int64(len(records))
+			saveErrorState(ctx, st, progress, err, logger)
 			return fmt.Errorf("upsert records: %w", err)
 		}
-		totalUpserted += upserted
-		logger.Debug("upserted batch", "count", upserted)
+		run.Upserted += int64(upserted)
+		run.Failed += int64(len(records) - upserted)
+		logger.DebugContext(ctx, "upserted batch", "count", upserted)
+
+		if ev.History {
+			versions, err := st.RecordHistory(ctx, ev.Table, records, ev.PrimaryKey)
+			if err != nil {
+				saveErrorState(ctx, st, progress, err, logger)
+				return fmt.Errorf("record history: %w", err)
+			}
+			logger.DebugContext(ctx, "recorded history", "versions", versions)
+		}
+
+		// Save the cursor now that the page is committed
+		for _, record := range records {
+			if id, ok := recordID(record[ev.PrimaryKey]); ok && id > cursor.LastID {
+				cursor.LastID = id
+			}
+		}
+		progress.LastSync = time.Now()
+		if err := st.SetSyncState(ctx, ev.Slug, progress); err != nil {
+			return fmt.Errorf("save sync cursor: %w", err)
+		}
 	}
 
-	// Update sync state
-	now := time.Now()
+	// The row count is the size of the table rather than a sum of upserts,
+	// which would count updated records again. It is only recounted when
+	// the sync may have changed it.
+	if run.Upserted > 0 || run.Mode == "full" {
+		agg, err := st.AggregateRecords(ctx, ev.Table, store.AggregateQuery{})
+		if err != nil {
+			logger.WarnContext(ctx, "failed to count rows", "error", err)
+		} else {
+			progress.RowCount = agg.Count
+		}
+	}
+
+	// Update sync state. Records changed while the sync ran are picked up
+	// by the next one, which starts from when this one started.
 	newState := store.SyncState{
 		Evidence:   ev.Slug,
-		LastUpdate: &now,
-		LastSync:   now,
-		RowCount:   int64(totalUpserted),
+		LastUpdate: &cursor.StartedAt,
+		LastSync:   time.Now(),
+		RowCount:   progress.RowCount,
 		Status:     "ok",
 	}
-	if state != nil {
-		newState.RowCount = state.RowCount + int64(totalUpserted)
-	}
 
 	if err := st.SetSyncState(ctx, ev.Slug, newState); err != nil {
 		return fmt.Errorf("set sync state: %w", err)
 	}
 
-	logger.Info("sync complete", "upserted", totalUpserted)
+	logger.InfoContext(ctx, "sync complete", "upserted", run.Upserted)
 	return nil
 }
 
-func saveErrorState(ctx context.Context, st SyncStore, evidence string, current *store.SyncState, syncErr error, logger *slog.Logger) {
-	state := store.SyncState{
-		Evidence: evidence,
-		LastSync: time.Now(),
-		Status:   "error",
-		ErrorMsg: syncErr.Error(),
+// syncMode names how a sync with cursor c started.
+func syncMode(c *store.SyncCursor) string {
+	if c.Filter == "" {
+		return "full"
+	}
+	return "incremental"
+}
+
+// recordSyncRun completes run with the outcome of the sync, stores it and
+// updates the sync metrics. Failing to store it does not fail the sync.
+func recordSyncRun(ctx context.Context, st SyncStore, run store.SyncRun, syncErr error, logger *slog.Logger) {
+	run.FinishedAt = time.Now()
+	run.Status = "ok"
+	if syncErr != nil {
+		run.Status = "error"
+		run.ErrorMsg = syncErr.Error()
+	}
+
+	metrics.RecordsFetched.WithLabelValues(run.Evidence).Add(float64(run.Fetched))
+	metrics.RecordsUpserted.WithLabelValues(run.Evidence).Add(float64(run.Upserted))
+	metrics.RecordsFailed.WithLabelValues(run.Evidence).Add(float64(run.Failed))
+	metrics.SyncDuration.WithLabelValues(run.Evidence, run.Status).Observe(run.Duration().Seconds())
+	if syncErr == nil {
+		metrics.LastSuccessfulSync.WithLabelValues(run.Evidence).Set(float64(run.FinishedAt.Unix()))
+	}
+	// Record runs cancelled by a shutdown too.
+	if err := st.RecordSyncRun(context.WithoutCancel(ctx), run); err != nil {
+		logger.ErrorContext(ctx, "failed to record sync run", "error", err)
+	}
+}
+
+// cursorFilter returns the Flexibee filter selecting the records of a sync
+// not synced yet.
+func cursorFilter(c *store.SyncCursor) string {
+	if c.LastID == 0 {
+		return c.Filter
 	}
-	if current != nil {
-		state.LastUpdate = current.LastUpdate
-		state.RowCount = current.RowCount
+	after := fmt.Sprintf("id > %d", c.LastID)
+	if c.Filter == "" {
+		return after
 	}
-	if err := st.SetSyncState(ctx, evidence, state); err != nil {
-		logger.Error("failed to save error state", "error", err)
+	return fmt.Sprintf("(%s) and %s", c.Filter, after)
+}
+
+// recordID converts a primary key value as decoded from Flexibee JSON,
+// where ids are numbers or numeric strings.
+func recordID(v any) (int64, bool) {
+	switch id := v.(type) {
+	case float64:
+		return int64(id), true
+	case string:
+		n, err := strconv.ParseInt(id, 10, 64)
+		return n, err == nil
+	case json.Number:
+		n, err := id.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// saveErrorState marks the sync of progress.Evidence as failed, keeping its
+// cursor so the next sync resumes where this one stopped.
+func saveErrorState(ctx context.Context, st SyncStore, progress store.SyncState, syncErr error, logger *slog.Logger) {
+	state := progress
+	state.LastSync = time.Now()
+	state.Status = "error"
+	state.ErrorMsg = syncErr.Error()
+	if err := st.SetSyncState(ctx, state.Evidence, state); err != nil {
+		logger.ErrorContext(ctx, "failed to save error state", "error", err)
 	}
 }
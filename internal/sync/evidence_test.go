@@ -2,13 +2,16 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
 	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
@@ -84,6 +87,7 @@ func TestSyncEvidence_IncrementalSync(t *testing.T) {
 	ms := newMockSyncStore()
 
 	// Set existing sync state to simulate previous sync
+	ms.rows["flexibee_test"] = map[string]bool{"1": true, "2": true}
 	lastUpdate := time.Now().Add(-time.Hour)
 	ms.states["test"] = &store.SyncState{
 		Evidence:   "test",
@@ -103,11 +107,32 @@ func TestSyncEvidence_IncrementalSync(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, ms.upsertCount["flexibee_test"])
 
-	// Row count should be cumulative
+	// The row count is the size of the table.
 	state := ms.states["test"]
 	assert.Equal(t, int64(3), state.RowCount)
 }
 
+func TestSyncEvidence_RowCountIgnoresUpdates(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"2","test":[{"id":1},{"id":2}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	ms := newMockSyncStore()
+	ev := registry.Evidence{Slug: "test", Table: "flexibee_test", PrimaryKey: "id"}
+
+	// The second sync updates the same two records.
+	require.NoError(t, syncEvidence(context.Background(), client, ms, ev, 100, discardLogger))
+	require.NoError(t, syncEvidence(context.Background(), client, ms, ev, 100, discardLogger))
+
+	assert.Equal(t, 4, ms.upsertCount["flexibee_test"])
+	assert.Equal(t, int64(2), ms.states["test"].RowCount)
+}
+
 func TestSyncEvidence_ServerError(t *testing.T) {
 	t.Parallel()
 
@@ -135,18 +160,162 @@ func TestSyncEvidence_ServerError(t *testing.T) {
 	}
 }
 
+func TestSyncEvidence_RecordsHistory(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"winstrom": {
+				"@version": "1.0",
+				"@rowCount": "2",
+				"test": [
+					{"id": 1, "kod": "T001"},
+					{"id": 2, "kod": "T002"}
+				]
+			}
+		}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	ms := newMockSyncStore()
+
+	plain := registry.Evidence{Slug: "test", Table: "flexibee_test", PrimaryKey: "id"}
+	err := syncEvidence(context.Background(), client, ms, plain, 100, discardLogger)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ms.historyCount["flexibee_test"])
+
+	tracked := registry.Evidence{Slug: "test", Table: "flexibee_test", PrimaryKey: "id", History: true}
+	err = syncEvidence(context.Background(), client, ms, tracked, 100, discardLogger)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ms.historyCount["flexibee_test"])
+}
+
 // mockSyncStore implements SyncStore for testing.
+func TestSyncEvidence_ResumesFromCursor(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "id", q.Get("order"))
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case q.Get("filter") == "" && q.Get("start") == "":
+			_, _ = w.Write([]byte(`{"winstrom":{"@rowCount":"4","test":[{"id":"1"},{"id":"2"}]}}`))
+		case q.Get("filter") == "":
+			// The first sync is interrupted after its first page.
+			w.WriteHeader(http.StatusBadRequest)
+		case q.Get("filter") == "id > 2":
+			_, _ = w.Write([]byte(`{"winstrom":{"@rowCount":"2","test":[{"id":"3"},{"id":"4"}]}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	ms := newMockSyncStore()
+	ev := registry.Evidence{Slug: "test", Table: "flexibee_test", PrimaryKey: "id"}
+
+	err := syncEvidence(context.Background(), client, ms, ev, 2, discardLogger)
+	require.Error(t, err)
+
+	state := ms.states["test"]
+	assert.Equal(t, "error", state.Status)
+	assert.Zero(t, state.RowCount, "rows are counted when the sync completes")
+	require.NotNil(t, state.Cursor)
+	assert.Equal(t, int64(2), state.Cursor.LastID)
+	startedAt := state.Cursor.StartedAt
+
+	require.NoError(t, syncEvidence(context.Background(), client, ms, ev, 2, discardLogger))
+
+	state = ms.states["test"]
+	assert.Equal(t, "ok", state.Status)
+	assert.Nil(t, state.Cursor)
+	assert.Equal(t, int64(4), state.RowCount)
+	assert.Equal(t, 4, ms.upsertCount["flexibee_test"])
+	// Changes made since the interrupted sync started are fetched next time.
+	assert.Equal(t, startedAt, *state.LastUpdate)
+
+	// Both syncs are recorded, the resumed one still as a full sync.
+	require.Len(t, ms.runs, 2)
+	failed, resumed := ms.runs[0], ms.runs[1]
+	assert.Equal(t, "error", failed.Status)
+	assert.Contains(t, failed.ErrorMsg, "400")
+	assert.Equal(t, 1, failed.Pages)
+	assert.Equal(t, int64(2), failed.Fetched)
+	assert.Positive(t, failed.Bytes)
+	assert.Equal(t, "ok", resumed.Status)
+	assert.Equal(t, "full", resumed.Mode)
+	assert.Equal(t, int64(2), resumed.Upserted)
+	assert.Zero(t, resumed.Failed)
+	assert.False(t, resumed.FinishedAt.Before(resumed.StartedAt))
+}
+
+func TestSyncEvidence_CountsRejectedRecordsAsFailed(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@rowCount":"3","test":[{"id":"1"},{"id":"2"},{"id":"3"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	ms := newMockSyncStore()
+	// E.g. a record without a value in the partition column.
+	ms.rejected = 1
+	ev := registry.Evidence{Slug: "test", Table: "flexibee_test", PrimaryKey: "id"}
+
+	require.NoError(t, syncEvidence(context.Background(), client, ms, ev, 100, discardLogger))
+
+	require.Len(t, ms.runs, 1)
+	assert.Equal(t, int64(3), ms.runs[0].Fetched)
+	assert.Equal(t, int64(2), ms.runs[0].Upserted)
+	assert.Equal(t, int64(1), ms.runs[0].Failed)
+}
+
+func TestCursorFilter(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", cursorFilter(&store.SyncCursor{}))
+	assert.Equal(t, "id > 7", cursorFilter(&store.SyncCursor{LastID: 7}))
+	assert.Equal(t, "(lastUpdate > 'x') and id > 7", cursorFilter(&store.SyncCursor{LastID: 7, Filter: "lastUpdate > 'x'"}))
+}
+
 type mockSyncStore struct {
-	states      map[string]*store.SyncState
-	upsertCount map[string]int
-	cleanups    map[string]int64
+	states       map[string]*store.SyncState
+	upsertCount  map[string]int
+	historyCount map[string]int
+	cleanups     map[string]int64
+	dropped      map[string]int64
+	counted      map[string]int
+	cleaned      map[string]int
+	rows         map[string]map[string]bool // ids stored per table
+	runs         []store.SyncRun
+	runsCleaned  int
+	paused       map[string]time.Time
+
+	// unpartitioned tables fail partition cleanups with ErrNotPartitioned.
+	unpartitioned map[string]bool
+	// rejected is the number of records of every batch UpsertRecords skips.
+	rejected int
 }
 
 func newMockSyncStore() *mockSyncStore {
 	return &mockSyncStore{
-		states:      make(map[string]*store.SyncState),
-		upsertCount: make(map[string]int),
-		cleanups:    make(map[string]int64),
+		states:        make(map[string]*store.SyncState),
+		upsertCount:   make(map[string]int),
+		historyCount:  make(map[string]int),
+		cleanups:      make(map[string]int64),
+		dropped:       make(map[string]int64),
+		counted:       make(map[string]int),
+		cleaned:       make(map[string]int),
+		rows:          make(map[string]map[string]bool),
+		unpartitioned: make(map[string]bool),
+		paused:        make(map[string]time.Time),
 	}
 }
 
@@ -163,16 +332,75 @@ func (m *mockSyncStore) SetSyncState(_ context.Context, evidence string, state s
 	return nil
 }
 
-func (m *mockSyncStore) UpsertRecords(_ context.Context, table string, records []map[string]any, _ string) (int, error) {
-	m.upsertCount[table] += len(records)
+func (m *mockSyncStore) UpsertRecords(_ context.Context, table string, records []map[string]any, primaryKey string) (int, error) {
+	upserted := max(len(records)-m.rejected, 0)
+	m.upsertCount[table] += upserted
+	if m.rows[table] == nil {
+		m.rows[table] = make(map[string]bool)
+	}
+	for _, record := range records[:upserted] {
+		m.rows[table][fmt.Sprint(record[primaryKey])] = true
+	}
+	return upserted, nil
+}
+
+func (m *mockSyncStore) AggregateRecords(_ context.Context, table string, _ store.AggregateQuery) (store.Aggregate, error) {
+	return store.Aggregate{Count: int64(len(m.rows[table]))}, nil
+}
+
+func (m *mockSyncStore) RecordHistory(_ context.Context, table string, records []map[string]any, _ string) (int, error) {
+	m.historyCount[table] += len(records)
 	return len(records), nil
 }
 
 func (m *mockSyncStore) CleanupOldRecords(_ context.Context, table string, _ time.Time, _ int) (int64, error) {
+	m.cleaned[table]++
 	deleted := m.cleanups[table]
 	return deleted, nil
 }
 
+func (m *mockSyncStore) DropPartitionsBefore(_ context.Context, table string, _ time.Time) (int64, error) {
+	if m.unpartitioned[table] {
+		return 0, store.ErrNotPartitioned
+	}
+	m.dropped[table]++
+	return 0, nil
+}
+
+func (m *mockSyncStore) CountOldRecords(_ context.Context, table string, _ time.Time) (int64, error) {
+	m.counted[table]++
+	return m.cleanups[table], nil
+}
+
+func (m *mockSyncStore) CountPartitionsBefore(_ context.Context, table string, _ time.Time) (int64, error) {
+	m.counted[table]++
+	return 0, nil
+}
+
+func (m *mockSyncStore) RecordSyncRun(_ context.Context, run store.SyncRun) error {
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func (m *mockSyncStore) CleanupSyncRuns(_ context.Context, _ time.Time) (int64, error) {
+	m.runsCleaned++
+	return 0, nil
+}
+
+func (m *mockSyncStore) PauseEvidence(_ context.Context, evidence string) error {
+	m.paused[evidence] = time.Now()
+	return nil
+}
+
+func (m *mockSyncStore) ResumeEvidence(_ context.Context, evidence string) error {
+	delete(m.paused, evidence)
+	return nil
+}
+
+func (m *mockSyncStore) PausedEvidences(_ context.Context) (map[string]time.Time, error) {
+	return maps.Clone(m.paused), nil
+}
+
 func (m *mockSyncStore) LogCleanup(_ context.Context, _ string, _ int64, _ *time.Time) error {
 	return nil
 }
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// heartbeatInterval is how often an idle engine loop beats.
+const heartbeatInterval = 10 * time.Second
+
+// HealthStore is the storage checked by the readiness probe.
+type HealthStore interface {
+	Ping(ctx context.Context) error
+	MigrationStatus(ctx context.Context) ([]store.MigrationStatus, error)
+	GetSyncState(ctx context.Context, evidence string) (*store.SyncState, error)
+	PausedEvidences(ctx context.Context) (map[string]time.Time, error)
+}
+
+// beat records that the engine is making progress.
+func (e *Engine) beat() {
+	e.heartbeat.Store(time.Now().UnixNano())
+}
+
+// Alive reports an error when the engine loop has made no progress for
+// longer than timeout. The loop beats while idle, after every evidence
+// sync and after every synced page. An engine that is not running, such
+// as a replica standing by for leadership, is alive.
+func (e *Engine) Alive(timeout time.Duration) error {
+	if !e.active.Load() {
+		return nil
+	}
+	if stalled := time.Since(time.Unix(0, e.heartbeat.Load())); stalled > timeout {
+		return fmt.Errorf("engine made no progress for %s", stalled.Round(time.Second))
+	}
+	return nil
+}
+
+// Ready reports an error unless the database is reachable, all migrations
+// are applied and every evidence that is not paused was last synced
+// successfully within maxSyncAge (0 skips the sync age check).
+func (e *Engine) Ready(ctx context.Context, maxSyncAge time.Duration) error {
+	return checkReady(ctx, e.store, e.registry.All(), maxSyncAge, time.Now())
+}
+
+func checkReady(ctx context.Context, st HealthStore, evidences []registry.Evidence, maxSyncAge time.Duration, now time.Time) error {
+	if err := st.Ping(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	migrations, err := st.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("migration status: %w", err)
+	}
+	for _, m := range migrations {
+		if !m.Applied {
+			return fmt.Errorf("migration %03d_%s not applied", m.Version, m.Name)
+		}
+	}
+
+	if maxSyncAge <= 0 {
+		return nil
+	}
+	paused, err := st.PausedEvidences(ctx)
+	if err != nil {
+		return fmt.Errorf("read paused evidences: %w", err)
+	}
+	var errs []error
+	for _, ev := range evidences {
+		if _, ok := paused[ev.Slug]; ok {
+			continue
+		}
+		state, err := st.GetSyncState(ctx, ev.Slug)
+		if err != nil {
+			return fmt.Errorf("get sync state of %s: %w", ev.Slug, err)
+		}
+		// LastUpdate is when the last successful sync started.
+		switch {
+		case state == nil || state.LastUpdate == nil:
+			errs = append(errs, fmt.Errorf("%s never synced", ev.Slug))
+		case now.Sub(*state.LastUpdate) > maxSyncAge:
+			errs = append(errs, fmt.Errorf("%s last synced %s ago", ev.Slug, now.Sub(*state.LastUpdate).Round(time.Second)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// heartbeatStore beats the engine heartbeat whenever a sync saves its
+// progress, which it does after every page.
+type heartbeatStore struct {
+	SyncStore
+	beat func()
+}
+
+func (s heartbeatStore) SetSyncState(ctx context.Context, evidence string, state store.SyncState) error {
+	s.beat()
+	return s.SyncStore.SetSyncState(ctx, evidence, state)
+}
@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// mockHealthStore is a HealthStore backed by a mockSyncStore.
+type mockHealthStore struct {
+	*mockSyncStore
+	pingErr    error
+	migrations []store.MigrationStatus
+}
+
+func (m *mockHealthStore) Ping(context.Context) error { return m.pingErr }
+
+func (m *mockHealthStore) MigrationStatus(context.Context) ([]store.MigrationStatus, error) {
+	return m.migrations, nil
+}
+
+func TestCheckReady(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	recent, stale := now.Add(-10*time.Minute), now.Add(-3*time.Hour)
+	evidences := []registry.Evidence{{Slug: "banka"}, {Slug: "adresar"}}
+
+	newStore := func() *mockHealthStore {
+		st := &mockHealthStore{
+			mockSyncStore: newMockSyncStore(),
+			migrations:    []store.MigrationStatus{{Version: 1, Name: "init", Applied: true}},
+		}
+		st.states["banka"] = &store.SyncState{LastUpdate: &recent}
+		st.states["adresar"] = &store.SyncState{LastUpdate: &recent}
+		return st
+	}
+
+	st := newStore()
+	require.NoError(t, checkReady(context.Background(), st, evidences, time.Hour, now))
+
+	st = newStore()
+	st.pingErr = errors.New("connection refused")
+	assert.ErrorContains(t, checkReady(context.Background(), st, evidences, time.Hour, now), "database unreachable")
+
+	st = newStore()
+	st.migrations = append(st.migrations, store.MigrationStatus{Version: 2, Name: "sync_runs"})
+	assert.ErrorContains(t, checkReady(context.Background(), st, evidences, time.Hour, now), "migration 002_sync_runs not applied")
+
+	st = newStore()
+	st.states["banka"].LastUpdate = &stale
+	delete(st.states, "adresar")
+	err := checkReady(context.Background(), st, evidences, time.Hour, now)
+	assert.ErrorContains(t, err, "banka last synced 3h0m0s ago")
+	assert.ErrorContains(t, err, "adresar never synced")
+
+	// Paused evidences are not expected to sync.
+	st.paused["banka"] = now
+	st.paused["adresar"] = now
+	require.NoError(t, checkReady(context.Background(), st, evidences, time.Hour, now))
+
+	// A zero maximum age skips the sync age check.
+	require.NoError(t, checkReady(context.Background(), st, evidences, 0, now))
+}
+
+func TestEngine_Alive(t *testing.T) {
+	t.Parallel()
+
+	e := &Engine{}
+	require.NoError(t, e.Alive(time.Minute), "an engine that is not running is alive")
+
+	e.active.Store(true)
+	e.beat()
+	require.NoError(t, e.Alive(time.Minute))
+
+	e.heartbeat.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	assert.ErrorContains(t, e.Alive(time.Minute), "engine made no progress")
+}
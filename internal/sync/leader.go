@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LeaseName is the lease the replicas of an adapter compete for.
+const LeaseName = "sync"
+
+// ErrNotLeader is returned by RunExclusive when another replica holds the
+// lease.
+var ErrNotLeader = errors.New("another replica holds the sync lease")
+
+// LeaseStore is the storage of the leadership lease.
+type LeaseStore interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// LeaderElector lets only one of several adapter replicas sync at a time.
+// The leader holds a lease in the database and renews it every third of
+// its duration; standby replicas keep trying to acquire it and take over
+// once the leader stops renewing and the lease expires.
+type LeaderElector struct {
+	store  LeaseStore
+	holder string
+	ttl    time.Duration
+	logger *slog.Logger
+}
+
+// NewLeaderElector creates a leader elector for the replica identified by
+// holder, with leases valid for ttl.
+func NewLeaderElector(st LeaseStore, holder string, ttl time.Duration, logger *slog.Logger) *LeaderElector {
+	return &LeaderElector{
+		store:  st,
+		holder: holder,
+		ttl:    ttl,
+		logger: logger.With("holder", holder),
+	}
+}
+
+// Run waits for leadership and calls lead while it lasts, with a context
+// cancelled when the lease is lost. It returns when ctx is cancelled,
+// releasing the lease, or when lead fails.
+func (l *LeaderElector) Run(ctx context.Context, lead func(context.Context) error) error {
+	defer l.release(ctx)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	l.logger.Info("waiting for leadership", "lease", l.ttl)
+	for {
+		acquired, err := l.store.AcquireLease(ctx, LeaseName, l.holder, l.ttl)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			l.logger.Warn("failed to acquire leadership", "error", err)
+		}
+		if acquired {
+			l.logger.Info("acquired leadership")
+			if err := l.lead(ctx, lead); err != nil {
+				return err
+			}
+			if ctx.Err() == nil {
+				l.logger.Warn("lost leadership, standing by")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunExclusive calls fn once, holding the lease while it runs, for one-off
+// commands that must not sync alongside the leader. It fails with
+// ErrNotLeader without calling fn if another replica holds the lease.
+func (l *LeaderElector) RunExclusive(ctx context.Context, fn func(context.Context) error) error {
+	acquired, err := l.store.AcquireLease(ctx, LeaseName, l.holder, l.ttl)
+	if err != nil {
+		return fmt.Errorf("acquire lease: %w", err)
+	}
+	if !acquired {
+		return ErrNotLeader
+	}
+	defer l.release(ctx)
+
+	var fnErr error
+	if err := l.lead(ctx, func(ctx context.Context) error {
+		fnErr = fn(ctx)
+		return fnErr
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// lead runs lead while renewing the lease. A lease that could not be
+// renewed for two thirds of its duration counts as lost, so that the
+// leader stops before a standby replica can take the lease over.
+func (l *LeaderElector) lead(ctx context.Context, lead func(context.Context) error) error {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- lead(leaderCtx) }()
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	renewed := time.Now()
+	for {
+		select {
+		case err := <-done:
+			if leaderCtx.Err() != nil {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+		}
+
+		held, err := l.store.AcquireLease(ctx, LeaseName, l.holder, l.ttl)
+		switch {
+		case err == nil && held:
+			renewed = time.Now()
+			continue
+		case err == nil:
+			l.logger.Warn("lease taken over by another replica")
+		case time.Since(renewed) < l.ttl-l.ttl/3:
+			l.logger.Warn("failed to renew leadership", "error", err)
+			continue
+		default:
+			l.logger.Error("failed to renew leadership before the lease expired", "error", err)
+		}
+
+		cancel()
+		<-done
+		return nil
+	}
+}
+
+// release gives up the lease on shutdown so that a standby replica takes
+// over without waiting for it to expire.
+func (l *LeaderElector) release(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := l.store.ReleaseLease(ctx, LeaseName, l.holder); err != nil {
+		l.logger.Warn("failed to release leadership", "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLeaseStore keeps a single lease in memory.
+type memoryLeaseStore struct {
+	mu        gosync.Mutex
+	holder    string
+	expiresAt time.Time
+}
+
+func (m *memoryLeaseStore) AcquireLease(_ context.Context, _, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder != "" && m.holder != holder && time.Now().Before(m.expiresAt) {
+		return false, nil
+	}
+	m.holder, m.expiresAt = holder, time.Now().Add(ttl)
+	return true, nil
+}
+
+func (m *memoryLeaseStore) ReleaseLease(_ context.Context, _, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == holder {
+		m.holder = ""
+	}
+	return nil
+}
+
+func (m *memoryLeaseStore) steal(holder string, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holder, m.expiresAt = holder, time.Now().Add(ttl)
+}
+
+func TestLeaderElector_FailsOver(t *testing.T) {
+	t.Parallel()
+
+	st := &memoryLeaseStore{}
+	var leading atomic.Int32
+	var maxLeading atomic.Int32
+	led := make(chan string, 2)
+
+	run := func(ctx context.Context, holder string) error {
+		return NewLeaderElector(st, holder, 30*time.Millisecond, discardLogger).Run(ctx, func(ctx context.Context) error {
+			n := leading.Add(1)
+			defer leading.Add(-1)
+			if n > maxLeading.Load() {
+				maxLeading.Store(n)
+			}
+			led <- holder
+			<-ctx.Done()
+			return nil
+		})
+	}
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	doneA := make(chan error, 1)
+	go func() { doneA <- run(ctxA, "a") }()
+	require.Equal(t, "a", <-led)
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	doneB := make(chan error, 1)
+	go func() { doneB <- run(ctxB, "b") }()
+
+	// b stands by while a renews the lease.
+	select {
+	case <-led:
+		t.Fatal("standby replica led while the lease was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// a shuts down and releases the lease; b takes over.
+	stopA()
+	require.NoError(t, <-doneA)
+	select {
+	case holder := <-led:
+		assert.Equal(t, "b", holder)
+	case <-time.After(time.Second):
+		t.Fatal("standby replica did not take over")
+	}
+
+	stopB()
+	require.NoError(t, <-doneB)
+	assert.Equal(t, int32(1), maxLeading.Load())
+}
+
+func TestLeaderElector_StopsLeadingWhenLeaseIsLost(t *testing.T) {
+	t.Parallel()
+
+	st := &memoryLeaseStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	led := make(chan struct{}, 1)
+	stopped := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- NewLeaderElector(st, "a", 30*time.Millisecond, discardLogger).Run(ctx, func(ctx context.Context) error {
+			led <- struct{}{}
+			<-ctx.Done()
+			stopped <- struct{}{}
+			return nil
+		})
+	}()
+	<-led
+
+	st.steal("b", time.Hour)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("leader kept leading after losing the lease")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestLeaderElector_RunExclusive(t *testing.T) {
+	t.Parallel()
+
+	st := &memoryLeaseStore{}
+	st.steal("daemon", time.Minute)
+
+	called := false
+	err := NewLeaderElector(st, "cron", time.Minute, discardLogger).RunExclusive(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	require.ErrorIs(t, err, ErrNotLeader)
+	assert.False(t, called)
+
+	// Once the lease is free, fn runs holding it and the lease is released.
+	require.NoError(t, st.ReleaseLease(context.Background(), LeaseName, "daemon"))
+	err = NewLeaderElector(st, "cron", time.Minute, discardLogger).RunExclusive(context.Background(), func(context.Context) error {
+		assert.Equal(t, "cron", st.holder)
+		return assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, st.holder)
+}
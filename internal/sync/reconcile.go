@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/metrics"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// sumTolerance is the largest difference between totals not reported as
+// drift, absorbing rounding of Flexibee's sums.
+const sumTolerance = 0.01
+
+// ReconcileStore defines the store operations needed by the reconciler.
+type ReconcileStore interface {
+	AggregateRecords(ctx context.Context, table string, q store.AggregateQuery) (store.Aggregate, error)
+	SaveReconciliation(ctx context.Context, results []store.Reconciliation) error
+}
+
+// ReconcileConfig controls reconciliation.
+type ReconcileConfig struct {
+	// Months is the number of monthly periods, up to the current month,
+	// reconciled for evidences with a document date.
+	Months int
+	// RetentionDays skips periods reaching back before the retention
+	// window, whose records may have been cleaned up (0 = no retention).
+	RetentionDays int
+}
+
+// Reconciler compares record counts and totals per evidence and period
+// between Flexibee and the synced tables.
+type Reconciler struct {
+	client   *flexibee.Client
+	store    ReconcileStore
+	registry *registry.Registry
+	config   ReconcileConfig
+	logger   *slog.Logger
+}
+
+// NewReconciler creates a new Reconciler.
+func NewReconciler(client *flexibee.Client, st ReconcileStore, reg *registry.Registry, cfg ReconcileConfig, logger *slog.Logger) *Reconciler {
+	return &Reconciler{
+		client:   client,
+		store:    st,
+		registry: reg,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// period is a range of document dates reconciled as a whole.
+type period struct {
+	Name     string    // "YYYY-MM" or "all"
+	From, To time.Time // zero for "all"
+}
+
+// Run reconciles every registered evidence and stores the results. Drift
+// is logged as a warning; evidences that fail are logged and skipped.
+func (r *Reconciler) Run(ctx context.Context) error {
+	now := time.Now()
+	checked, drifted := 0, 0
+
+	for _, ev := range r.registry.All() {
+		var results []store.Reconciliation
+		for _, p := range r.periods(ev, now) {
+			res, err := r.reconcile(ctx, ev, p, now)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				r.logger.Error("reconciliation failed", "evidence", ev.Slug, "period", p.Name, "error", err)
+				continue
+			}
+			results = append(results, res...)
+		}
+
+		driftPeriods := make(map[string]bool)
+		for _, res := range results {
+			checked++
+			if res.Drift {
+				drifted++
+				driftPeriods[res.Period] = true
+				r.logger.Warn("reconciliation drift",
+					"evidence", res.Evidence, "period", res.Period, "metric", res.Metric,
+					"flexibee", res.Flexibee, "synced", res.Synced)
+			}
+		}
+		metrics.ReconciliationDrift.WithLabelValues(ev.Slug).Set(float64(len(driftPeriods)))
+		if err := r.store.SaveReconciliation(ctx, results); err != nil {
+			r.logger.Error("failed to save reconciliation", "evidence", ev.Slug, "error", err)
+		}
+	}
+
+	r.logger.Info("reconciliation complete", "checked", checked, "drift", drifted)
+	return nil
+}
+
+// periods returns the periods of ev to reconcile. Evidences with a document
+// date are reconciled per month; the whole evidence is reconciled as well
+// unless cleanup may have removed some of its records.
+func (r *Reconciler) periods(ev registry.Evidence, now time.Time) []period {
+	var periods []period
+
+	if ev.DateColumn != "" {
+		var cutoff time.Time
+		if r.config.RetentionDays > 0 {
+			cutoff = now.AddDate(0, 0, -r.config.RetentionDays)
+		}
+
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for range r.config.Months {
+			if month.Before(cutoff) {
+				break
+			}
+			periods = append(periods, period{Name: month.Format("2006-01"), From: month, To: month.AddDate(0, 1, 0)})
+			month = month.AddDate(0, -1, 0)
+		}
+	}
+
+	if r.config.RetentionDays <= 0 || ev.IsMasterData {
+		periods = append(periods, period{Name: "all"})
+	}
+	return periods
+}
+
+// reconcile compares the count and totals of ev in p.
+func (r *Reconciler) reconcile(ctx context.Context, ev registry.Evidence, p period, now time.Time) ([]store.Reconciliation, error) {
+	q := store.AggregateQuery{SumColumns: ev.SumColumns}
+	filter := ""
+	if p.Name != "all" {
+		q.DateColumn, q.From, q.To = ev.DateColumn, p.From, p.To
+		filter = fmt.Sprintf("%s >= '%s' and %s < '%s'",
+			ev.DateColumn, p.From.Format(time.DateOnly), ev.DateColumn, p.To.Format(time.DateOnly))
+	}
+
+	count, err := r.client.CountRecords(ctx, ev.Slug, filter)
+	if err != nil {
+		return nil, err
+	}
+	var sums map[string]float64
+	if len(ev.SumColumns) > 0 {
+		if sums, err = r.client.FetchSums(ctx, ev.Slug, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	agg, err := r.store.AggregateRecords(ctx, ev.Table, q)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []store.Reconciliation{{
+		Evidence:  ev.Slug,
+		Period:    p.Name,
+		Metric:    "count",
+		Flexibee:  float64(count),
+		Synced:    float64(agg.Count),
+		Drift:     int64(count) != agg.Count,
+		CheckedAt: now,
+	}}
+	for _, col := range ev.SumColumns {
+		want, ok := sums[col]
+		if !ok {
+			r.logger.Debug("Flexibee returned no total", "evidence", ev.Slug, "column", col)
+			continue
+		}
+		got := agg.Sums[col]
+		results = append(results, store.Reconciliation{
+			Evidence:  ev.Slug,
+			Period:    p.Name,
+			Metric:    col,
+			Flexibee:  want,
+			Synced:    got,
+			Drift:     math.Abs(got-want) > sumTolerance,
+			CheckedAt: now,
+		})
+	}
+	return results, nil
+}
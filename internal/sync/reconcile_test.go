@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/store"
+)
+
+// mockReconcileStore returns fixed aggregates and records saved results.
+type mockReconcileStore struct {
+	aggregates map[string]store.Aggregate // keyed by period ("all" without a date range)
+	saved      []store.Reconciliation
+}
+
+func (m *mockReconcileStore) AggregateRecords(_ context.Context, _ string, q store.AggregateQuery) (store.Aggregate, error) {
+	key := "all"
+	if q.DateColumn != "" {
+		key = q.From.Format("2006-01")
+	}
+	return m.aggregates[key], nil
+}
+
+func (m *mockReconcileStore) SaveReconciliation(_ context.Context, results []store.Reconciliation) error {
+	m.saved = append(m.saved, results...)
+	return nil
+}
+
+func TestReconciler_FlagsDrift(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/$sum.json") {
+			_, _ = w.Write([]byte(`{"winstrom":{"sum":{"sumCelkem":"1000.00"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"winstrom":{"@rowCount":"10","faktura-vydana":[{"id":"1"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{
+		Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", PrimaryKey: "id",
+		DateColumn: "datVyst", SumColumns: []string{"sumCelkem"},
+	})
+
+	current := time.Now().Format("2006-01")
+	ms := &mockReconcileStore{aggregates: map[string]store.Aggregate{
+		current: {Count: 10, Sums: map[string]float64{"sumCelkem": 999.995}},
+		"all":   {Count: 9, Sums: map[string]float64{"sumCelkem": 900}},
+	}}
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	r := NewReconciler(client, ms, reg, ReconcileConfig{Months: 1}, discardLogger)
+	require.NoError(t, r.Run(context.Background()))
+
+	require.Len(t, ms.saved, 4)
+	drift := make(map[string]bool)
+	for _, res := range ms.saved {
+		drift[res.Period+"/"+res.Metric] = res.Drift
+	}
+	assert.Equal(t, map[string]bool{
+		current + "/count":     false,
+		current + "/sumCelkem": false,
+		"all/count":            true,
+		"all/sumCelkem":        true,
+	}, drift)
+}
+
+func TestReconciler_Periods(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	names := func(periods []period) []string {
+		var out []string
+		for _, p := range periods {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+	doc := registry.Evidence{Slug: "faktura-vydana", DateColumn: "datVyst"}
+
+	r := &Reconciler{config: ReconcileConfig{Months: 3}}
+	assert.Equal(t, []string{"2024-03", "2024-02", "2024-01", "all"}, names(r.periods(doc, now)))
+
+	// Periods reaching back before the retention window are skipped, and so
+	// is the whole evidence.
+	r = &Reconciler{config: ReconcileConfig{Months: 3, RetentionDays: 45}}
+	assert.Equal(t, []string{"2024-03", "2024-02"}, names(r.periods(doc, now)))
+
+	// Master data is never cleaned up.
+	master := registry.Evidence{Slug: "adresar", IsMasterData: true}
+	assert.Equal(t, []string{"all"}, names(r.periods(master, now)))
+
+	p := r.periods(doc, now)[1]
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), p.From)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), p.To)
+}
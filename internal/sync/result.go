@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Outcomes of an evidence in a sync pass.
+const (
+	OutcomeOK      = "ok"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped" // paused, or already being synced
+)
+
+// Overall statuses of a sync pass.
+const (
+	PassOK      = "ok"
+	PassPartial = "partial" // some evidences failed, others synced
+	PassFailed  = "failed"  // every evidence that was synced failed
+)
+
+// ErrPassFailed is wrapped by the error of a sync pass in which any
+// evidence failed.
+var ErrPassFailed = errors.New("sync pass failed")
+
+// errAlreadyRunning is returned by syncExclusive when it skips an evidence
+// being synced.
+var errAlreadyRunning = errors.New("sync already running")
+
+// EvidenceResult is the outcome of one evidence in a sync pass.
+type EvidenceResult struct {
+	Evidence string
+	Outcome  string // OutcomeOK, OutcomeFailed or OutcomeSkipped
+	Reason   string // why the evidence was skipped
+	Duration time.Duration
+	Err      error // set if failed
+}
+
+// PassResult summarises a sync pass. Every evidence is synced regardless
+// of the others failing, so a pass can partially succeed.
+type PassResult struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Evidences  []EvidenceResult // in sync order
+}
+
+// Count returns the number of evidences with outcome.
+func (r *PassResult) Count(outcome string) int {
+	n := 0
+	for _, ev := range r.Evidences {
+		if ev.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Status returns the overall status of the pass: PassOK when no evidence
+// failed, PassFailed when no evidence synced but some failed, PassPartial
+// otherwise.
+func (r *PassResult) Status() string {
+	switch failed := r.Count(OutcomeFailed); {
+	case failed == 0:
+		return PassOK
+	case r.Count(OutcomeOK) == 0:
+		return PassFailed
+	default:
+		return PassPartial
+	}
+}
+
+// Err returns nil if no evidence failed, otherwise an error wrapping
+// ErrPassFailed and the errors of the failed evidences.
+func (r *PassResult) Err() error {
+	var errs []error
+	for _, ev := range r.Evidences {
+		if ev.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ev.Evidence, ev.Err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w (%s, %d of %d evidences failed): %w",
+		ErrPassFailed, r.Status(), len(errs), len(r.Evidences), errors.Join(errs...))
+}
+
+// Failed returns the results of the failed evidences.
+func (r *PassResult) Failed() []EvidenceResult {
+	return slices.DeleteFunc(slices.Clone(r.Evidences), func(ev EvidenceResult) bool {
+		return ev.Outcome != OutcomeFailed
+	})
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+// Schedule decides when an evidence with its own schedule is synced next.
+type Schedule interface {
+	// Next returns the next sync time after t.
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule syncs at a fixed interval after the previous sync.
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule syncs at the times of a cron expression evaluated in loc.
+type cronSchedule struct {
+	schedule cron.Schedule
+	loc      *time.Location
+}
+
+func (s cronSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.loc))
+}
+
+// ParseSchedule parses an evidence sync schedule: either an interval such
+// as "5m" or a standard five-field cron expression such as "* 8-20 * * 1-6",
+// evaluated in loc unless it starts with CRON_TZ=<zone>.
+func ParseSchedule(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be positive", spec)
+		}
+		return intervalSchedule(d), nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return cronSchedule{schedule: schedule, loc: loc}, nil
+}
+
+// runSchedule syncs ev at the times of schedule until ctx is cancelled.
+func (e *Engine) runSchedule(ctx context.Context, ev registry.Evidence, schedule Schedule) {
+	for {
+		next := schedule.Next(time.Now())
+		e.logger.Debug("next scheduled sync", "evidence", ev.Slug, "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, paused := e.paused(ctx)[ev.Slug]; paused {
+			e.logger.Info("evidence paused, skipping scheduled sync", "evidence", ev.Slug)
+			continue
+		}
+		if err := e.syncExclusive(ctx, ev); err != nil && !errors.Is(err, errAlreadyRunning) {
+			e.logger.Error("scheduled sync failed", "evidence", ev.Slug, "error", err)
+		}
+	}
+}
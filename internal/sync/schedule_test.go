@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	prague, err := time.LoadLocation("Europe/Prague")
+	require.NoError(t, err)
+	now := time.Date(2024, 3, 4, 20, 30, 0, 0, time.UTC) // Monday, 21:30 in Prague
+
+	s, err := ParseSchedule("5m", prague)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(5*time.Minute), s.Next(now))
+
+	// Every minute during shop hours, in the given time zone.
+	s, err = ParseSchedule("* 8-20 * * 1-6", prague)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2024, 3, 5, 8, 0, 0, 0, prague).Equal(s.Next(now)))
+
+	// CRON_TZ overrides the time zone.
+	s, err = ParseSchedule("CRON_TZ=UTC 0 2 * * *", prague)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC).Equal(s.Next(now)))
+
+	for _, bad := range []string{"", "0s", "-1m", "every minute", "* * *"} {
+		_, err := ParseSchedule(bad, prague)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEngine_SyncExclusive_SkipsRunning(t *testing.T) {
+	t.Parallel()
+
+	ms := newMockSyncStore()
+	e := &Engine{syncStore: ms, logger: discardLogger, running: make(map[string]Activity), locks: make(map[string]*gosync.Mutex)}
+	e.lock("banka").Lock()
+
+	// A sync already running is not started again (it would need a client).
+	err := e.syncExclusive(context.Background(), registry.Evidence{Slug: "banka", Table: "flexibee_banka"})
+	require.ErrorIs(t, err, errAlreadyRunning)
+	assert.Empty(t, ms.states)
+	assert.Empty(t, e.Running())
+}
+
+func TestEngine_Schedules(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "prodejka", Schedule: "1m"})
+	reg.Register(registry.Evidence{Slug: "banka"})
+
+	e := &Engine{registry: reg}
+	schedules, err := e.schedules()
+	require.NoError(t, err)
+	assert.Len(t, schedules, 1)
+	assert.Contains(t, schedules, "prodejka")
+
+	reg.Update("banka", func(ev *registry.Evidence) { ev.Schedule = "sometimes" })
+	_, err = e.schedules()
+	assert.ErrorContains(t, err, "schedule of banka")
+}
@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+// Stage is an optional step the engine runs around syncing, such as
+// maintaining views or models built on top of the synced tables.
+type Stage interface {
+	// Name identifies the stage in logs.
+	Name() string
+	// Prepare runs once at startup, after all evidence tables are ensured.
+	Prepare(ctx context.Context) error
+	// Run runs at the end of every sync pass.
+	Run(ctx context.Context) error
+}
+
+// TableSchema describes an evidence table as ensured by the engine.
+type TableSchema struct {
+	Evidence registry.Evidence
+	// Properties are the Flexibee property definitions the table's columns
+	// were created from; empty if they could not be fetched.
+	Properties []flexibee.Property
+}
+
+// SchemaObserver is implemented by stages that need the Flexibee metadata
+// of the synced tables. The engine calls ObserveSchema before Prepare.
+type SchemaObserver interface {
+	ObserveSchema(tables []TableSchema)
+}
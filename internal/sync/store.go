@@ -12,6 +12,16 @@ type SyncStore interface {
 	GetSyncState(ctx context.Context, evidence string) (*store.SyncState, error)
 	SetSyncState(ctx context.Context, evidence string, state store.SyncState) error
 	UpsertRecords(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error)
+	RecordHistory(ctx context.Context, table string, records []map[string]any, primaryKey string) (int, error)
+	AggregateRecords(ctx context.Context, table string, q store.AggregateQuery) (store.Aggregate, error)
 	CleanupOldRecords(ctx context.Context, table string, olderThan time.Time, batchSize int) (int64, error)
+	DropPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error)
+	CountOldRecords(ctx context.Context, table string, olderThan time.Time) (int64, error)
+	CountPartitionsBefore(ctx context.Context, table string, cutoff time.Time) (int64, error)
 	LogCleanup(ctx context.Context, evidence string, rowsDeleted int64, oldestKept *time.Time) error
+	RecordSyncRun(ctx context.Context, run store.SyncRun) error
+	CleanupSyncRuns(ctx context.Context, olderThan time.Time) (int64, error)
+	PauseEvidence(ctx context.Context, evidence string) error
+	ResumeEvidence(ctx context.Context, evidence string) error
+	PausedEvidences(ctx context.Context) (map[string]time.Time, error)
 }
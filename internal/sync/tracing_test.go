@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/flexibee"
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func TestSyncEvidence_Spans(t *testing.T) {
+	// The global tracer provider also records the spans of tests running
+	// in parallel; only the spans of this test's trace are checked.
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"winstrom":{"@version":"1.0","@rowCount":"1","traced":[{"id":1}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := flexibee.NewClient(srv.URL, "demo", "user", "pass", discardLogger)
+	ev := registry.Evidence{Slug: "traced", Table: "flexibee_traced", PrimaryKey: "id"}
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "test")
+	require.NoError(t, syncEvidence(ctx, client, newMockSyncStore(), ev, 100, discardLogger))
+	root.End()
+
+	names := map[string]int{}
+	var evidenceSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			continue
+		}
+		names[span.Name()]++
+		if span.Name() == "sync.evidence" {
+			evidenceSpan = span
+		}
+	}
+
+	assert.Equal(t, map[string]int{
+		"test":                1,
+		"sync.evidence":       1,
+		"flexibee.fetch_page": 1,
+		"flexibee.request":    1,
+		"flexibee.parse":      1,
+		"store.upsert":        1,
+	}, names)
+	require.NotNil(t, evidenceSpan)
+	assert.Contains(t, evidenceSpan.Attributes(), attribute.Int64("sync.records_upserted", 1))
+}
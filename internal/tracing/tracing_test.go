@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestLogHandler_AddsTraceIDs(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("evidence", "banka")
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "sync")
+	logger.InfoContext(ctx, "in span")
+	span.End()
+	logger.Info("outside span")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var inSpan, outside map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &inSpan))
+	require.NoError(t, json.Unmarshal(lines[1], &outside))
+
+	assert.Equal(t, span.SpanContext().TraceID().String(), inSpan["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), inSpan["span_id"])
+	assert.Equal(t, "banka", inSpan["evidence"])
+	assert.NotContains(t, outside, "trace_id")
+}
@@ -0,0 +1,66 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+// rateTable is the synced table of Flexibee exchange rates (kurz).
+const rateTable = "flexibee_kurz"
+
+// CurrencyViews returns a view per document evidence with monetary totals,
+// named after its table with a "_base" suffix. Each exposes all columns of
+// the table plus the base currency, the rate applied and every total
+// converted to the base currency as base_<column>.
+//
+// Documents in a foreign currency are converted from their <column>Men
+// amount using the document's own rate, or when it has none the Flexibee
+// rate of its currency valid on the document date.
+func CurrencyViews(reg *registry.Registry, baseCurrency string) []View {
+	var views []View
+	for _, ev := range reg.All() {
+		if len(ev.SumColumns) == 0 || ev.DateColumn == "" {
+			continue
+		}
+		views = append(views, currencyView(ev, baseCurrency))
+	}
+	return views
+}
+
+func currencyView(ev registry.Evidence, baseCurrency string) View {
+	base := "'" + strings.ReplaceAll(baseCurrency, "'", "''") + "'"
+	inBase := fmt.Sprintf(`COALESCE(flexibee_relation_code(t."mena"), %s) = %s`, base, base)
+	rate := `COALESCE(NULLIF(t."kurz", 0) / NULLIF(t."kurzMnozstvi", 0), r.rate)`
+
+	cols := []string{
+		"t.*",
+		base + " AS base_currency",
+		fmt.Sprintf("CASE WHEN %s THEN 1 ELSE %s END AS base_rate", inBase, rate),
+	}
+	for _, col := range ev.SumColumns {
+		cols = append(cols, fmt.Sprintf(`CASE WHEN %s THEN t."%s" ELSE t."%sMen" * %s END AS "base_%s"`,
+			inBase, col, col, rate, col))
+	}
+
+	query := fmt.Sprintf(`
+				SELECT
+					%s
+				FROM %s t
+				LEFT JOIN LATERAL (
+					SELECT k."nbStred" / NULLIF(k."kurzMnozstvi", 0) AS rate
+					FROM %s k
+					WHERE flexibee_relation_code(k."mena") = flexibee_relation_code(t."mena")
+						AND k."platiOdData" <= t."%s"
+					ORDER BY k."platiOdData" DESC
+					LIMIT 1
+				) r ON TRUE`,
+		strings.Join(cols, ",\n\t\t\t\t\t"), ev.Table, rateTable, ev.DateColumn)
+
+	return View{
+		Name:   ev.Table + "_base",
+		Tables: []string{ev.Table, rateTable},
+		Query:  query,
+	}
+}
@@ -0,0 +1,121 @@
+package views
+
+// View is a curated, Metabase-friendly view over the synced tables.
+type View struct {
+	Name   string   // View name (e.g. "issued_invoices")
+	Tables []string // Synced tables the view reads from
+	Query  string   // SELECT statement defining the view; must expose a unique id column
+}
+
+// Default returns the curated views shipped with the adapter.
+func Default() []View {
+	return []View{
+		{
+			Name:   "issued_invoices",
+			Tables: []string{"flexibee_faktura_vydana", "flexibee_adresar"},
+			Query: `
+				SELECT
+					f.id,
+					f."kod" AS invoice_number,
+					f."datVyst" AS issue_date,
+					f."datSplat" AS due_date,
+					flexibee_relation_code(f."firma") AS customer_code,
+					COALESCE(a."nazev", f."nazFirmy") AS customer_name,
+					flexibee_relation_code(f."mena") AS currency,
+					f."sumCelkem" AS total_amount,
+					f."sumCelkemMen" AS total_amount_currency,
+					f."zbyvaUhradit" AS amount_due,
+					CASE
+						WHEN f."stavUhrK" LIKE 'stavUhr.uhrazeno%' THEN 'paid'
+						WHEN f."stavUhrK" LIKE 'stavUhr.castUhr%' THEN 'partially paid'
+						WHEN f."datSplat" < CURRENT_DATE THEN 'overdue'
+						ELSE 'unpaid'
+					END AS payment_status,
+					flexibee_relation_code(f."stredisko") AS cost_center,
+					f.synced_at
+				FROM flexibee_faktura_vydana f
+				LEFT JOIN flexibee_adresar a ON a."kod" = flexibee_relation_code(f."firma")`,
+		},
+		{
+			Name:   "received_invoices",
+			Tables: []string{"flexibee_faktura_prijata", "flexibee_adresar"},
+			Query: `
+				SELECT
+					f.id,
+					f."kod" AS invoice_number,
+					f."cisDosle" AS supplier_invoice_number,
+					f."datVyst" AS issue_date,
+					f."datSplat" AS due_date,
+					flexibee_relation_code(f."firma") AS supplier_code,
+					COALESCE(a."nazev", f."nazFirmy") AS supplier_name,
+					flexibee_relation_code(f."mena") AS currency,
+					f."sumCelkem" AS total_amount,
+					f."sumCelkemMen" AS total_amount_currency,
+					f."zbyvaUhradit" AS amount_due,
+					CASE
+						WHEN f."stavUhrK" LIKE 'stavUhr.uhrazeno%' THEN 'paid'
+						WHEN f."stavUhrK" LIKE 'stavUhr.castUhr%' THEN 'partially paid'
+						WHEN f."datSplat" < CURRENT_DATE THEN 'overdue'
+						ELSE 'unpaid'
+					END AS payment_status,
+					flexibee_relation_code(f."stredisko") AS cost_center,
+					f.synced_at
+				FROM flexibee_faktura_prijata f
+				LEFT JOIN flexibee_adresar a ON a."kod" = flexibee_relation_code(f."firma")`,
+		},
+		{
+			Name: "stock_movements",
+			Tables: []string{
+				"flexibee_skladovy_pohyb_polozka", "flexibee_skladovy_pohyb",
+				"flexibee_cenik", "flexibee_sklad",
+			},
+			Query: `
+				SELECT
+					p.id,
+					h."kod" AS document_number,
+					h."datVyst" AS movement_date,
+					CASE h."typPohybuK"
+						WHEN 'typPohybu.prijem' THEN 'receipt'
+						WHEN 'typPohybu.vydej' THEN 'issue'
+						ELSE h."typPohybuK"
+					END AS direction,
+					flexibee_relation_code(p."cenik") AS product_code,
+					c."nazev" AS product_name,
+					flexibee_relation_code(p."sklad") AS warehouse_code,
+					s."nazev" AS warehouse_name,
+					p."mnozMj" AS quantity,
+					p."sumCelkem" AS total_amount,
+					p.synced_at
+				FROM flexibee_skladovy_pohyb_polozka p
+				LEFT JOIN flexibee_skladovy_pohyb h ON h."kod" = flexibee_relation_code(p."doklSklad")
+				LEFT JOIN flexibee_cenik c ON c."kod" = flexibee_relation_code(p."cenik")
+				LEFT JOIN flexibee_sklad s ON s."kod" = flexibee_relation_code(p."sklad")`,
+		},
+		{
+			Name:   "bank_movements",
+			Tables: []string{"flexibee_banka", "flexibee_bankovni_ucet", "flexibee_adresar"},
+			Query: `
+				SELECT
+					b.id,
+					b."kod" AS document_number,
+					b."datVyst" AS movement_date,
+					flexibee_relation_code(b."banka") AS bank_account_code,
+					u."nazev" AS bank_account_name,
+					CASE b."typPohybuK"
+						WHEN 'typPohybu.prijem' THEN 'income'
+						WHEN 'typPohybu.vydej' THEN 'expense'
+						ELSE b."typPohybuK"
+					END AS direction,
+					CASE WHEN b."typPohybuK" = 'typPohybu.vydej' THEN -b."sumCelkem" ELSE b."sumCelkem" END AS amount,
+					flexibee_relation_code(b."mena") AS currency,
+					flexibee_relation_code(b."firma") AS counterparty_code,
+					COALESCE(a."nazev", b."nazFirmy") AS counterparty_name,
+					b."varSym" AS variable_symbol,
+					b."popis" AS description,
+					b.synced_at
+				FROM flexibee_banka b
+				LEFT JOIN flexibee_bankovni_ucet u ON u."kod" = flexibee_relation_code(b."banka")
+				LEFT JOIN flexibee_adresar a ON a."kod" = flexibee_relation_code(b."firma")`,
+		},
+	}
+}
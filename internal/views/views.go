@@ -0,0 +1,273 @@
+package views
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Config controls how curated views are built.
+type Config struct {
+	// Materialized builds materialized views refreshed after every sync pass
+	// instead of plain views.
+	Materialized bool
+	// Deferred, if set, returns further views that depend on data read
+	// from Flexibee, such as the base currency views. It is called in
+	// Prepare and, until it succeeds, again before every Run.
+	Deferred func(ctx context.Context) ([]View, error)
+}
+
+// Manager creates and maintains curated views. It implements the engine's
+// Stage interface: views are (re)built in Prepare and refreshed in Run.
+type Manager struct {
+	pool   *pgxpool.Pool
+	views  []View
+	config Config
+	logger *slog.Logger
+
+	built    []string // views that exist and are up to date
+	deferred bool     // the deferred views have been added to views
+}
+
+// NewManager creates a new Manager for the given views.
+func NewManager(pool *pgxpool.Pool, views []View, cfg Config, logger *slog.Logger) *Manager {
+	return &Manager{
+		pool:   pool,
+		views:  views,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Name implements sync.Stage.
+func (m *Manager) Name() string {
+	return "views"
+}
+
+// Prepare builds every view whose definition or underlying columns changed
+// since it was last built. Views whose tables are missing or lack a column
+// are skipped with a warning.
+func (m *Manager) Prepare(ctx context.Context) error {
+	m.built = m.built[:0]
+	m.addDeferred(ctx)
+	if err := m.ensureViews(ctx, m.views); err != nil {
+		return err
+	}
+	m.logger.Info("curated views ready", "views", len(m.built), "materialized", m.config.Materialized)
+	return nil
+}
+
+// Run builds the deferred views if they could not be built before and
+// refreshes materialized views. Plain views need no maintenance.
+func (m *Manager) Run(ctx context.Context) error {
+	if n := len(m.views); !m.deferred && m.addDeferred(ctx) {
+		if err := m.ensureViews(ctx, m.views[n:]); err != nil {
+			return err
+		}
+	}
+	if !m.config.Materialized {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range m.built {
+		refreshSQL := fmt.Sprintf("REFRESH MATERIALIZED VIEW CONCURRENTLY %s", pgx.Identifier{name}.Sanitize())
+		if _, err := m.pool.Exec(ctx, refreshSQL); err != nil {
+			errs = append(errs, fmt.Errorf("refresh view %s: %w", name, err))
+			continue
+		}
+		m.logger.Debug("refreshed materialized view", "view", name)
+	}
+	return errors.Join(errs...)
+}
+
+// addDeferred adds the deferred views to the managed views, reporting
+// whether it added any.
+func (m *Manager) addDeferred(ctx context.Context) bool {
+	if m.config.Deferred == nil || m.deferred {
+		return false
+	}
+	views, err := m.config.Deferred(ctx)
+	if err != nil {
+		m.logger.Warn("deferred views unavailable, retrying after the next sync", "error", err)
+		return false
+	}
+	m.views = append(m.views, views...)
+	m.deferred = true
+	return len(views) > 0
+}
+
+// ensureViews builds views as needed and records the ones that exist.
+func (m *Manager) ensureViews(ctx context.Context, views []View) error {
+	for _, v := range views {
+		ok, err := m.ensureView(ctx, v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.built = append(m.built, v.Name)
+		}
+	}
+	return nil
+}
+
+// ensureView builds v if needed. It returns false if v cannot be built
+// against the current schema.
+func (m *Manager) ensureView(ctx context.Context, v View) (bool, error) {
+	signature, missing, err := m.tableSignature(ctx, v.Tables)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) > 0 {
+		m.logger.Warn("skipping view, tables missing", "view", v.Name, "tables", missing)
+		return false, nil
+	}
+
+	kind := m.kind()
+	checksum := viewChecksum(v, kind, signature)
+
+	var stored string
+	err = m.pool.QueryRow(ctx, "SELECT checksum FROM curated_views WHERE name = $1", v.Name).Scan(&stored)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("read checksum of view %s: %w", v.Name, err)
+	}
+
+	current, err := m.relkind(ctx, v.Name)
+	if err != nil {
+		return false, err
+	}
+	if stored == checksum && current == relkindFor(kind) {
+		return true, nil
+	}
+
+	err = pgx.BeginFunc(ctx, m.pool, func(tx pgx.Tx) error {
+		for _, stmt := range buildStatements(v, kind, current, checksum) {
+			if _, err := tx.Exec(ctx, stmt.sql, stmt.args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		m.logger.Warn("failed to build view", "view", v.Name, "error", err)
+		return false, nil
+	}
+
+	m.logger.Info("built curated view", "view", v.Name, "kind", kind)
+	return true, nil
+}
+
+func (m *Manager) kind() string {
+	if m.config.Materialized {
+		return "MATERIALIZED VIEW"
+	}
+	return "VIEW"
+}
+
+// tableSignature describes the columns of tables so that views are rebuilt
+// whenever a column is added or changes type. It also returns the tables
+// that do not exist.
+func (m *Manager) tableSignature(ctx context.Context, tables []string) (string, []string, error) {
+	rows, err := m.pool.Query(ctx, `
+		SELECT table_name, column_name, data_type
+		FROM information_schema.columns
+		WHERE table_name = ANY($1)
+		ORDER BY table_name, column_name
+	`, tables)
+	if err != nil {
+		return "", nil, fmt.Errorf("read view columns: %w", err)
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	found := make(map[string]bool)
+	for rows.Next() {
+		var table, column, dataType string
+		if err := rows.Scan(&table, &column, &dataType); err != nil {
+			return "", nil, fmt.Errorf("read view columns: %w", err)
+		}
+		found[table] = true
+		fmt.Fprintf(&sb, "%s.%s:%s;", table, column, dataType)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, fmt.Errorf("read view columns: %w", err)
+	}
+
+	var missing []string
+	for _, t := range tables {
+		if !found[t] {
+			missing = append(missing, t)
+		}
+	}
+	return sb.String(), missing, nil
+}
+
+// relkind returns "v" for a view, "m" for a materialized view, another
+// pg_class kind for other relations and "" if name does not exist.
+func (m *Manager) relkind(ctx context.Context, name string) (string, error) {
+	var kind *string
+	err := m.pool.QueryRow(ctx,
+		"SELECT (SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1))",
+		pgx.Identifier{name}.Sanitize(),
+	).Scan(&kind)
+	if err != nil {
+		return "", fmt.Errorf("look up view %s: %w", name, err)
+	}
+	if kind == nil {
+		return "", nil
+	}
+	return *kind, nil
+}
+
+func relkindFor(kind string) string {
+	if kind == "MATERIALIZED VIEW" {
+		return "m"
+	}
+	return "v"
+}
+
+// viewChecksum fingerprints a view definition against the current schema.
+func viewChecksum(v View, kind, signature string) string {
+	sum := sha256.Sum256([]byte(kind + "\n" + v.Query + "\n" + signature))
+	return hex.EncodeToString(sum[:])
+}
+
+type statement struct {
+	sql  string
+	args []any
+}
+
+// buildStatements returns the statements replacing the relation currently
+// named v.Name (of pg_class kind current) with v built as kind.
+func buildStatements(v View, kind, current, checksum string) []statement {
+	name := pgx.Identifier{v.Name}.Sanitize()
+
+	var stmts []statement
+	switch current {
+	case "v":
+		stmts = append(stmts, statement{sql: "DROP VIEW " + name})
+	case "m":
+		stmts = append(stmts, statement{sql: "DROP MATERIALIZED VIEW " + name})
+	}
+
+	stmts = append(stmts, statement{sql: fmt.Sprintf("CREATE %s %s AS %s", kind, name, v.Query)})
+	if kind == "MATERIALIZED VIEW" {
+		// REFRESH ... CONCURRENTLY requires a unique index.
+		idx := pgx.Identifier{v.Name + "_id_idx"}.Sanitize()
+		stmts = append(stmts, statement{sql: fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (id)", idx, name)})
+	}
+
+	stmts = append(stmts, statement{
+		sql: `INSERT INTO curated_views (name, checksum, built_at) VALUES ($1, $2, NOW())
+			ON CONFLICT (name) DO UPDATE SET checksum = $2, built_at = NOW()`,
+		args: []any{v.Name, checksum},
+	})
+	return stmts
+}
@@ -0,0 +1,109 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/anaryk/metabase-flexibee-adapter/internal/registry"
+)
+
+func TestDefault_Definitions(t *testing.T) {
+	t.Parallel()
+
+	names := make(map[string]bool)
+	for _, v := range Default() {
+		assert.False(t, names[v.Name], "duplicate view %s", v.Name)
+		names[v.Name] = true
+
+		assert.NotEmpty(t, v.Tables, "view %s should list its tables", v.Name)
+		for _, table := range v.Tables {
+			assert.Contains(t, v.Query, table, "view %s lists table %s it does not read", v.Name, table)
+		}
+		assert.Contains(t, v.Query, ".id,", "view %s must expose an id column", v.Name)
+	}
+
+	for _, name := range []string{"issued_invoices", "received_invoices", "stock_movements", "bank_movements"} {
+		assert.True(t, names[name], "missing view %s", name)
+	}
+}
+
+func TestViewChecksum(t *testing.T) {
+	t.Parallel()
+
+	v := View{Name: "v", Query: "SELECT 1 AS id"}
+
+	base := viewChecksum(v, "VIEW", "t.id:bigint;")
+	assert.Equal(t, base, viewChecksum(v, "VIEW", "t.id:bigint;"))
+	assert.NotEqual(t, base, viewChecksum(v, "MATERIALIZED VIEW", "t.id:bigint;"))
+	assert.NotEqual(t, base, viewChecksum(v, "VIEW", "t.id:bigint;t.kod:text;"))
+}
+
+func TestManager_AddDeferred_Retries(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	m := NewManager(nil, []View{{Name: "a"}}, Config{
+		Deferred: func(context.Context) ([]View, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("flexibee unreachable")
+			}
+			return []View{{Name: "b"}}, nil
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	assert.False(t, m.addDeferred(context.Background()))
+	assert.True(t, m.addDeferred(context.Background()))
+	assert.False(t, m.addDeferred(context.Background()))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, []View{{Name: "a"}, {Name: "b"}}, m.views)
+}
+
+func TestBuildStatements(t *testing.T) {
+	t.Parallel()
+
+	v := View{Name: "issued_invoices", Query: "SELECT 1 AS id"}
+
+	stmts := buildStatements(v, "VIEW", "", "abc")
+	require.Len(t, stmts, 2)
+	assert.Equal(t, `CREATE VIEW "issued_invoices" AS SELECT 1 AS id`, stmts[0].sql)
+	assert.True(t, strings.HasPrefix(stmts[1].sql, "INSERT INTO curated_views"))
+	assert.Equal(t, []any{"issued_invoices", "abc"}, stmts[1].args)
+
+	stmts = buildStatements(v, "MATERIALIZED VIEW", "v", "abc")
+	require.Len(t, stmts, 4)
+	assert.Equal(t, `DROP VIEW "issued_invoices"`, stmts[0].sql)
+	assert.Equal(t, `CREATE MATERIALIZED VIEW "issued_invoices" AS SELECT 1 AS id`, stmts[1].sql)
+	assert.Equal(t, `CREATE UNIQUE INDEX "issued_invoices_id_idx" ON "issued_invoices" (id)`, stmts[2].sql)
+
+	stmts = buildStatements(v, "VIEW", "m", "abc")
+	assert.Equal(t, `DROP MATERIALIZED VIEW "issued_invoices"`, stmts[0].sql)
+}
+
+func TestCurrencyViews(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(registry.Evidence{Slug: "faktura-vydana", Table: "flexibee_faktura_vydana", DateColumn: "datVyst", SumColumns: []string{"sumCelkem"}})
+	reg.Register(registry.Evidence{Slug: "objednavka-prijata", Table: "flexibee_objednavka_prijata", DateColumn: "datVyst"})
+	reg.Register(registry.Evidence{Slug: "adresar", Table: "flexibee_adresar", IsMasterData: true})
+
+	views := CurrencyViews(reg, "CZK")
+	require.Len(t, views, 1)
+
+	v := views[0]
+	assert.Equal(t, "flexibee_faktura_vydana_base", v.Name)
+	assert.Equal(t, []string{"flexibee_faktura_vydana", "flexibee_kurz"}, v.Tables)
+	assert.Contains(t, v.Query, `'CZK' AS base_currency`)
+	assert.Contains(t, v.Query, `THEN t."sumCelkem" ELSE t."sumCelkemMen" * COALESCE(NULLIF(t."kurz", 0) / NULLIF(t."kurzMnozstvi", 0), r.rate) END AS "base_sumCelkem"`)
+	assert.Contains(t, v.Query, `k."platiOdData" <= t."datVyst"`)
+
+	// Currency codes end up in the query as literals.
+	assert.Contains(t, CurrencyViews(reg, "C'Z")[0].Query, `'C''Z' AS base_currency`)
+}